@@ -3,109 +3,234 @@ package auth
 import (
 	"errors"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// TokenConfig configures the TokenIssuer. Tokens are signed with the key named by SigningKeyId and
+// verified with any of the keys, so a retired key keeps verifying its tokens until it is removed.
+// Tokens without a key id were signed with the DefaultKeyId key before the keys had ids, they are
+// only accepted when issued before LegacyTokensIssuedBefore.
 type TokenConfig struct {
-	SecretKey          string
-	AccessTokenExpiry  int
-	RefreshTokenExpiry int
+	Issuer                   string
+	Audience                 string
+	AccessTokenExpiry        time.Duration
+	OrderTokenExpiry         time.Duration
+	SigningKeyId             string
+	Keys                     []KeyConfig
+	LegacyTokensIssuedBefore time.Time
 }
 
+// DefaultKeyId names the key configured with JWT_SECRET_KEY, which signed the tokens issued before the keys had ids
+const DefaultKeyId = "default"
+
 var (
-	ErrTokenExpiry = errors.New("token expired")
+	ErrTokenExpiry       = errors.New("token expired")
+	ErrInvalidOrderToken = errors.New("order token is not valid for the order")
+	ErrInvalidTokenType  = errors.New("token can not be used as an access token")
+	ErrLegacyToken       = errors.New("token without a key id is no longer accepted")
 )
 
-func loadEnvConfig() (*TokenConfig, error) {
-	envTokenExpMins := os.Getenv("JWT_ACC_TOKEN_EXP_MINS")
-	tokenExp, err := strconv.Atoi(envTokenExpMins)
-	if err != nil {
-		return nil, err
+// token types, carried in the typ claim so a token is only accepted where it was meant to be used
+const (
+	AccessTokenType = "access"
+	OrderTokenType  = "order"
+)
+
+// CustomClaims carry the roles of the user along with the permissions the roles granted when the token was issued
+type CustomClaims struct {
+	UserId      string   `json:"user_id"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+	Type        string   `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// OrderClaims grant access to a single order to whoever holds the token, letting guest
+// customers manage their booking without an account
+type OrderClaims struct {
+	OrderId string `json:"order_id"`
+	Type    string `json:"typ"`
+	jwt.RegisteredClaims
+}
+
+// TokenIssuer signs and verifies the access and order tokens, it is built once at startup
+type TokenIssuer struct {
+	keys       map[string]*signingKey
+	signingKey *signingKey
+	config     TokenConfig
+}
+
+func NewTokenIssuer(tokenConfig TokenConfig) (*TokenIssuer, error) {
+	issuer := TokenIssuer{
+		keys:   make(map[string]*signingKey, len(tokenConfig.Keys)),
+		config: tokenConfig,
 	}
 
-	envRefresTokenExpMins := os.Getenv("JWT_REFRESH_TOKEN_EXP_MINS")
-	refresTokenExp, err := strconv.Atoi(envRefresTokenExpMins)
-	if err != nil {
-		return nil, err
+	for _, keyConfig := range tokenConfig.Keys {
+		key, err := loadSigningKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("load signing key %s: %w", keyConfig.ID, err)
+		}
+		if _, ok := issuer.keys[key.id]; ok {
+			return nil, fmt.Errorf("signing key %s is configured more than once", key.id)
+		}
+		issuer.keys[key.id] = key
 	}
-	tokenConfig := TokenConfig{
-		SecretKey:          os.Getenv("JWT_SECRET_KEY"),
-		AccessTokenExpiry:  tokenExp,
-		RefreshTokenExpiry: refresTokenExp,
+
+	signingKey, ok := issuer.keys[tokenConfig.SigningKeyId]
+	if !ok {
+		return nil, fmt.Errorf("signing key %s is not configured", tokenConfig.SigningKeyId)
 	}
-	return &tokenConfig, nil
-}
+	if !signingKey.canSign() {
+		return nil, fmt.Errorf("signing key %s has no private key", tokenConfig.SigningKeyId)
+	}
+	issuer.signingKey = signingKey
 
-type CustomClaims struct {
-	UserId string   `json:"user_id"`
-	Roles  []string `json:"roles"`
-	jwt.RegisteredClaims
+	return &issuer, nil
 }
 
-func GenerateAccessToken(userId string, roles []string) (string, error) {
-	tokenConfig, err := loadEnvConfig()
+func (ti *TokenIssuer) GenerateAccessToken(userId string, roles, permissions []string) (string, error) {
+	claims := CustomClaims{
+		UserId:           userId,
+		Roles:            roles,
+		Permissions:      permissions,
+		Type:             AccessTokenType,
+		RegisteredClaims: ti.registeredClaims(ti.config.AccessTokenExpiry),
+	}
+	token, err := ti.sign(claims)
 	if err != nil {
 		return "", fmt.Errorf("generate access token: %w", err)
 	}
-	claims := CustomClaims{
-		UserId: userId,
-		Roles:  roles,
-		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * time.Duration(tokenConfig.AccessTokenExpiry))),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(tokenConfig.SecretKey))
+	return token, nil
 }
 
-func GenerateRefreshToken(userId string, roles []string) (string, error) {
-	tokenConfig, err := loadEnvConfig()
-	if err != nil {
-		return "", fmt.Errorf("generate refresh token: %w", err)
+func (ti *TokenIssuer) ValidateToken(tokenString string) (CustomClaims, error) {
+	var claims CustomClaims
+	if err := ti.parse(tokenString, &claims); err != nil {
+		return CustomClaims{}, err
 	}
-	claims := CustomClaims{
-		UserId: userId,
-		Roles:  roles,
-		RegisteredClaims: jwt.RegisteredClaims{
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * time.Duration(tokenConfig.RefreshTokenExpiry))),
-		},
-	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(tokenConfig.SecretKey))
+	if claims.Type != AccessTokenType {
+		return CustomClaims{}, ErrInvalidTokenType
+	}
+	return claims, nil
 }
 
-func ValidateToken(tokenString string) (CustomClaims, error) {
-	claims, err := GetClaims(tokenString)
+func (ti *TokenIssuer) GenerateOrderToken(orderId string) (string, error) {
+	claims := OrderClaims{
+		OrderId:          orderId,
+		Type:             OrderTokenType,
+		RegisteredClaims: ti.registeredClaims(ti.config.OrderTokenExpiry),
+	}
+	token, err := ti.sign(claims)
 	if err != nil {
-		return CustomClaims{}, err
+		return "", fmt.Errorf("generate order token: %w", err)
 	}
-	if claims.ExpiresAt.Before(time.Now()) {
-		return CustomClaims{}, ErrTokenExpiry
+	return token, nil
+}
+
+// ValidateOrderToken makes sure the token was issued for the given order and has not expired
+func (ti *TokenIssuer) ValidateOrderToken(tokenString, orderId string) error {
+	var claims OrderClaims
+	if err := ti.parse(tokenString, &claims); err != nil {
+		return err
 	}
-	return claims, nil
+	if claims.OrderId == "" || claims.OrderId != orderId {
+		return ErrInvalidOrderToken
+	}
+	return nil
+}
+
+// JWKS returns the public keys of the asymmetric signing keys, the HMAC secrets are never published
+func (ti *TokenIssuer) JWKS() JWKS {
+	jwks := JWKS{Keys: make([]JWK, 0, len(ti.keys))}
+	for _, keyConfig := range ti.config.Keys {
+		if jwk, ok := ti.keys[keyConfig.ID].jwk(); ok {
+			jwks.Keys = append(jwks.Keys, jwk)
+		}
+	}
+	return jwks
 }
 
-func GetClaims(tokenString string) (CustomClaims, error) {
-	tokenConfig, err := loadEnvConfig()
+func (ti *TokenIssuer) registeredClaims(expiry time.Duration) jwt.RegisteredClaims {
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    ti.config.Issuer,
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiry)),
+	}
+	if ti.config.Audience != "" {
+		claims.Audience = jwt.ClaimStrings{ti.config.Audience}
+	}
+	return claims
+}
+
+func (ti *TokenIssuer) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ti.signingKey.method, claims)
+	token.Header["kid"] = ti.signingKey.id
+	return token.SignedString(ti.signingKey.signKey())
+}
+
+// parse verifies the token and fills in its claims. The issuer and audience are only checked on tokens naming
+// their key, the tokens issued before the keys had ids carry neither and stay valid until they expire as long
+// as they were issued before the legacy cutoff.
+func (ti *TokenIssuer) parse(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, ti.verificationKey,
+		jwt.WithValidMethods(supportedAlgorithms),
+		jwt.WithExpirationRequired(),
+	)
 	if err != nil {
-		return CustomClaims{}, fmt.Errorf("validate access token: %w", err)
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return ErrTokenExpiry
+		}
+		return err
 	}
-	token, err := jwt.ParseWithClaims(tokenString, &CustomClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return []byte(tokenConfig.SecretKey), nil
-	})
 
+	if _, ok := token.Header["kid"].(string); !ok {
+		return ti.checkLegacyToken(claims)
+	}
+
+	var options []jwt.ParserOption
+	if ti.config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(ti.config.Issuer))
+	}
+	if ti.config.Audience != "" {
+		options = append(options, jwt.WithAudience(ti.config.Audience))
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return jwt.NewValidator(options...).Validate(claims)
+}
+
+// checkLegacyToken accepts a token without a key id only when it was issued before the legacy cutoff,
+// no such token is accepted when the cutoff is not configured
+func (ti *TokenIssuer) checkLegacyToken(claims jwt.Claims) error {
+	issuedAt, err := claims.GetIssuedAt()
 	if err != nil {
-		return CustomClaims{}, err
+		return err
 	}
-	claims, ok := token.Claims.(*CustomClaims)
+	if issuedAt == nil || !issuedAt.Before(ti.config.LegacyTokensIssuedBefore) {
+		return ErrLegacyToken
+	}
+	return nil
+}
+
+// verificationKey picks the key named by the kid header of the token, tokens issued before the keys had ids
+// are verified with the default key
+func (ti *TokenIssuer) verificationKey(token *jwt.Token) (any, error) {
+	kid, ok := token.Header["kid"].(string)
 	if !ok {
-		return CustomClaims{}, fmt.Errorf("error while decoding the claims")
+		kid = DefaultKeyId
+	}
+	key, ok := ti.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("signing key %s does not use %s", key.id, token.Method.Alg())
 	}
-	return *claims, nil
+	return key.verifyKey(), nil
 }
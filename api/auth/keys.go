@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signing algorithms supported for the keys
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+var supportedAlgorithms = []string{HS256, RS256, EdDSA}
+
+// KeyConfig describes a signing key. HS256 keys need the Secret, RS256 and EdDSA keys are read from PEM files,
+// a key with only the public key file can verify tokens but not sign them.
+type KeyConfig struct {
+	ID             string
+	Algorithm      string
+	Secret         string
+	PrivateKeyFile string
+	PublicKeyFile  string
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type signingKey struct {
+	id         string
+	method     jwt.SigningMethod
+	secret     []byte
+	privateKey crypto.Signer
+	publicKey  crypto.PublicKey
+}
+
+func loadSigningKey(keyConfig KeyConfig) (*signingKey, error) {
+	if keyConfig.ID == "" {
+		return nil, errors.New("key id can not be empty")
+	}
+	key := signingKey{id: keyConfig.ID}
+
+	switch keyConfig.Algorithm {
+	case HS256:
+		if len(keyConfig.Secret) == 0 {
+			return nil, errors.New("HS256 keys need a secret")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.secret = []byte(keyConfig.Secret)
+		return &key, nil
+	case RS256:
+		key.method = jwt.SigningMethodRS256
+	case EdDSA:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", keyConfig.Algorithm)
+	}
+
+	if keyConfig.PrivateKeyFile != "" {
+		privateKey, err := readPrivateKey(keyConfig.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.privateKey = privateKey
+		key.publicKey = privateKey.Public()
+	} else if keyConfig.PublicKeyFile != "" {
+		publicKey, err := readPublicKey(keyConfig.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key.publicKey = publicKey
+	} else {
+		return nil, fmt.Errorf("%s keys need a private or public key file", keyConfig.Algorithm)
+	}
+
+	switch key.publicKey.(type) {
+	case *rsa.PublicKey:
+		if key.method != jwt.SigningMethodRS256 {
+			return nil, errors.New("RSA keys can only be used with RS256")
+		}
+	case ed25519.PublicKey:
+		if key.method != jwt.SigningMethodEdDSA {
+			return nil, errors.New("Ed25519 keys can only be used with EdDSA")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.publicKey)
+	}
+	return &key, nil
+}
+
+func (sk *signingKey) canSign() bool {
+	return sk.secret != nil || sk.privateKey != nil
+}
+
+func (sk *signingKey) signKey() any {
+	if sk.secret != nil {
+		return sk.secret
+	}
+	return sk.privateKey
+}
+
+func (sk *signingKey) verifyKey() any {
+	if sk.secret != nil {
+		return sk.secret
+	}
+	return sk.publicKey
+}
+
+func (sk *signingKey) jwk() (JWK, bool) {
+	jwk := JWK{
+		Kid: sk.id,
+		Use: "sig",
+		Alg: sk.method.Alg(),
+	}
+
+	switch publicKey := sk.publicKey.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.Kty = "OKP"
+		jwk.Crv = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+	default:
+		return JWK{}, false
+	}
+	return jwk, true
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s: %w", path, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key in %s", path)
+	}
+	return signer, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key %s: %w", path, err)
+	}
+	return key, nil
+}
@@ -3,12 +3,21 @@ package ctx
 import (
 	"context"
 	"errors"
+	"slices"
 )
 
 type UserIdKey string
 
 var UserIdCtxKey UserIdKey = "userId"
 
+type UserRolesKey string
+
+var UserRolesCtxKey UserRolesKey = "userRoles"
+
+type UserPermissionsKey string
+
+var UserPermissionsCtxKey UserPermissionsKey = "userPermissions"
+
 var (
 	ErrInvalidUserId = errors.New("invalid user id type")
 )
@@ -25,3 +34,33 @@ func UserIdValue(c context.Context) (string, error) {
 	}
 	return val, nil
 }
+
+func WithUserRoles(c context.Context, roles []string) context.Context {
+	ctx := context.WithValue(c, UserRolesCtxKey, roles)
+	return ctx
+}
+
+func UserRolesValue(c context.Context) []string {
+	val, _ := c.Value(UserRolesCtxKey).([]string)
+	return val
+}
+
+// HasRole reports whether the logged in user of the request has the role
+func HasRole(c context.Context, role string) bool {
+	return slices.Contains(UserRolesValue(c), role)
+}
+
+func WithUserPermissions(c context.Context, permissions []string) context.Context {
+	ctx := context.WithValue(c, UserPermissionsCtxKey, permissions)
+	return ctx
+}
+
+func UserPermissionsValue(c context.Context) []string {
+	val, _ := c.Value(UserPermissionsCtxKey).([]string)
+	return val
+}
+
+// HasPermission reports whether the roles of the logged in user of the request grant the permission
+func HasPermission(c context.Context, permission string) bool {
+	return slices.Contains(UserPermissionsValue(c), permission)
+}
@@ -0,0 +1,268 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ortin779/private_theatre_api/api/ctx"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/service"
+	"go.uber.org/zap"
+)
+
+type AccountsHandler struct {
+	accountsService service.AccountsService
+	logger          *zap.Logger
+}
+
+func NewAccountsHandler(logger *zap.Logger, accountsService service.AccountsService) *AccountsHandler {
+	return &AccountsHandler{
+		accountsService: accountsService,
+		logger:          logger,
+	}
+}
+
+func (accHandler *AccountsHandler) HandleSignup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var signupParams models.SignupParams
+
+		if err := json.NewDecoder(r.Body).Decode(&signupParams); err != nil {
+			accHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+			return
+		}
+
+		if errs := signupParams.Validate(); len(errs) > 0 {
+			accHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		user, err := accHandler.accountsService.SignUp(signupParams)
+		if err != nil {
+			if errors.Is(err, models.ErrUserAlreadyExists) {
+				accHandler.logger.Error("conflict", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+				return
+			}
+			accHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusCreated, user)
+	}
+}
+
+func (accHandler *AccountsHandler) HandleVerifyEmail() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var verifyParams models.VerifyEmailParams
+
+		if err := json.NewDecoder(r.Body).Decode(&verifyParams); err != nil {
+			accHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+			return
+		}
+
+		if errs := verifyParams.Validate(); len(errs) > 0 {
+			accHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		result, err := accHandler.accountsService.VerifyEmail(verifyParams)
+		if err != nil {
+			switch {
+			// unknown emails get the same response as wrong codes, so accounts can not be probed
+			case errors.Is(err, models.ErrNoUserWithEmail), errors.Is(err, models.ErrInvalidVerificationCode):
+				accHandler.logger.Error("bad request", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusBadRequest, models.ErrInvalidVerificationCode.Error())
+			case errors.Is(err, models.ErrVerificationCodeExpired), errors.Is(err, models.ErrTooManyVerificationAttempts), errors.Is(err, models.ErrEmailAlreadyVerified):
+				accHandler.logger.Error("bad request", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusBadRequest, err.Error())
+			default:
+				accHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			}
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, result)
+	}
+}
+
+// HandleResendVerification always accepts the request, so it can not be used to find out which emails have accounts
+func (accHandler *AccountsHandler) HandleResendVerification() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var resendParams models.ResendVerificationParams
+
+		if err := json.NewDecoder(r.Body).Decode(&resendParams); err != nil {
+			accHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+			return
+		}
+
+		err := accHandler.accountsService.ResendVerification(resendParams.Email)
+		if err != nil && !errors.Is(err, models.ErrNoUserWithEmail) && !errors.Is(err, models.ErrEmailAlreadyVerified) && !errors.Is(err, models.ErrVerificationResendTooSoon) {
+			accHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusAccepted, map[string]string{"message": "a verification code is sent if the email has an unverified account"})
+	}
+}
+
+func (accHandler *AccountsHandler) HandleGetProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			accHandler.logger.Error("unauthorized", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusUnauthorized, "login required")
+			return
+		}
+
+		user, err := accHandler.accountsService.GetProfile(userId)
+		if err != nil {
+			accHandler.respondWithProfileError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, user)
+	}
+}
+
+func (accHandler *AccountsHandler) HandleUpdateProfile() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			accHandler.logger.Error("unauthorized", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusUnauthorized, "login required")
+			return
+		}
+
+		var profileParams models.ProfileParams
+		if err := json.NewDecoder(r.Body).Decode(&profileParams); err != nil {
+			accHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+			return
+		}
+
+		if errs := profileParams.Validate(); len(errs) > 0 {
+			accHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		user, err := accHandler.accountsService.UpdateProfile(userId, profileParams)
+		if err != nil {
+			accHandler.respondWithProfileError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, user)
+	}
+}
+
+// HandleChangePassword sets a new password for the logged in user, the other sessions stay logged in
+func (accHandler *AccountsHandler) HandleChangePassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			accHandler.logger.Error("unauthorized", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusUnauthorized, "login required")
+			return
+		}
+
+		var passwordParams models.ChangePasswordParams
+		if err := json.NewDecoder(r.Body).Decode(&passwordParams); err != nil {
+			accHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+			return
+		}
+
+		if errs := passwordParams.Validate(); len(errs) > 0 {
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		err = accHandler.accountsService.ChangePassword(userId, passwordParams)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrWrongPassword), errors.Is(err, models.ErrPasswordNotChanged):
+				accHandler.logger.Error("bad request", zap.String("user_id", userId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusBadRequest, err.Error())
+			default:
+				accHandler.respondWithProfileError(w, err)
+			}
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// HandleForgotPassword always accepts the request, so it can not be used to find out which emails have accounts
+func (accHandler *AccountsHandler) HandleForgotPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var forgotParams models.ForgotPasswordParams
+		if err := json.NewDecoder(r.Body).Decode(&forgotParams); err != nil {
+			accHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+			return
+		}
+
+		if errs := forgotParams.Validate(); len(errs) > 0 {
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		if err := accHandler.accountsService.RequestPasswordReset(forgotParams.Email); err != nil {
+			accHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusAccepted, map[string]string{"message": "a password reset token is sent if the email has an account"})
+	}
+}
+
+func (accHandler *AccountsHandler) HandleResetPassword() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var resetParams models.ResetPasswordParams
+		if err := json.NewDecoder(r.Body).Decode(&resetParams); err != nil {
+			accHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+			return
+		}
+
+		if errs := resetParams.Validate(); len(errs) > 0 {
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		if err := accHandler.accountsService.ResetPassword(resetParams); err != nil {
+			if errors.Is(err, models.ErrInvalidResetToken) {
+				accHandler.logger.Error("bad request", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			accHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (accHandler *AccountsHandler) respondWithProfileError(w http.ResponseWriter, err error) {
+	if errors.Is(err, models.ErrNoUserWithId) {
+		accHandler.logger.Error("not found", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	accHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+	RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+}
@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -92,3 +94,111 @@ func (ah *AddonsHandler) HandleGetAddonCategories() http.HandlerFunc {
 		RespondWithJson(w, http.StatusCreated, categories)
 	}
 }
+
+func (ah *AddonsHandler) HandleGetAddon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		addon, err := ah.addonsService.GetById(r.PathValue("id"))
+		if err != nil {
+			ah.respondWithAddonError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, addon)
+	}
+}
+
+func (ah *AddonsHandler) HandleUpdateAddon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var updateParams models.UpdateAddonParams
+
+		err := json.NewDecoder(r.Body).Decode(&updateParams)
+
+		if err != nil {
+			ah.logger.Error("bad request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errs := updateParams.Validate(); len(errs) > 0 {
+			ah.logger.Error("bad request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			ah.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		addon, err := ah.addonsService.Update(r.PathValue("id"), updateParams, userId)
+		if err != nil {
+			ah.respondWithAddonError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, addon)
+	}
+}
+
+// HandleGetAddonPrices returns the prices the addon has had, newest first
+func (ah *AddonsHandler) HandleGetAddonPrices() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		prices, err := ah.addonsService.GetPrices(r.PathValue("id"))
+		if err != nil {
+			ah.respondWithAddonError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, prices)
+	}
+}
+
+func (ah *AddonsHandler) HandleArchiveAddon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			ah.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		addon, err := ah.addonsService.Archive(r.PathValue("id"), userId)
+		if err != nil {
+			ah.respondWithAddonError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, addon)
+	}
+}
+
+func (ah *AddonsHandler) HandleUnarchiveAddon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			ah.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		addon, err := ah.addonsService.Unarchive(r.PathValue("id"), userId)
+		if err != nil {
+			ah.respondWithAddonError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, addon)
+	}
+}
+
+func (ah *AddonsHandler) respondWithAddonError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		ah.logger.Error("not found", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusNotFound, "no addon found with given id")
+		return
+	}
+	ah.logger.Error("internal server error", zap.String("error", err.Error()))
+	RespondWithError(w, http.StatusInternalServerError, "internal server error")
+}
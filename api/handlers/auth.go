@@ -3,24 +3,34 @@ package handlers
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/ortin779/private_theatre_api/api/auth"
+	"github.com/ortin779/private_theatre_api/api/ctx"
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/service"
 	"go.uber.org/zap"
 )
 
 type AuthHandler struct {
-	usersService service.UsersService
-	logger       *zap.Logger
+	loginService    service.LoginService
+	sessionsService service.SessionsService
+	tokenIssuer     *auth.TokenIssuer
+	clientIPHeader  string
+	logger          *zap.Logger
 }
 
-func NewAuthHandler(logger *zap.Logger, usersService service.UsersService) *AuthHandler {
+func NewAuthHandler(logger *zap.Logger, loginService service.LoginService, sessionsService service.SessionsService, tokenIssuer *auth.TokenIssuer, clientIPHeader string) *AuthHandler {
 	return &AuthHandler{
-		usersService: usersService,
-		logger:       logger,
+		loginService:    loginService,
+		sessionsService: sessionsService,
+		tokenIssuer:     tokenIssuer,
+		clientIPHeader:  clientIPHeader,
+		logger:          logger,
 	}
 }
 
@@ -31,7 +41,7 @@ func (authHandler *AuthHandler) Login() http.HandlerFunc {
 		err := json.NewDecoder(r.Body).Decode(&loginParams)
 
 		if err != nil {
-			authHandler.logger.Error("invalid login params ", zap.Any("error", err))
+			authHandler.logger.Error("invalid login params", zap.String("error", err.Error()))
 			RespondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
@@ -42,65 +52,60 @@ func (authHandler *AuthHandler) Login() http.HandlerFunc {
 			return
 		}
 
-		user, err := authHandler.usersService.GetByEmail(loginParams.Email)
+		ip := clientIP(r, authHandler.clientIPHeader)
+		user, err := authHandler.loginService.Login(loginParams, ip)
 
 		if err != nil {
-			authHandler.logger.Error(err.Error())
-			if errors.Is(err, models.ErrNoUserWithEmail) {
-				RespondWithError(w, http.StatusNotFound, fmt.Sprintf("no user found with given email: %s", loginParams.Email))
-				return
+			var throttledErr *models.LoginThrottledError
+			switch {
+			case errors.As(err, &throttledErr):
+				authHandler.logger.Warn("login throttled", zap.String("email", loginParams.Email), zap.String("ip", ip))
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttledErr.RetryAfter.Seconds()))))
+				RespondWithError(w, http.StatusTooManyRequests, err.Error())
+			case errors.Is(err, models.ErrInvalidCredentials):
+				authHandler.logger.Warn("login failed", zap.String("email", loginParams.Email), zap.String("ip", ip), zap.String("reason", err.Error()))
+				RespondWithError(w, http.StatusUnauthorized, models.ErrInvalidCredentials.Error())
+			case errors.Is(err, models.ErrUserDisabled):
+				authHandler.logger.Warn("login of disabled user", zap.String("email", loginParams.Email), zap.String("ip", ip))
+				RespondWithError(w, http.StatusForbidden, err.Error())
+			case errors.Is(err, models.ErrEmailNotVerified):
+				authHandler.logger.Warn("login with unverified email", zap.String("email", loginParams.Email), zap.String("ip", ip))
+				RespondWithError(w, http.StatusForbidden, err.Error())
+			default:
+				authHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusInternalServerError, "something went wrong")
 			}
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-
-		isValidPassword := auth.ComparePasswordToHash(user.Password, loginParams.Password)
-		if !isValidPassword {
-			authHandler.logger.Error("Authentication error, invalid credentials", zap.Any("error", loginParams))
-			RespondWithError(w, http.StatusUnauthorized, "invalid credentials")
-			return
-		}
-		accessToken, err := auth.GenerateAccessToken(user.ID, user.Roles)
-		if err != nil {
-			authHandler.logger.Error(err.Error())
-			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
 			return
 		}
 
-		refreshToken, err := auth.GenerateRefreshToken(user.ID, user.Roles)
+		loginResponse, err := authHandler.sessionsService.Start(*user)
 		if err != nil {
 			authHandler.logger.Error(err.Error())
 			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
 			return
 		}
 
-		RespondWithJson(w, http.StatusOK, models.LoginResponse{
-			Token:        accessToken,
-			RefreshToken: refreshToken,
-		})
-
+		RespondWithJson(w, http.StatusOK, loginResponse)
 	}
 }
 
 func (authHandler *AuthHandler) RefreshToken() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		var refreshBody struct {
-			RefreshToken string `json:"refresh_token"`
-		}
+		var refreshParams models.RefreshTokenParams
 
-		err := json.NewDecoder(r.Body).Decode(&refreshBody)
+		err := json.NewDecoder(r.Body).Decode(&refreshParams)
 
 		if err != nil {
-			authHandler.logger.Error("invalid login params")
+			authHandler.logger.Error("invalid refresh token params")
 			RespondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
-		claims, err := auth.ValidateToken(refreshBody.RefreshToken)
+		loginResponse, err := authHandler.sessionsService.Refresh(refreshParams.RefreshToken)
 
 		if err != nil {
 			authHandler.logger.Error(err.Error())
-			if errors.Is(err, auth.ErrTokenExpiry) {
+			if errors.Is(err, models.ErrInvalidRefreshToken) || errors.Is(err, models.ErrRefreshTokenExpired) || errors.Is(err, models.ErrRefreshTokenReused) || errors.Is(err, models.ErrUserDisabled) {
 				RespondWithError(w, http.StatusUnauthorized, err.Error())
 				return
 			}
@@ -108,16 +113,77 @@ func (authHandler *AuthHandler) RefreshToken() http.HandlerFunc {
 			return
 		}
 
-		token, err := auth.GenerateAccessToken(claims.UserId, claims.Roles)
+		RespondWithJson(w, http.StatusOK, loginResponse)
+	}
+}
+
+// Logout ends the session of the refresh token, the access tokens already issued stay valid until they expire
+func (authHandler *AuthHandler) Logout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var refreshParams models.RefreshTokenParams
+
+		err := json.NewDecoder(r.Body).Decode(&refreshParams)
+
+		if err != nil {
+			authHandler.logger.Error("invalid logout params")
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if err := authHandler.sessionsService.End(refreshParams.RefreshToken); err != nil {
+			authHandler.logger.Error(err.Error())
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// LogoutAll ends every session of the logged in user
+func (authHandler *AuthHandler) LogoutAll() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
 		if err != nil {
+			authHandler.logger.Error("unauthorized", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusUnauthorized, "login required")
+			return
+		}
+
+		if err := authHandler.sessionsService.EndAll(userId); err != nil {
 			authHandler.logger.Error(err.Error())
 			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
 			return
 		}
 
-		RespondWithJson(w, http.StatusOK, models.LoginResponse{
-			Token:        token,
-			RefreshToken: refreshBody.RefreshToken,
-		})
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// JWKS publishes the public keys the tokens can be verified with
+func (authHandler *AuthHandler) JWKS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		RespondWithJson(w, http.StatusOK, authHandler.tokenIssuer.JWKS())
+	}
+}
+
+// clientIP is the address the request came from, without its port. Behind a proxy it is read from the header
+// the proxy sets, the last address of a list being the one the proxy saw, the earlier ones are up to the client.
+func clientIP(r *http.Request, header string) string {
+	if header != "" {
+		values := r.Header.Values(header)
+		if len(values) > 0 {
+			addresses := strings.Split(values[len(values)-1], ",")
+			if ip := net.ParseIP(strings.TrimSpace(addresses[len(addresses)-1])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
 	}
+	return host
 }
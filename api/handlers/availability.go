@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/service"
+	"go.uber.org/zap"
+)
+
+type AvailabilityHandler struct {
+	logger              *zap.Logger
+	availabilityService service.AvailabilityService
+}
+
+func NewAvailabilityHandler(logger *zap.Logger, availabilityService service.AvailabilityService) *AvailabilityHandler {
+	return &AvailabilityHandler{
+		logger:              logger,
+		availabilityService: availabilityService,
+	}
+}
+
+func (avlHandler *AvailabilityHandler) HandleGetTheatreAvailability() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.PathValue("id")
+		if _, err := uuid.Parse(id); err != nil {
+			avlHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "invalid theatre id")
+			return
+		}
+
+		query := r.URL.Query()
+		params, errs := models.ParseAvailabilityParams(query.Get("from"), query.Get("to"))
+		if len(errs) > 0 {
+			avlHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		availability, err := avlHandler.availabilityService.GetTheatreAvailability(id, params)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				avlHandler.logger.Error("not found", zap.String("error", "no theatre found with given id"))
+				RespondWithError(w, http.StatusNotFound, "no theatre found with given details")
+				return
+			}
+			avlHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "internal server error")
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, availability)
+	}
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ortin779/private_theatre_api/api/ctx"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/service"
+	"go.uber.org/zap"
+)
+
+type CouponsHandler struct {
+	logger         *zap.Logger
+	couponsService service.CouponsService
+}
+
+func NewCouponsHandler(logger *zap.Logger, couponsService service.CouponsService) *CouponsHandler {
+	return &CouponsHandler{
+		logger:         logger,
+		couponsService: couponsService,
+	}
+}
+
+func (ch *CouponsHandler) HandleGetCoupons() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		coupons, err := ch.couponsService.GetAll()
+
+		if err != nil {
+			ch.respondWithCouponError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, coupons)
+	}
+}
+
+func (ch *CouponsHandler) HandleGetCoupon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		coupon, err := ch.couponsService.Get(r.PathValue("id"))
+
+		if err != nil {
+			ch.respondWithCouponError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, coupon)
+	}
+}
+
+func (ch *CouponsHandler) HandleCreateCoupon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		couponParams, userId, ok := ch.decodeCoupon(w, r)
+		if !ok {
+			return
+		}
+
+		coupon, err := ch.couponsService.Create(couponParams, userId)
+
+		if err != nil {
+			ch.respondWithCouponError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusCreated, coupon)
+	}
+}
+
+func (ch *CouponsHandler) HandleUpdateCoupon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		couponParams, userId, ok := ch.decodeCoupon(w, r)
+		if !ok {
+			return
+		}
+
+		coupon, err := ch.couponsService.Update(r.PathValue("id"), couponParams, userId)
+
+		if err != nil {
+			ch.respondWithCouponError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, coupon)
+	}
+}
+
+func (ch *CouponsHandler) HandleArchiveCoupon() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			ch.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		coupon, err := ch.couponsService.Archive(r.PathValue("id"), userId)
+		if err != nil {
+			ch.respondWithCouponError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, coupon)
+	}
+}
+
+func (ch *CouponsHandler) HandleGetRedemptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		redemptions, err := ch.couponsService.GetRedemptions(r.PathValue("id"))
+
+		if err != nil {
+			ch.respondWithCouponError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, redemptions)
+	}
+}
+
+// decodeCoupon reads and validates the coupon in the request body, responding with the error when it is not valid
+func (ch *CouponsHandler) decodeCoupon(w http.ResponseWriter, r *http.Request) (models.CouponParams, string, bool) {
+	var couponParams models.CouponParams
+
+	if err := json.NewDecoder(r.Body).Decode(&couponParams); err != nil {
+		ch.logger.Error("invalid request", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return couponParams, "", false
+	}
+
+	if errs := couponParams.Validate(); len(errs) > 0 {
+		ch.logger.Error("invalid request", zap.Any("errors", errs))
+		RespondWithJson(w, http.StatusBadRequest, errs)
+		return couponParams, "", false
+	}
+
+	userId, err := ctx.UserIdValue(r.Context())
+	if err != nil {
+		ch.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return couponParams, "", false
+	}
+	return couponParams, userId, true
+}
+
+func (ch *CouponsHandler) respondWithCouponError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		ch.logger.Error("not found", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusNotFound, "no coupon found with given id")
+	case errors.Is(err, models.ErrCouponExists):
+		ch.logger.Error("conflict", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		ch.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -8,24 +8,41 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/auth"
+	"github.com/ortin779/private_theatre_api/api/ctx"
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/service"
 	"go.uber.org/zap"
 )
 
 type OrdersHandler struct {
-	logger          *zap.Logger
-	ordersService   service.OrdersService
-	paymentsService service.RazorpayService
+	logger            *zap.Logger
+	ordersService     service.OrdersService
+	paymentsService   service.PaymentsService
+	holdsService      service.HoldsService
+	pricingService    service.PricingService
+	cancelService     service.CancellationService
+	rescheduleService service.RescheduleService
+	tokenIssuer       *auth.TokenIssuer
 }
 
 func NewOrdersHandler(logger *zap.Logger,
 	ordersService service.OrdersService,
-	paymentsService service.RazorpayService) *OrdersHandler {
+	paymentsService service.PaymentsService,
+	holdsService service.HoldsService,
+	pricingService service.PricingService,
+	cancelService service.CancellationService,
+	rescheduleService service.RescheduleService,
+	tokenIssuer *auth.TokenIssuer) *OrdersHandler {
 	return &OrdersHandler{
-		logger:          logger,
-		ordersService:   ordersService,
-		paymentsService: paymentsService,
+		logger:            logger,
+		ordersService:     ordersService,
+		paymentsService:   paymentsService,
+		holdsService:      holdsService,
+		pricingService:    pricingService,
+		cancelService:     cancelService,
+		rescheduleService: rescheduleService,
+		tokenIssuer:       tokenIssuer,
 	}
 }
 
@@ -48,34 +65,67 @@ func (orderHandler *OrdersHandler) HandleCreateOrder() http.HandlerFunc {
 			return
 		}
 
-		normalizedPrice := orderParams.TotalPrice * 100
+		priceBreakdown, err := orderHandler.pricingService.Calculate(orderParams.QuoteParams)
+		if err == nil {
+			err = orderHandler.pricingService.CheckTotal(priceBreakdown, orderParams.TotalPrice)
+		}
+		if err != nil {
+			orderHandler.respondWithPricingError(w, err)
+			return
+		}
 
-		razorpayOrderId, err := orderHandler.paymentsService.CreateOrder(normalizedPrice)
+		order := models.Order{
+			ID:             uuid.NewString(),
+			CustomerName:   orderParams.CustomerName,
+			CustomerEmail:  orderParams.CustomerEmail,
+			PhoneNumber:    orderParams.PhoneNumber,
+			TheatreId:      orderParams.TheatreId,
+			Addons:         orderParams.Addons,
+			SlotId:         orderParams.SlotId,
+			NoOfPersons:    orderParams.NoOfPersons,
+			TotalPrice:     priceBreakdown.TotalInRupees(),
+			OrderDate:      orderParams.OrderDate,
+			OrderedAt:      time.Now(),
+			PriceBreakdown: priceBreakdown,
+		}
+		// orders made by logged in customers are linked to their account, staff may be booking for someone else
+		if !ctx.HasPermission(r.Context(), models.OrdersWritePermission) {
+			order.UserId, _ = ctx.UserIdValue(r.Context())
+		}
 
+		hold, err := orderHandler.holdsService.Acquire(order)
 		if err != nil {
+			if errors.Is(err, models.ErrSlotUnavailable) {
+				orderHandler.logger.Error("conflict", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+				return
+			}
 			orderHandler.logger.Error("internal server error", zap.String("error", err.Error()))
-			RespondWithError(w, http.StatusInternalServerError, "something went wrong, while creating payment")
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
 			return
 		}
 
-		order := models.Order{
-			ID:              uuid.NewString(),
-			CustomerName:    orderParams.CustomerName,
-			CustomerEmail:   orderParams.CustomerEmail,
-			PhoneNumber:     orderParams.PhoneNumber,
-			TheatreId:       orderParams.TheatreId,
-			Addons:          orderParams.Addons,
-			SlotId:          orderParams.SlotId,
-			NoOfPersons:     orderParams.NoOfPersons,
-			TotalPrice:      orderParams.TotalPrice,
-			OrderDate:       orderParams.OrderDate,
-			OrderedAt:       time.Now(),
-			RazorpayOrderId: razorpayOrderId,
+		razorpayOrderId, err := orderHandler.paymentsService.CreateOrder(priceBreakdown.Total)
+
+		if err != nil {
+			orderHandler.releaseHold(hold)
+			orderHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong, while creating payment")
+			return
 		}
 
+		order.RazorpayOrderId = razorpayOrderId
+		order.HoldExpiresAt = &hold.ExpiresAt
+
 		err = orderHandler.ordersService.Create(order)
 
 		if err != nil {
+			orderHandler.releaseHold(hold)
+			if errors.Is(err, service.ErrDuplicateOrder) || errors.Is(err, models.ErrCouponExhausted) || errors.Is(err, models.ErrSlotClosed) {
+				orderHandler.logger.Error("conflict", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+				return
+			}
 			if errors.Is(err, sql.ErrNoRows) {
 				orderHandler.logger.Error("bad request", zap.String("error", err.Error()))
 				RespondWithError(w, http.StatusBadRequest, err.Error())
@@ -85,15 +135,98 @@ func (orderHandler *OrdersHandler) HandleCreateOrder() http.HandlerFunc {
 			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
 			return
 		}
+
+		order.AccessToken, err = orderHandler.tokenIssuer.GenerateOrderToken(order.ID)
+		if err != nil {
+			orderHandler.logger.Error("generate order token", zap.String("order_id", order.ID), zap.String("error", err.Error()))
+		}
+
 		RespondWithJson(w, http.StatusCreated, order)
 	}
 }
 
+func (orderHandler *OrdersHandler) HandleQuoteOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		var quoteParams models.QuoteParams
+
+		err := json.NewDecoder(r.Body).Decode(&quoteParams)
+
+		if err != nil {
+			orderHandler.logger.Error("bad request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse quote body")
+			return
+		}
+
+		if errs := quoteParams.Validate(); len(errs) > 0 {
+			orderHandler.logger.Error("bad request", zap.Any("errs", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		priceBreakdown, err := orderHandler.pricingService.Calculate(quoteParams)
+		if err != nil {
+			orderHandler.respondWithPricingError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, priceBreakdown)
+	}
+}
+
 func (orderHandler *OrdersHandler) HandleGetAllOrders() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		orders, err := orderHandler.ordersService.GetAll()
+		filter, errs := models.ParseOrdersFilter(r.URL.Query())
+		if len(errs) > 0 {
+			orderHandler.logger.Error("bad request", zap.Any("errs", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		// customers only get to see their own orders
+		if !ctx.HasPermission(r.Context(), models.OrdersReadPermission) {
+			userId, err := ctx.UserIdValue(r.Context())
+			if err != nil {
+				orderHandler.logger.Error("unauthorized", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusUnauthorized, "login required")
+				return
+			}
+			filter.CustomerUserId = userId
+		}
+
+		orders, err := orderHandler.ordersService.List(filter)
+
+		if err != nil {
+			orderHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, orders)
+	}
+}
+
+// HandleGetMyOrders lists the orders of the logged in user, with the same filters as the orders listing
+func (orderHandler *OrdersHandler) HandleGetMyOrders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		filter, errs := models.ParseOrdersFilter(r.URL.Query())
+		if len(errs) > 0 {
+			orderHandler.logger.Error("bad request", zap.Any("errs", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
 
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			orderHandler.logger.Error("unauthorized", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusUnauthorized, "login required")
+			return
+		}
+		filter.CustomerUserId = userId
+
+		orders, err := orderHandler.ordersService.List(filter)
 		if err != nil {
 			orderHandler.logger.Error("internal server error", zap.String("error", err.Error()))
 			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
@@ -115,6 +248,11 @@ func (orderHandler *OrdersHandler) HandleGetOrderById() http.HandlerFunc {
 		}
 
 		orderDetails, err := orderHandler.ordersService.GetById(orderId)
+		if errors.Is(err, sql.ErrNoRows) {
+			orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "no order found with given id")
+			return
+		}
 		if err != nil {
 			orderHandler.logger.Error("internal server error", zap.String("error", err.Error()))
 			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
@@ -124,3 +262,225 @@ func (orderHandler *OrdersHandler) HandleGetOrderById() http.HandlerFunc {
 		RespondWithJson(w, http.StatusOK, orderDetails)
 	}
 }
+
+func (orderHandler *OrdersHandler) HandleCancelOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		orderId := r.PathValue("orderId")
+		if _, err := uuid.Parse(orderId); err != nil {
+			orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "invalid order id")
+			return
+		}
+
+		cancellation, err := orderHandler.cancelService.Cancel(orderId, actorFromRequest(r))
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusNotFound, "no order found with given id")
+			case errors.Is(err, models.ErrOrderAlreadyCancelled), errors.Is(err, models.ErrOrderAlreadyStarted), errors.Is(err, models.ErrInvalidOrderTransition):
+				orderHandler.logger.Error("conflict", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+			default:
+				orderHandler.logger.Error("internal server error", zap.String("order_id", orderId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			}
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, cancellation)
+	}
+}
+
+func (orderHandler *OrdersHandler) HandleRescheduleOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		orderId := r.PathValue("orderId")
+		if _, err := uuid.Parse(orderId); err != nil {
+			orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "invalid order id")
+			return
+		}
+
+		var rescheduleParams models.RescheduleParams
+		if err := json.NewDecoder(r.Body).Decode(&rescheduleParams); err != nil {
+			orderHandler.logger.Error("bad request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+			return
+		}
+
+		if errs := rescheduleParams.Validate(); len(errs) > 0 {
+			orderHandler.logger.Error("bad request", zap.Any("errs", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		reschedule, err := orderHandler.rescheduleService.Reschedule(orderId, rescheduleParams, actorFromRequest(r))
+		if errors.Is(err, models.ErrRefundPending) {
+			// the order has moved, its refund is retried through the reschedule refund endpoint
+			orderHandler.logger.Error("refund pending", zap.String("order_id", orderId), zap.String("error", err.Error()))
+			RespondWithJson(w, http.StatusAccepted, reschedule)
+			return
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusNotFound, "no order found with given id")
+			case errors.Is(err, service.ErrInvalidOrder):
+				orderHandler.logger.Error("bad request", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusBadRequest, err.Error())
+			case errors.Is(err, models.ErrSlotUnavailable), errors.Is(err, models.ErrSlotClosed), errors.Is(err, models.ErrOrderNotReschedulable):
+				orderHandler.logger.Error("conflict", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+			default:
+				orderHandler.logger.Error("internal server error", zap.String("order_id", orderId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			}
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, reschedule)
+	}
+}
+
+// HandleRetryRescheduleRefund refunds what is left of the refund a cheaper reschedule of the order owes
+func (orderHandler *OrdersHandler) HandleRetryRescheduleRefund() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		orderId := r.PathValue("orderId")
+		rescheduleId := r.PathValue("rescheduleId")
+		if _, err := uuid.Parse(orderId); err != nil {
+			orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "invalid order id")
+			return
+		}
+		if _, err := uuid.Parse(rescheduleId); err != nil {
+			orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "invalid reschedule id")
+			return
+		}
+
+		refund, err := orderHandler.rescheduleService.RetryRefund(orderId, rescheduleId)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusNotFound, "no refund owed for the reschedule of the order")
+			case errors.Is(err, models.ErrRefundPending):
+				orderHandler.logger.Error("refund pending", zap.String("order_id", orderId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusBadGateway, models.ErrRefundPending.Error())
+			default:
+				orderHandler.logger.Error("internal server error", zap.String("order_id", orderId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			}
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, refund)
+	}
+}
+
+// HandleTransitionOrder moves the order to the given status, an optional note can be sent in the body
+func (orderHandler *OrdersHandler) HandleTransitionOrder(to models.OrderStatus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		orderId := r.PathValue("orderId")
+		if _, err := uuid.Parse(orderId); err != nil {
+			orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "invalid order id")
+			return
+		}
+
+		var transitionParams models.OrderTransitionParams
+		if r.ContentLength > 0 {
+			if err := json.NewDecoder(r.Body).Decode(&transitionParams); err != nil {
+				orderHandler.logger.Error("bad request", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusBadRequest, "unable to parse the request body")
+				return
+			}
+		}
+
+		err := orderHandler.ordersService.Transition(orderId, to, actorFromRequest(r), transitionParams.Note)
+		if err != nil {
+			switch {
+			case errors.Is(err, sql.ErrNoRows):
+				orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusNotFound, "no order found with given id")
+			case errors.Is(err, models.ErrInvalidOrderTransition):
+				orderHandler.logger.Error("conflict", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+			default:
+				orderHandler.logger.Error("internal server error", zap.String("order_id", orderId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			}
+			return
+		}
+
+		orderDetails, err := orderHandler.ordersService.GetById(orderId)
+		if errors.Is(err, sql.ErrNoRows) {
+			orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "no order found with given id")
+			return
+		}
+		if err != nil {
+			orderHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, orderDetails)
+	}
+}
+
+func (orderHandler *OrdersHandler) HandleGetOrderHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		orderId := r.PathValue("orderId")
+		if _, err := uuid.Parse(orderId); err != nil {
+			orderHandler.logger.Error("not found", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusNotFound, "invalid order id")
+			return
+		}
+
+		history, err := orderHandler.ordersService.GetHistory(orderId)
+		if err != nil {
+			orderHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, history)
+	}
+}
+
+func (orderHandler *OrdersHandler) releaseHold(hold *models.Hold) {
+	if err := orderHandler.holdsService.Release(hold.ID); err != nil {
+		orderHandler.logger.Error("release hold", zap.String("hold_id", hold.ID), zap.String("error", err.Error()))
+	}
+}
+
+func (orderHandler *OrdersHandler) respondWithPricingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidOrder), errors.Is(err, service.ErrPriceMismatch), errors.Is(err, models.ErrInvalidCoupon):
+		orderHandler.logger.Error("bad request", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		orderHandler.logger.Error("bad request", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusBadRequest, "no theatre found with given details")
+	default:
+		orderHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+	}
+}
+
+// actorFromRequest names who is making the change for the order history,
+// the authorization middlewares only set the user id for the logged in users
+func actorFromRequest(r *http.Request) string {
+	userId, err := ctx.UserIdValue(r.Context())
+	if err != nil {
+		return models.CustomerActor
+	}
+	return userId
+}
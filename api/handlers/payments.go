@@ -13,13 +13,15 @@ import (
 
 type PaymentsHandler struct {
 	logger          *zap.Logger
-	paymentsService service.RazorpayService
+	paymentsService service.PaymentsService
+	holdsService    service.HoldsService
 }
 
-func NewPaymentHandler(logger *zap.Logger, paymentsService service.RazorpayService) *PaymentsHandler {
+func NewPaymentHandler(logger *zap.Logger, paymentsService service.PaymentsService, holdsService service.HoldsService) *PaymentsHandler {
 	return &PaymentsHandler{
 		logger:          logger,
 		paymentsService: paymentsService,
+		holdsService:    holdsService,
 	}
 }
 
@@ -48,8 +50,41 @@ func (paymentsHandler *PaymentsHandler) VerifyPayment() http.HandlerFunc {
 			return
 		}
 
+		err = paymentsHandler.holdsService.Confirm(paymentBody.RazorpayOrderId, paymentBody.RazorpayPaymentId)
+		if err != nil {
+			if errors.Is(err, models.ErrHoldExpired) || errors.Is(err, models.ErrRescheduleFailed) || errors.Is(err, models.ErrInvalidOrderTransition) {
+				paymentsHandler.logger.Error("conflict", zap.String("razorpay_order_id", paymentBody.RazorpayOrderId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+				return
+			}
+			paymentsHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
 		RespondWithJson(w, http.StatusOK, struct {
 			Message string `json:"message"`
 		}{Message: "successfully verified payment information"})
 	}
 }
+
+// HandleFakePayment pays the order on the fake payment gateway, it is only routed when the fake gateway is in use
+func (paymentsHandler *PaymentsHandler) HandleFakePayment(fakeGateway *service.FakeGateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		orderId := r.PathValue("orderId")
+
+		verificationBody, err := fakeGateway.Pay(orderId)
+		if err != nil {
+			if errors.Is(err, service.ErrGatewayOrderNotFound) {
+				paymentsHandler.logger.Error("not found", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			paymentsHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, verificationBody)
+	}
+}
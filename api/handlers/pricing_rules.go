@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ortin779/private_theatre_api/api/ctx"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/service"
+	"go.uber.org/zap"
+)
+
+type PricingRulesHandler struct {
+	logger              *zap.Logger
+	pricingRulesService service.PricingRulesService
+}
+
+func NewPricingRulesHandler(logger *zap.Logger, pricingRulesService service.PricingRulesService) *PricingRulesHandler {
+	return &PricingRulesHandler{
+		logger:              logger,
+		pricingRulesService: pricingRulesService,
+	}
+}
+
+func (rulesHandler *PricingRulesHandler) HandleGetPricingRules() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules, err := rulesHandler.pricingRulesService.GetAll()
+
+		if err != nil {
+			rulesHandler.respondWithPricingRuleError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, rules)
+	}
+}
+
+func (rulesHandler *PricingRulesHandler) HandleGetPricingRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := rulesHandler.pricingRulesService.Get(r.PathValue("id"))
+
+		if err != nil {
+			rulesHandler.respondWithPricingRuleError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, rule)
+	}
+}
+
+func (rulesHandler *PricingRulesHandler) HandleCreatePricingRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ruleParams, userId, ok := rulesHandler.decodePricingRule(w, r)
+		if !ok {
+			return
+		}
+
+		rule, err := rulesHandler.pricingRulesService.Create(ruleParams, userId)
+
+		if err != nil {
+			rulesHandler.respondWithPricingRuleError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusCreated, rule)
+	}
+}
+
+func (rulesHandler *PricingRulesHandler) HandleUpdatePricingRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ruleParams, userId, ok := rulesHandler.decodePricingRule(w, r)
+		if !ok {
+			return
+		}
+
+		rule, err := rulesHandler.pricingRulesService.Update(r.PathValue("id"), ruleParams, userId)
+
+		if err != nil {
+			rulesHandler.respondWithPricingRuleError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, rule)
+	}
+}
+
+func (rulesHandler *PricingRulesHandler) HandleDeletePricingRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := rulesHandler.pricingRulesService.Delete(r.PathValue("id"))
+
+		if err != nil {
+			rulesHandler.respondWithPricingRuleError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodePricingRule reads and validates the rule in the request body, responding with the error when it is not valid
+func (rulesHandler *PricingRulesHandler) decodePricingRule(w http.ResponseWriter, r *http.Request) (models.PricingRuleParams, string, bool) {
+	var ruleParams models.PricingRuleParams
+
+	if err := json.NewDecoder(r.Body).Decode(&ruleParams); err != nil {
+		rulesHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return ruleParams, "", false
+	}
+
+	if errs := ruleParams.Validate(); len(errs) > 0 {
+		rulesHandler.logger.Error("invalid request", zap.Any("errors", errs))
+		RespondWithJson(w, http.StatusBadRequest, errs)
+		return ruleParams, "", false
+	}
+
+	userId, err := ctx.UserIdValue(r.Context())
+	if err != nil {
+		rulesHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return ruleParams, "", false
+	}
+	return ruleParams, userId, true
+}
+
+func (rulesHandler *PricingRulesHandler) respondWithPricingRuleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		rulesHandler.logger.Error("not found", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusNotFound, "no pricing rule found with given id")
+	case errors.Is(err, models.ErrUnknownPricingTarget):
+		rulesHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		rulesHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
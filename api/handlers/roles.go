@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ortin779/private_theatre_api/api/ctx"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/service"
+	"go.uber.org/zap"
+)
+
+type RolesHandler struct {
+	rolesService service.RolesService
+	logger       *zap.Logger
+}
+
+func NewRolesHandler(logger *zap.Logger, rolesService service.RolesService) *RolesHandler {
+	return &RolesHandler{
+		rolesService: rolesService,
+		logger:       logger,
+	}
+}
+
+func (rolesHandler *RolesHandler) HandleGetRoles() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		roles, err := rolesHandler.rolesService.GetAll()
+
+		if err != nil {
+			rolesHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, roles)
+	}
+}
+
+// HandleSaveRole creates the role of the name path value or replaces its permissions
+func (rolesHandler *RolesHandler) HandleSaveRole() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+		if !models.IsRoleNameValid(name) {
+			rolesHandler.logger.Error("invalid request", zap.String("name", name))
+			RespondWithError(w, http.StatusBadRequest, "role name should be 2 to 32 lowercase letters, digits or underscores")
+			return
+		}
+
+		var roleParams models.RoleParams
+
+		err := json.NewDecoder(r.Body).Decode(&roleParams)
+
+		if err != nil {
+			rolesHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errs := roleParams.Validate(); len(errs) > 0 {
+			rolesHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		role, err := rolesHandler.rolesService.Save(name, roleParams, grantorFromRequest(r))
+
+		if err != nil {
+			if errors.Is(err, models.ErrGrantNotHeld) {
+				rolesHandler.logger.Error("forbidden", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			if errors.Is(err, models.ErrAdminRoleFixed) {
+				rolesHandler.logger.Error("conflict", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+				return
+			}
+			rolesHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, role)
+	}
+}
+
+// grantorFromRequest is the logged in user of the request handing out permissions or roles
+func grantorFromRequest(r *http.Request) models.Grantor {
+	return models.Grantor{
+		Roles:       ctx.UserRolesValue(r.Context()),
+		Permissions: ctx.UserPermissionsValue(r.Context()),
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/ortin779/private_theatre_api/api/ctx"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/service"
+	"go.uber.org/zap"
+)
+
+type SchedulesHandler struct {
+	logger           *zap.Logger
+	schedulesService service.SchedulesService
+}
+
+func NewSchedulesHandler(logger *zap.Logger, schedulesService service.SchedulesService) *SchedulesHandler {
+	return &SchedulesHandler{
+		logger:           logger,
+		schedulesService: schedulesService,
+	}
+}
+
+func (schedulesHandler *SchedulesHandler) HandleGetSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schedule, err := schedulesHandler.schedulesService.GetSchedule(r.PathValue("id"))
+
+		if err != nil {
+			schedulesHandler.respondWithScheduleError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, schedule)
+	}
+}
+
+// HandleSetSlotSchedule replaces the rules of the weekdays and dates a slot of the theatre runs on
+func (schedulesHandler *SchedulesHandler) HandleSetSlotSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var scheduleParams models.SlotScheduleParams
+
+		err := json.NewDecoder(r.Body).Decode(&scheduleParams)
+
+		if err != nil {
+			schedulesHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errs := scheduleParams.Validate(); len(errs) > 0 {
+			schedulesHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			schedulesHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		schedule, err := schedulesHandler.schedulesService.SetSlotRules(r.PathValue("id"), r.PathValue("slotId"), scheduleParams, userId)
+
+		if err != nil {
+			schedulesHandler.respondWithScheduleError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, schedule)
+	}
+}
+
+func (schedulesHandler *SchedulesHandler) HandleAddBlackout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var blackoutParams models.BlackoutParams
+
+		err := json.NewDecoder(r.Body).Decode(&blackoutParams)
+
+		if err != nil {
+			schedulesHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errs := blackoutParams.Validate(); len(errs) > 0 {
+			schedulesHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			schedulesHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		blackout, err := schedulesHandler.schedulesService.AddBlackout(r.PathValue("id"), blackoutParams, userId)
+
+		if err != nil {
+			schedulesHandler.respondWithScheduleError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusCreated, blackout)
+	}
+}
+
+func (schedulesHandler *SchedulesHandler) HandleDeleteBlackout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := schedulesHandler.schedulesService.DeleteBlackout(r.PathValue("id"), r.PathValue("blackoutId"))
+
+		if err != nil {
+			schedulesHandler.respondWithScheduleError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (schedulesHandler *SchedulesHandler) respondWithScheduleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		schedulesHandler.logger.Error("not found", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusNotFound, "no theatre or blackout found with given details")
+	case errors.Is(err, models.ErrUnknownSlot):
+		schedulesHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+		RespondWithJson(w, http.StatusBadRequest, map[string]string{"slot_id": err.Error()})
+	case errors.Is(err, models.ErrScheduleHasBookings):
+		schedulesHandler.logger.Error("conflict", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		schedulesHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
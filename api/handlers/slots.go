@@ -1,7 +1,9 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"time"
 
@@ -45,8 +47,8 @@ func (slotsHandler *SlotsHandler) HandleCreateSlot() http.HandlerFunc {
 		err := json.NewDecoder(r.Body).Decode(&createSlotParams)
 
 		if err != nil {
-			slotsHandler.logger.Error("internal server error", zap.String("error", err.Error()))
-			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			slotsHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
 			return
 		}
 
@@ -63,20 +65,16 @@ func (slotsHandler *SlotsHandler) HandleCreateSlot() http.HandlerFunc {
 			return
 		}
 
-		slot := models.Slot{
-			ID:        uuid.New().String(),
-			StartTime: convertMinutesToTime(createSlotParams.StartTime),
-			EndTime:   convertMinutesToTime(createSlotParams.EndTime),
-			CreatedBy: userId,
-			UpdatedBy: userId,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}
+		slot := createSlotParams.Slot()
+		slot.ID = uuid.New().String()
+		slot.CreatedBy = userId
+		slot.UpdatedBy = userId
+		slot.CreatedAt = time.Now()
+		slot.UpdatedAt = time.Now()
 
 		err = slotsHandler.slotsService.AddSlot(slot)
 		if err != nil {
-			slotsHandler.logger.Error("internal server error", zap.String("error", err.Error()))
-			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			slotsHandler.respondWithSlotError(w, err)
 			return
 		}
 
@@ -84,12 +82,83 @@ func (slotsHandler *SlotsHandler) HandleCreateSlot() http.HandlerFunc {
 	}
 }
 
-// a function to convert the given minutes to timestamp. It adds the given number of minutes from midnight
-func convertMinutesToTime(minutes int) time.Time {
-	// Get the current date at midnight
-	now := time.Now()
-	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+func (slotsHandler *SlotsHandler) HandleUpdateSlot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var updateSlotParams models.UpdateSlotParams
+
+		err := json.NewDecoder(r.Body).Decode(&updateSlotParams)
+		if err != nil {
+			slotsHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			slotsHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		slot, errs, err := slotsHandler.slotsService.Update(r.PathValue("id"), updateSlotParams, userId)
+		if err != nil {
+			slotsHandler.respondWithSlotError(w, err)
+			return
+		}
+		if len(errs) > 0 {
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, slot)
+	}
+}
+
+func (slotsHandler *SlotsHandler) HandleDeleteSlot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			slotsHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := slotsHandler.slotsService.Delete(r.PathValue("id"), userId); err != nil {
+			slotsHandler.respondWithSlotError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (slotsHandler *SlotsHandler) respondWithSlotError(w http.ResponseWriter, err error) {
+	var conflictErr *models.SlotConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		slotsHandler.logger.Error("conflict", zap.String("error", err.Error()))
+		respondWithSlotConflict(w, conflictErr)
+	case errors.Is(err, sql.ErrNoRows):
+		slotsHandler.logger.Error("not found", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusNotFound, "no slot found with given id")
+	case errors.Is(err, models.ErrSlotExists), errors.Is(err, models.ErrSlotHasBookings):
+		slotsHandler.logger.Error("conflict", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		slotsHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+	}
+}
+
+type SlotConflictResponse struct {
+	HttpErrorResponse
+	ConflictingSlots []models.Slot `json:"conflicting_slots"`
+}
 
-	// Add the minutes to midnight
-	return midnight.Add(time.Duration(minutes) * time.Minute)
+// respondWithSlotConflict responds with a 409 listing the slots which would overlap
+func respondWithSlotConflict(w http.ResponseWriter, conflictErr *models.SlotConflictError) {
+	RespondWithJson(w, http.StatusConflict, SlotConflictResponse{
+		HttpErrorResponse: HttpErrorResponse{Message: conflictErr.Error()},
+		ConflictingSlots:  conflictErr.Slots,
+	})
 }
@@ -52,6 +52,11 @@ func (thrHandler *TheatreHandler) HandleCreateTheatre() http.HandlerFunc {
 			return
 		}
 
+		timezone := createTheatreParams.Timezone
+		if timezone == "" {
+			timezone = models.DefaultTimezone
+		}
+
 		theatre := models.Theatre{
 			ID:                     uuid.NewString(),
 			Name:                   createTheatreParams.Name,
@@ -61,6 +66,7 @@ func (thrHandler *TheatreHandler) HandleCreateTheatre() http.HandlerFunc {
 			MaxCapacity:            createTheatreParams.MaxCapacity,
 			MinCapacity:            createTheatreParams.MinCapacity,
 			DefaultCapacity:        createTheatreParams.DefaultCapacity,
+			Timezone:               timezone,
 			CreatedBy:              userId,
 			UpdatedBy:              userId,
 			CreatedAt:              time.Now(),
@@ -70,8 +76,7 @@ func (thrHandler *TheatreHandler) HandleCreateTheatre() http.HandlerFunc {
 		err = thrHandler.theatreService.Create(theatre, createTheatreParams.Slots)
 
 		if err != nil {
-			thrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
-			RespondWithError(w, http.StatusInternalServerError, "internal server error")
+			thrHandler.respondWithTheatreError(w, err)
 			return
 		}
 
@@ -119,3 +124,163 @@ func (thrHandler *TheatreHandler) HandleGetTheatreDetails() http.HandlerFunc {
 		RespondWithJson(w, http.StatusOK, theatres)
 	}
 }
+
+func (thrHandler *TheatreHandler) HandleUpdateTheatre() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var updateTheatreParams models.UpdateTheatreParams
+
+		err := json.NewDecoder(r.Body).Decode(&updateTheatreParams)
+
+		if err != nil {
+			thrHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errs := updateTheatreParams.Validate(); len(errs) > 0 {
+			thrHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			thrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		theatre, errs, err := thrHandler.theatreService.Update(r.PathValue("id"), updateTheatreParams, userId)
+
+		if err != nil {
+			thrHandler.respondWithTheatreError(w, err)
+			return
+		}
+		if len(errs) > 0 {
+			thrHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, theatre)
+	}
+}
+
+func (thrHandler *TheatreHandler) HandleAddTheatreSlots() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var slotsParams models.TheatreSlotsParams
+
+		err := json.NewDecoder(r.Body).Decode(&slotsParams)
+
+		if err != nil {
+			thrHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errs := slotsParams.Validate(); len(errs) > 0 {
+			thrHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			thrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		theatre, err := thrHandler.theatreService.AddSlots(r.PathValue("id"), slotsParams.Slots, userId)
+
+		if err != nil {
+			thrHandler.respondWithTheatreError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, theatre)
+	}
+}
+
+// HandleRemoveTheatreSlot takes a slot away from the theatre, which is refused while the slot has upcoming orders
+func (thrHandler *TheatreHandler) HandleRemoveTheatreSlot() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			thrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		theatre, err := thrHandler.theatreService.RemoveSlot(r.PathValue("id"), r.PathValue("slotId"), userId)
+
+		if err != nil {
+			thrHandler.respondWithTheatreError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, theatre)
+	}
+}
+
+// HandleArchiveTheatre hides the theatre and stops its bookings, its past orders are kept
+func (thrHandler *TheatreHandler) HandleArchiveTheatre() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			thrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		theatre, err := thrHandler.theatreService.Archive(r.PathValue("id"), userId)
+
+		if err != nil {
+			thrHandler.respondWithTheatreError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, theatre)
+	}
+}
+
+func (thrHandler *TheatreHandler) HandleRestoreTheatre() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId, err := ctx.UserIdValue(r.Context())
+		if err != nil {
+			thrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		theatre, err := thrHandler.theatreService.Restore(r.PathValue("id"), userId)
+
+		if err != nil {
+			thrHandler.respondWithTheatreError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, theatre)
+	}
+}
+
+func (thrHandler *TheatreHandler) respondWithTheatreError(w http.ResponseWriter, err error) {
+	var conflictErr *models.SlotConflictError
+	switch {
+	case errors.As(err, &conflictErr):
+		thrHandler.logger.Error("conflict", zap.String("error", err.Error()))
+		respondWithSlotConflict(w, conflictErr)
+	case errors.Is(err, sql.ErrNoRows):
+		thrHandler.logger.Error("not found", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusNotFound, "no theatre found with given details")
+	case errors.Is(err, models.ErrUnknownSlot):
+		thrHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+		RespondWithJson(w, http.StatusBadRequest, map[string]string{"slots": err.Error()})
+	case errors.Is(err, models.ErrTheatreHasBookings):
+		thrHandler.logger.Error("conflict", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		thrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/ortin779/private_theatre_api/api/auth"
@@ -14,12 +16,14 @@ import (
 
 type UsersHandler struct {
 	usersService service.UsersService
+	loginService service.LoginService
 	logger       *zap.Logger
 }
 
-func NewUsersHandler(logger *zap.Logger, usersService service.UsersService) *UsersHandler {
+func NewUsersHandler(logger *zap.Logger, usersService service.UsersService, loginService service.LoginService) *UsersHandler {
 	return &UsersHandler{
 		usersService: usersService,
+		loginService: loginService,
 		logger:       logger,
 	}
 }
@@ -50,17 +54,36 @@ func (usrHandler *UsersHandler) HandleCreateUser() http.HandlerFunc {
 			return
 		}
 
+		// admins vouch for the emails of the users they create
+		now := time.Now()
 		user := models.User{
-			ID:       uuid.NewString(),
-			Name:     userParams.Name,
-			Email:    strings.ToLower(userParams.Email),
-			Password: hashedPassword,
-			Roles:    userParams.Roles,
+			ID:              uuid.NewString(),
+			Name:            userParams.Name,
+			Email:           strings.ToLower(userParams.Email),
+			Password:        hashedPassword,
+			Roles:           userParams.Roles,
+			EmailVerifiedAt: &now,
+			CreatedAt:       now,
 		}
 
-		err = usrHandler.usersService.Create(user)
+		err = usrHandler.usersService.Create(user, grantorFromRequest(r))
 
 		if err != nil {
+			if errors.Is(err, models.ErrUnknownRole) {
+				usrHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+				RespondWithJson(w, http.StatusBadRequest, map[string]string{"roles": err.Error()})
+				return
+			}
+			if errors.Is(err, models.ErrGrantNotHeld) {
+				usrHandler.logger.Error("forbidden", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusForbidden, err.Error())
+				return
+			}
+			if errors.Is(err, models.ErrUserAlreadyExists) {
+				usrHandler.logger.Error("conflict", zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusConflict, err.Error())
+				return
+			}
 			usrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
 			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
 			return
@@ -69,3 +92,116 @@ func (usrHandler *UsersHandler) HandleCreateUser() http.HandlerFunc {
 		RespondWithJson(w, http.StatusCreated, user)
 	}
 }
+
+func (usrHandler *UsersHandler) HandleGetAllUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, errs := models.ParseUsersFilter(r.URL.Query())
+		if len(errs) > 0 {
+			usrHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		page, err := usrHandler.usersService.List(filter)
+
+		if err != nil {
+			usrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, page)
+	}
+}
+
+func (usrHandler *UsersHandler) HandleGetUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := usrHandler.usersService.GetByUserId(r.PathValue("id"))
+
+		if err != nil {
+			usrHandler.respondWithUserError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, user)
+	}
+}
+
+func (usrHandler *UsersHandler) HandleUpdateUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var updateParams models.UpdateUserParams
+
+		err := json.NewDecoder(r.Body).Decode(&updateParams)
+
+		if err != nil {
+			usrHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if errs := updateParams.Validate(); len(errs) > 0 {
+			usrHandler.logger.Error("invalid request", zap.Any("errors", errs))
+			RespondWithJson(w, http.StatusBadRequest, errs)
+			return
+		}
+
+		user, err := usrHandler.usersService.Update(r.PathValue("id"), updateParams, grantorFromRequest(r))
+
+		if err != nil {
+			usrHandler.respondWithUserError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, user)
+	}
+}
+
+// HandleDisableUser keeps the user from logging in or refreshing their sessions
+func (usrHandler *UsersHandler) HandleDisableUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := usrHandler.usersService.Disable(r.PathValue("id"))
+
+		if err != nil {
+			usrHandler.respondWithUserError(w, err)
+			return
+		}
+
+		RespondWithJson(w, http.StatusOK, user)
+	}
+}
+
+// HandleUnlockUser forgets the failed logins of the user, so a locked out user can log in again right away
+func (usrHandler *UsersHandler) HandleUnlockUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userId := r.PathValue("id")
+
+		err := usrHandler.loginService.Unlock(userId)
+
+		if err != nil {
+			usrHandler.respondWithUserError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (usrHandler *UsersHandler) respondWithUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, models.ErrNoUserWithId):
+		usrHandler.logger.Error("not found", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, models.ErrUnknownRole):
+		usrHandler.logger.Error("invalid request", zap.String("error", err.Error()))
+		RespondWithJson(w, http.StatusBadRequest, map[string]string{"roles": err.Error()})
+	case errors.Is(err, models.ErrGrantNotHeld):
+		usrHandler.logger.Error("forbidden", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, models.ErrLastAdmin):
+		usrHandler.logger.Error("conflict", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		usrHandler.logger.Error("internal server error", zap.String("error", err.Error()))
+		RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/service"
+	"go.uber.org/zap"
+)
+
+// maxWebhookBodyBytes caps the size of the webhook bodies we are willing to read
+const maxWebhookBodyBytes = 1 << 20
+
+type WebhooksHandler struct {
+	logger          *zap.Logger
+	webhooksService service.WebhooksService
+}
+
+func NewWebhooksHandler(logger *zap.Logger, webhooksService service.WebhooksService) *WebhooksHandler {
+	return &WebhooksHandler{
+		logger:          logger,
+		webhooksService: webhooksService,
+	}
+}
+
+func (webhooksHandler *WebhooksHandler) HandleRazorpayWebhook() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBodyBytes))
+		if err != nil {
+			webhooksHandler.logger.Error("bad request", zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusBadRequest, "unable to read webhook body")
+			return
+		}
+
+		eventId := r.Header.Get("X-Razorpay-Event-Id")
+		signature := r.Header.Get("X-Razorpay-Signature")
+
+		applied, err := webhooksHandler.webhooksService.HandleRazorpayEvent(eventId, body, signature)
+		if errors.Is(err, models.ErrPaymentRefunded) {
+			webhooksHandler.logger.Warn("late payment refunded", zap.String("event_id", eventId), zap.String("error", err.Error()))
+			err = nil
+		}
+		if err != nil {
+			if errors.Is(err, service.ErrWebhookSignatureFailure) {
+				webhooksHandler.logger.Error("unauthorized", zap.String("event_id", eventId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusUnauthorized, "webhook signature is invalid")
+				return
+			}
+			if errors.Is(err, service.ErrInvalidWebhookPayload) {
+				webhooksHandler.logger.Error("bad request", zap.String("event_id", eventId), zap.String("error", err.Error()))
+				RespondWithError(w, http.StatusBadRequest, "webhook payload is invalid")
+				return
+			}
+			webhooksHandler.logger.Error("internal server error", zap.String("event_id", eventId), zap.String("error", err.Error()))
+			RespondWithError(w, http.StatusInternalServerError, "something went wrong")
+			return
+		}
+
+		if !applied {
+			webhooksHandler.logger.Info("duplicate webhook event", zap.String("event_id", eventId))
+		}
+
+		RespondWithJson(w, http.StatusOK, struct {
+			Message string `json:"message"`
+		}{Message: "webhook event processed"})
+	}
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type AccountsConfig struct {
+	VerificationCodeTTL        time.Duration
+	VerificationResendInterval time.Duration
+	MaxVerificationAttempts    int
+	PasswordResetTTL           time.Duration
+}
+
+var (
+	ErrUserAlreadyExists           = errors.New("user already exists with given email")
+	ErrEmailNotVerified            = errors.New("email is not verified yet")
+	ErrEmailAlreadyVerified        = errors.New("email is already verified")
+	ErrInvalidVerificationCode     = errors.New("invalid verification code")
+	ErrVerificationCodeExpired     = errors.New("verification code expired, request a new one")
+	ErrTooManyVerificationAttempts = errors.New("too many attempts with the verification code, request a new one")
+	ErrVerificationResendTooSoon   = errors.New("verification code was sent recently, try again later")
+)
+
+type SignupParams struct {
+	Name        string `json:"name"`
+	Email       string `json:"email"`
+	Password    string `json:"password"`
+	PhoneNumber string `json:"phone_number"`
+}
+
+func (sp SignupParams) Validate() map[string]string {
+	errs := UserParams{
+		Name:     sp.Name,
+		Email:    sp.Email,
+		Password: sp.Password,
+	}.Validate()
+
+	if sp.PhoneNumber != "" && !isPhoneNumberValid(sp.PhoneNumber) {
+		errs["phone_number"] = "invalid phone number"
+	}
+	return errs
+}
+
+type VerifyEmailParams struct {
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+func (vp VerifyEmailParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if !isEmailValid(vp.Email) {
+		errs["email"] = "invalid email format"
+	}
+	if vp.Code == "" {
+		errs["code"] = "verification code can not be empty"
+	}
+	return errs
+}
+
+type ResendVerificationParams struct {
+	Email string `json:"email"`
+}
+
+// ProfileParams are the changes a user can make to their own profile, the fields left out are kept as they are
+type ProfileParams struct {
+	Name        *string `json:"name"`
+	PhoneNumber *string `json:"phone_number"`
+}
+
+func (pp ProfileParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if pp.Name != nil && *pp.Name == "" {
+		errs["name"] = "user name can not be empty"
+	}
+	if pp.PhoneNumber != nil && *pp.PhoneNumber != "" && !isPhoneNumberValid(*pp.PhoneNumber) {
+		errs["phone_number"] = "invalid phone number"
+	}
+	return errs
+}
+
+// EmailVerification is the pending verification code of a user, only its hash is stored
+type EmailVerification struct {
+	UserId    string
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type EmailVerificationResult struct {
+	User           *User `json:"user"`
+	AttachedOrders int   `json:"attached_orders"`
+}
+
+// Message is a notification sent to a user
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
@@ -41,12 +41,16 @@ func (m MetaData) Value() (driver.Value, error) {
 }
 
 func (m *MetaData) Scan(value interface{}) error {
-	b, ok := value.([]byte)
-	if !ok {
-		return errors.New("type assertion to []byte failed")
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case string:
+		return json.Unmarshal([]byte(v), &m)
+	case []byte:
+		return json.Unmarshal(v, &m)
 	}
-
-	return json.Unmarshal(b, &m)
+	return errors.New("type assertion to []byte failed")
 }
 
 type AddonParams struct {
@@ -70,6 +74,57 @@ func (ap *AddonParams) Validate() map[string]string {
 	return errors
 }
 
+// UpdateAddonParams are the changes to an addon, fields left out are kept
+type UpdateAddonParams struct {
+	Name     *string   `json:"name"`
+	Category *string   `json:"category"`
+	Price    *float64  `json:"price"`
+	MetaData *MetaData `json:"meta_data"`
+}
+
+func (uap *UpdateAddonParams) Validate() map[string]string {
+	errors := make(map[string]string)
+	if uap.Name == nil && uap.Category == nil && uap.Price == nil && uap.MetaData == nil {
+		errors["addon"] = "nothing to update"
+	}
+	if uap.Name != nil && *uap.Name == "" {
+		errors["name"] = "addon name can not be empty"
+	}
+	if uap.Price != nil && *uap.Price <= 0 {
+		errors["price"] = "addon price can not be negative"
+	}
+	if uap.Category != nil && !slices.Contains(AddonCategories, *uap.Category) {
+		errors["category"] = "addon category is not valid"
+	}
+	return errors
+}
+
+// Apply returns the addon with the changes
+func (uap *UpdateAddonParams) Apply(addon Addon) Addon {
+	if uap.Name != nil {
+		addon.Name = *uap.Name
+	}
+	if uap.Category != nil {
+		addon.Category = *uap.Category
+	}
+	if uap.Price != nil {
+		addon.Price = *uap.Price
+	}
+	if uap.MetaData != nil {
+		addon.MetaData = *uap.MetaData
+	}
+	return addon
+}
+
+// AddonPrice is a price the addon had from EffectiveFrom until the next price of the addon
+type AddonPrice struct {
+	ID            string    `json:"id"`
+	AddonId       string    `json:"addon_id"`
+	Price         float64   `json:"price"`
+	EffectiveFrom time.Time `json:"effective_from"`
+	CreatedBy     string    `json:"created_by"`
+}
+
 type Addon struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
@@ -80,4 +135,6 @@ type Addon struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	UpdatedBy string    `json:"updated_by"`
 	CreatedBy string    `json:"created_by"`
+	// ArchivedAt is set for the addons which can no longer be ordered, they are kept for their past orders
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
 }
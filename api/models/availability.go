@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxAvailabilityDays limits how many days a single availability lookup can span
+const MaxAvailabilityDays = 62
+
+type AvailabilityParams struct {
+	From time.Time
+	To   time.Time
+}
+
+// ParseAvailabilityParams parses the from and to query values, which are expected in YYYY-MM-DD format
+func ParseAvailabilityParams(from, to string) (AvailabilityParams, map[string]string) {
+	errs := make(map[string]string)
+	var params AvailabilityParams
+
+	fromDate, err := time.Parse(time.DateOnly, from)
+	if err != nil {
+		errs["from"] = "from should be a valid date in YYYY-MM-DD format"
+	}
+	toDate, err := time.Parse(time.DateOnly, to)
+	if err != nil {
+		errs["to"] = "to should be a valid date in YYYY-MM-DD format"
+	}
+	if len(errs) > 0 {
+		return params, errs
+	}
+
+	params.From = fromDate
+	params.To = toDate
+
+	for key, val := range params.Validate() {
+		errs[key] = val
+	}
+	return params, errs
+}
+
+func (ap AvailabilityParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if ap.To.Before(ap.From) {
+		errs["to"] = "to date can not be before from date"
+	}
+	if ap.To.Sub(ap.From) >= MaxAvailabilityDays*24*time.Hour {
+		errs["to"] = fmt.Sprintf("availability can be fetched for at most %d days", MaxAvailabilityDays)
+	}
+	return errs
+}
+
+// BookedSlot is a slot of a theatre which already has an order on the given date
+type BookedSlot struct {
+	SlotId    string
+	OrderDate time.Time
+}
+
+type SlotAvailability struct {
+	Slot
+	Booked bool `json:"booked"`
+	Held   bool `json:"held"`
+}
+
+type DayAvailability struct {
+	Date  string             `json:"date"`
+	Slots []SlotAvailability `json:"slots"`
+}
+
+type TheatreAvailability struct {
+	TheatreId string            `json:"theatre_id"`
+	From      string            `json:"from"`
+	To        string            `json:"to"`
+	Days      []DayAvailability `json:"days"`
+}
@@ -0,0 +1,206 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCoupon   = errors.New("invalid coupon")
+	ErrCouponExhausted = errors.New("coupon has reached its usage limit")
+	ErrCouponExists    = errors.New("a coupon with the same code already exists")
+)
+
+var couponCodeRegex = regexp.MustCompile(`^[A-Z0-9_-]{3,32}$`)
+
+// NormalizeCouponCode returns the code the way coupons are stored, codes are not case sensitive
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Coupon is a discount code. A percent discount can be capped by MaxDiscount, the amounts are in rupees.
+// Coupons scoped to theatres can only be used for them, and coupons scoped to addons only discount those addons.
+type Coupon struct {
+	ID                        string              `json:"id"`
+	Code                      string              `json:"code"`
+	Description               string              `json:"description"`
+	DiscountType              PriceAdjustmentType `json:"discount_type"`
+	Discount                  float64             `json:"discount"`
+	MaxDiscount               *float64            `json:"max_discount"`
+	MinOrderValue             float64             `json:"min_order_value"`
+	ValidFrom                 *time.Time          `json:"valid_from"`
+	ValidTo                   *time.Time          `json:"valid_to"`
+	MaxRedemptions            *int                `json:"max_redemptions"`
+	MaxRedemptionsPerCustomer *int                `json:"max_redemptions_per_customer"`
+	TheatreIds                []string            `json:"theatre_ids"`
+	AddonIds                  []string            `json:"addon_ids"`
+	// Redemptions counts the orders using the coupon which are not expired or cancelled
+	Redemptions int        `json:"redemptions"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CreatedBy   string     `json:"created_by"`
+	UpdatedBy   string     `json:"updated_by"`
+}
+
+// CheckUsable returns ErrInvalidCoupon when the coupon can not be used for the theatre at the time
+func (c Coupon) CheckUsable(theatreId string, now time.Time) error {
+	switch {
+	case c.ArchivedAt != nil:
+		return fmt.Errorf("%w: coupon %s is no longer available", ErrInvalidCoupon, c.Code)
+	case c.ValidFrom != nil && now.Before(*c.ValidFrom):
+		return fmt.Errorf("%w: coupon %s is not valid yet", ErrInvalidCoupon, c.Code)
+	case c.ValidTo != nil && now.After(*c.ValidTo):
+		return fmt.Errorf("%w: coupon %s has expired", ErrInvalidCoupon, c.Code)
+	case c.MaxRedemptions != nil && c.Redemptions >= *c.MaxRedemptions:
+		return fmt.Errorf("%w: coupon %s has been fully redeemed", ErrInvalidCoupon, c.Code)
+	case len(c.TheatreIds) > 0 && !slices.Contains(c.TheatreIds, theatreId):
+		return fmt.Errorf("%w: coupon %s can not be used for the theatre", ErrInvalidCoupon, c.Code)
+	}
+	return nil
+}
+
+// DiscountItem returns the discount of the coupon on the priced items, failing with ErrInvalidCoupon when the
+// order is below the minimum order value or has none of the addons the coupon is for
+func (c Coupon) DiscountItem(items []PriceLineItem) (PriceLineItem, error) {
+	var subtotal, eligible int
+	for _, item := range items {
+		subtotal += item.Amount
+		if len(c.AddonIds) == 0 || (item.Type == AddonPriceItem && slices.Contains(c.AddonIds, item.AddonId)) {
+			eligible += item.Amount
+		}
+	}
+
+	if subtotal < ToPaise(c.MinOrderValue) {
+		return PriceLineItem{}, fmt.Errorf("%w: coupon %s needs an order of at least %.2f", ErrInvalidCoupon, c.Code, c.MinOrderValue)
+	}
+	if eligible <= 0 {
+		return PriceLineItem{}, fmt.Errorf("%w: coupon %s is not for anything in the order", ErrInvalidCoupon, c.Code)
+	}
+
+	discount := ToPaise(c.Discount)
+	if c.DiscountType == PercentAdjustment {
+		discount = int(math.Round(float64(eligible) * c.Discount / 100))
+		if c.MaxDiscount != nil {
+			discount = min(discount, ToPaise(*c.MaxDiscount))
+		}
+	}
+	discount = min(discount, eligible)
+
+	return PriceLineItem{
+		Type:      DiscountItem,
+		Name:      "coupon " + c.Code,
+		CouponId:  c.ID,
+		Quantity:  1,
+		UnitPrice: -discount,
+		Amount:    -discount,
+	}, nil
+}
+
+type CouponParams struct {
+	Code                      string              `json:"code"`
+	Description               string              `json:"description"`
+	DiscountType              PriceAdjustmentType `json:"discount_type"`
+	Discount                  float64             `json:"discount"`
+	MaxDiscount               *float64            `json:"max_discount"`
+	MinOrderValue             float64             `json:"min_order_value"`
+	ValidFrom                 *time.Time          `json:"valid_from"`
+	ValidTo                   *time.Time          `json:"valid_to"`
+	MaxRedemptions            *int                `json:"max_redemptions"`
+	MaxRedemptionsPerCustomer *int                `json:"max_redemptions_per_customer"`
+	TheatreIds                []string            `json:"theatre_ids"`
+	AddonIds                  []string            `json:"addon_ids"`
+}
+
+func (cp CouponParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if !couponCodeRegex.MatchString(NormalizeCouponCode(cp.Code)) {
+		errs["code"] = "code should be 3 to 32 letters, digits, - or _"
+	}
+	switch cp.DiscountType {
+	case PercentAdjustment:
+		if cp.Discount > 100 {
+			errs["discount"] = "a percent discount can not be more than 100"
+		}
+	case FlatAdjustment:
+		if cp.MaxDiscount != nil {
+			errs["max_discount"] = "only percent discounts can have a max discount"
+		}
+	default:
+		errs["discount_type"] = "discount type should be either percent or flat"
+	}
+	if cp.Discount <= 0 {
+		errs["discount"] = "discount should be a positive number"
+	}
+	if cp.MaxDiscount != nil && *cp.MaxDiscount <= 0 {
+		errs["max_discount"] = "max discount should be a positive number"
+	}
+	if cp.MinOrderValue < 0 {
+		errs["min_order_value"] = "min order value can not be negative"
+	}
+	if cp.ValidFrom != nil && cp.ValidTo != nil && !cp.ValidTo.After(*cp.ValidFrom) {
+		errs["valid_to"] = "valid to should be after valid from"
+	}
+	if cp.MaxRedemptions != nil && *cp.MaxRedemptions <= 0 {
+		errs["max_redemptions"] = "max redemptions should be a positive number"
+	}
+	if cp.MaxRedemptionsPerCustomer != nil && *cp.MaxRedemptionsPerCustomer <= 0 {
+		errs["max_redemptions_per_customer"] = "max redemptions per customer should be a positive number"
+	}
+	for _, theatreId := range cp.TheatreIds {
+		if _, err := uuid.Parse(theatreId); err != nil {
+			errs["theatre_ids"] = "theatre id must be a valid uuid"
+			break
+		}
+	}
+	for _, addonId := range cp.AddonIds {
+		if _, err := uuid.Parse(addonId); err != nil {
+			errs["addon_ids"] = "addon id must be a valid uuid"
+			break
+		}
+	}
+	return errs
+}
+
+// Coupon returns the coupon with the code, the discount and the limits of the params
+func (cp CouponParams) Coupon(coupon Coupon) Coupon {
+	coupon.Code = NormalizeCouponCode(cp.Code)
+	coupon.Description = cp.Description
+	coupon.DiscountType = cp.DiscountType
+	coupon.Discount = cp.Discount
+	coupon.MaxDiscount = cp.MaxDiscount
+	coupon.MinOrderValue = cp.MinOrderValue
+	coupon.ValidFrom = cp.ValidFrom
+	coupon.ValidTo = cp.ValidTo
+	coupon.MaxRedemptions = cp.MaxRedemptions
+	coupon.MaxRedemptionsPerCustomer = cp.MaxRedemptionsPerCustomer
+	coupon.TheatreIds = cp.TheatreIds
+	if coupon.TheatreIds == nil {
+		coupon.TheatreIds = []string{}
+	}
+	coupon.AddonIds = cp.AddonIds
+	if coupon.AddonIds == nil {
+		coupon.AddonIds = []string{}
+	}
+	return coupon
+}
+
+// CouponRedemption is the use of a coupon by an order, the discount is in paise
+type CouponRedemption struct {
+	ID            string      `json:"id"`
+	CouponId      string      `json:"coupon_id"`
+	OrderId       string      `json:"order_id"`
+	CustomerEmail string      `json:"customer_email"`
+	UserId        *string     `json:"user_id"`
+	Discount      int         `json:"discount"`
+	RedeemedAt    time.Time   `json:"redeemed_at"`
+	OrderStatus   OrderStatus `json:"order_status"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type HoldConfig struct {
+	TTL           time.Duration
+	SweepInterval time.Duration
+}
+
+var (
+	ErrSlotUnavailable = errors.New("slot is not available for the given date")
+	ErrHoldExpired     = errors.New("slot hold expired before the payment was verified")
+	ErrPaymentRefunded = errors.New("payment is refunded as its order can no longer use it")
+)
+
+// Hold reserves a theatre slot on a date while the customer completes the payment
+type Hold struct {
+	ID        string    `json:"id"`
+	TheatreId string    `json:"theatre_id"`
+	SlotId    string    `json:"slot_id"`
+	OrderDate time.Time `json:"order_date"`
+	OrderId   string    `json:"order_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
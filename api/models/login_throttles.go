@@ -0,0 +1,38 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// LoginThrottleConfig sets how failed logins slow down the next attempts. After FreeAttempts failures every
+// attempt waits twice as long as the previous one, and LockoutAttempts failures lock the login out for
+// LockoutDuration. The limits for an IP address are IPAttemptsFactor times the ones for an email,
+// since many users can share an address. The IP address is read from ClientIPHeader when the API runs
+// behind a proxy setting it, and is the address of the connection otherwise.
+type LoginThrottleConfig struct {
+	FreeAttempts     int
+	LockoutAttempts  int
+	LockoutDuration  time.Duration
+	IPAttemptsFactor int
+	ClientIPHeader   string
+}
+
+// LoginLimits are the limits the failed logins of a single email or IP address are held to
+type LoginLimits struct {
+	FreeAttempts    int
+	LockoutAttempts int
+	BaseDelay       time.Duration
+	LockoutDuration time.Duration
+}
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// LoginThrottledError is returned while the login is slowed down or locked out
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (lte *LoginThrottledError) Error() string {
+	return "too many failed login attempts, try again later"
+}
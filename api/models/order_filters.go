@@ -0,0 +1,167 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultOrdersPageSize = 20
+	MaxOrdersPageSize     = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type OrderSortField string
+
+const (
+	SortByOrderedAt  OrderSortField = "ordered_at"
+	SortByOrderDate  OrderSortField = "order_date"
+	SortByTotalPrice OrderSortField = "total_price"
+)
+
+var orderSortFields = []OrderSortField{SortByOrderedAt, SortByOrderDate, SortByTotalPrice}
+
+// OrdersFilter narrows down and orders the orders listing, the zero values match every order
+type OrdersFilter struct {
+	TheatreId     string
+	SlotId        string
+	From          *time.Time
+	To            *time.Time
+	PaymentStatus PaymentStatus
+	Status        OrderStatus
+	CustomerEmail string
+	PhoneNumber   string
+	// CustomerUserId limits the orders to the ones of the customer, it is set from the logged in user and not the query
+	CustomerUserId string
+	SortBy         OrderSortField
+	Descending     bool
+	Limit          int
+	Cursor         *OrdersCursor
+}
+
+// OrdersCursor points at the last order of a page, the next page starts right after it
+type OrdersCursor struct {
+	SortBy     OrderSortField `json:"s"`
+	Descending bool           `json:"d"`
+	Value      string         `json:"v"`
+	ID         string         `json:"id"`
+}
+
+func (oc OrdersCursor) Encode() string {
+	b, _ := json.Marshal(oc)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeOrdersCursor(cursor string) (*OrdersCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var oc OrdersCursor
+	if err := json.Unmarshal(b, &oc); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(oc.ID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &oc, nil
+}
+
+// ParseOrdersFilter parses the query of the orders listing, dates are expected in YYYY-MM-DD format
+func ParseOrdersFilter(query url.Values) (OrdersFilter, map[string]string) {
+	errs := make(map[string]string)
+	filter := OrdersFilter{
+		TheatreId:     query.Get("theatre_id"),
+		SlotId:        query.Get("slot_id"),
+		PaymentStatus: PaymentStatus(query.Get("payment_status")),
+		Status:        OrderStatus(query.Get("status")),
+		CustomerEmail: query.Get("customer_email"),
+		PhoneNumber:   query.Get("phone_number"),
+		SortBy:        SortByOrderedAt,
+		Descending:    true,
+		Limit:         DefaultOrdersPageSize,
+	}
+
+	if filter.TheatreId != "" {
+		if _, err := uuid.Parse(filter.TheatreId); err != nil {
+			errs["theatre_id"] = "theatre id must be a valid uuid"
+		}
+	}
+	if filter.SlotId != "" {
+		if _, err := uuid.Parse(filter.SlotId); err != nil {
+			errs["slot_id"] = "slot id must be a valid uuid"
+		}
+	}
+	if from := query.Get("from"); from != "" {
+		fromDate, err := time.Parse(time.DateOnly, from)
+		if err != nil {
+			errs["from"] = "from should be a valid date in YYYY-MM-DD format"
+		}
+		filter.From = &fromDate
+	}
+	if to := query.Get("to"); to != "" {
+		toDate, err := time.Parse(time.DateOnly, to)
+		if err != nil {
+			errs["to"] = "to should be a valid date in YYYY-MM-DD format"
+		}
+		filter.To = &toDate
+	}
+	if filter.From != nil && filter.To != nil && filter.To.Before(*filter.From) {
+		errs["to"] = "to date can not be before from date"
+	}
+	if filter.PaymentStatus != "" && !slices.Contains([]PaymentStatus{Success, Failure, Pending, Refunded}, filter.PaymentStatus) {
+		errs["payment_status"] = "payment status must be one of success, failure, pending or refunded"
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		errs["status"] = "invalid order status"
+	}
+
+	if sortBy := query.Get("sort_by"); sortBy != "" {
+		filter.SortBy = OrderSortField(sortBy)
+		if !slices.Contains(orderSortFields, filter.SortBy) {
+			errs["sort_by"] = "sort by must be one of ordered_at, order_date or total_price"
+		}
+	}
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		filter.Descending = false
+	default:
+		errs["order"] = "order must be either asc or desc"
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > MaxOrdersPageSize {
+			errs["limit"] = "limit must be a number between 1 and " + strconv.Itoa(MaxOrdersPageSize)
+		}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		filter.Cursor, err = DecodeOrdersCursor(cursor)
+		if err != nil {
+			errs["cursor"] = err.Error()
+		} else if filter.Cursor.SortBy != filter.SortBy || filter.Cursor.Descending != filter.Descending {
+			errs["cursor"] = "cursor does not match the sort order"
+		}
+	}
+
+	return filter, errs
+}
+
+type OrdersPage struct {
+	Orders     []OrderDetails `json:"orders"`
+	TotalCount int            `json:"total_count"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
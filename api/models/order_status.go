@@ -0,0 +1,65 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+type OrderStatus string
+
+const (
+	OrderPendingPayment OrderStatus = "pending_payment"
+	OrderConfirmed      OrderStatus = "confirmed"
+	OrderCheckedIn      OrderStatus = "checked_in"
+	OrderCompleted      OrderStatus = "completed"
+	OrderCancelled      OrderStatus = "cancelled"
+	OrderExpired        OrderStatus = "expired"
+	OrderRefunded       OrderStatus = "refunded"
+)
+
+// actors recorded in the order history for the changes not made by an admin
+const (
+	SystemActor   = "system"
+	CustomerActor = "customer"
+)
+
+var ErrInvalidOrderTransition = errors.New("order can not move to the requested status")
+
+// orderTransitions lists the statuses every order status can move to
+var orderTransitions = map[OrderStatus][]OrderStatus{
+	OrderPendingPayment: {OrderConfirmed, OrderExpired, OrderCancelled},
+	OrderConfirmed:      {OrderCheckedIn, OrderCancelled},
+	OrderCheckedIn:      {OrderCompleted},
+	OrderCancelled:      {OrderRefunded},
+}
+
+func (os OrderStatus) IsValid() bool {
+	return slices.Contains([]OrderStatus{OrderPendingPayment, OrderConfirmed, OrderCheckedIn, OrderCompleted, OrderCancelled, OrderExpired, OrderRefunded}, os)
+}
+
+func (os OrderStatus) CanTransitionTo(to OrderStatus) bool {
+	return slices.Contains(orderTransitions[os], to)
+}
+
+// HoldsSlot reports whether the order in this status keeps its theatre slot booked
+func (os OrderStatus) HoldsSlot() bool {
+	return os != OrderCancelled && os != OrderExpired && os != OrderRefunded
+}
+
+// OrderTransition is an entry of the order history, FromStatus is empty for the order creation.
+// Changes which keep the status, like reschedules, have the same from and to status and describe the change in Details.
+type OrderTransition struct {
+	ID         string      `json:"id"`
+	OrderId    string      `json:"order_id"`
+	FromStatus OrderStatus `json:"from_status,omitempty"`
+	ToStatus   OrderStatus `json:"to_status"`
+	Actor      string      `json:"actor"`
+	Note       string      `json:"note"`
+	Details    MetaData    `json:"details,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+type OrderTransitionParams struct {
+	Note string `json:"note"`
+}
@@ -12,6 +12,7 @@ type OrderAddon struct {
 	Quantity int    `json:"quantity"`
 }
 
+// OrderAddonDetails is an addon of an order, its Price is the unit price the addon was ordered at
 type OrderAddonDetails struct {
 	Addon
 	Quantity int `json:"quantity"`
@@ -20,42 +21,35 @@ type OrderAddonDetails struct {
 type PaymentStatus string
 
 var (
-	Success PaymentStatus = "success"
-	Failure PaymentStatus = "failure"
-	Pending PaymentStatus = "pending"
+	Success  PaymentStatus = "success"
+	Failure  PaymentStatus = "failure"
+	Pending  PaymentStatus = "pending"
+	Refunded PaymentStatus = "refunded"
 )
 
-type OrderParams struct {
-	CustomerName  string       `json:"customer_name"`
-	CustomerEmail string       `json:"customer_email"`
-	PhoneNumber   string       `json:"phone_number"`
-	TheatreId     string       `json:"theatre_id"`
-	SlotId        string       `json:"slot_id"`
-	NoOfPersons   int          `json:"no_of_persons"`
-	TotalPrice    int          `json:"total_price"`
-	OrderDate     time.Time    `json:"order_date"`
-	Addons        []OrderAddon `json:"addons"`
+// QuoteParams are the booking details needed to price an order
+type QuoteParams struct {
+	TheatreId   string       `json:"theatre_id"`
+	SlotId      string       `json:"slot_id"`
+	NoOfPersons int          `json:"no_of_persons"`
+	OrderDate   time.Time    `json:"order_date"`
+	Addons      []OrderAddon `json:"addons"`
+	CouponCode  string       `json:"coupon_code"`
 }
 
-func (op *OrderParams) Validate() map[string]string {
+func (qp QuoteParams) Validate() map[string]string {
 	errs := make(map[string]string)
 
-	if len(op.PhoneNumber) == 0 {
-		errs["phone_number"] = "phone number can not be empty"
-	}
-	if len(op.CustomerName) == 0 {
-		errs["customer_name"] = "phone number can not be empty"
-	}
-	if !isEmailValid(op.CustomerEmail) {
-		errs["customer_email"] = "invalid email address"
+	if _, err := uuid.Parse(qp.TheatreId); err != nil {
+		errs["theatre_id"] = "theatre id must be a valid uuid"
 	}
-	if _, err := uuid.Parse(op.SlotId); err != nil {
+	if _, err := uuid.Parse(qp.SlotId); err != nil {
 		errs["slot_id"] = "slot id must be a valid uuid"
 	}
-	if op.TotalPrice <= 0 {
-		errs["total_price"] = "order value must be greater than zero"
+	if qp.NoOfPersons <= 0 {
+		errs["no_of_persons"] = "no of persons must be greater than zero"
 	}
-	for _, addon := range op.Addons {
+	for _, addon := range qp.Addons {
 		if _, err := uuid.Parse(addon.ID); err != nil {
 			errs["addons"] = "addon id must be a valid uuid"
 			break
@@ -68,6 +62,32 @@ func (op *OrderParams) Validate() map[string]string {
 	return errs
 }
 
+type OrderParams struct {
+	CustomerName  string `json:"customer_name"`
+	CustomerEmail string `json:"customer_email"`
+	PhoneNumber   string `json:"phone_number"`
+	TotalPrice    int    `json:"total_price"`
+	QuoteParams
+}
+
+func (op *OrderParams) Validate() map[string]string {
+	errs := op.QuoteParams.Validate()
+
+	if len(op.PhoneNumber) == 0 {
+		errs["phone_number"] = "phone number can not be empty"
+	}
+	if len(op.CustomerName) == 0 {
+		errs["customer_name"] = "phone number can not be empty"
+	}
+	if !isEmailValid(op.CustomerEmail) {
+		errs["customer_email"] = "invalid email address"
+	}
+	if op.TotalPrice <= 0 {
+		errs["total_price"] = "order value must be greater than zero"
+	}
+	return errs
+}
+
 func isEmailValid(e string) bool {
 	emailRegex := regexp.MustCompile(`^[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,4}$`)
 	return emailRegex.MatchString(e)
@@ -86,19 +106,45 @@ type OrderDetails struct {
 	Addons         []OrderAddonDetails `json:"addons"`
 	OrderedAt      time.Time           `json:"ordered_at"`
 	PaymentDetails OrderPayment        `json:"payment_details"`
+	PriceBreakdown *PriceBreakdown     `json:"price_breakdown"`
+	CancelledAt    *time.Time          `json:"cancelled_at"`
+	Status         OrderStatus         `json:"status"`
+	UserId         *string             `json:"user_id"`
+	// Payments are the captured payments of the order, the first one and the top ups of its reschedules
+	Payments []CapturedPayment `json:"-"`
+}
+
+// SlotStartsAt returns when the booked slot starts on the order date, in the timezone of the theatre
+func (od OrderDetails) SlotStartsAt() time.Time {
+	return od.Slot.StartsAt(od.OrderDate, od.Theatre.Location())
+}
+
+// AmountPaid returns the amount captured for the order and not refunded, in paise
+func (od OrderDetails) AmountPaid() int {
+	var paid int
+	for _, payment := range od.Payments {
+		paid += payment.Amount - payment.Refunded
+	}
+	return paid
 }
 
 type Order struct {
-	ID              string       `json:"id"`
-	CustomerName    string       `json:"customer_name"`
-	CustomerEmail   string       `json:"customer_email"`
-	PhoneNumber     string       `json:"phone_number"`
-	TheatreId       string       `json:"theatre_id"`
-	SlotId          string       `json:"slot_id"`
-	Addons          []OrderAddon `json:"addons"`
-	NoOfPersons     int          `json:"no_of_persons"`
-	TotalPrice      int          `json:"total_price"`
-	OrderDate       time.Time    `json:"order_date"`
-	OrderedAt       time.Time    `json:"ordered_at"`
-	RazorpayOrderId string       `json:"razorpay_order_id"`
+	ID              string          `json:"id"`
+	CustomerName    string          `json:"customer_name"`
+	CustomerEmail   string          `json:"customer_email"`
+	PhoneNumber     string          `json:"phone_number"`
+	TheatreId       string          `json:"theatre_id"`
+	SlotId          string          `json:"slot_id"`
+	Addons          []OrderAddon    `json:"addons"`
+	NoOfPersons     int             `json:"no_of_persons"`
+	TotalPrice      int             `json:"total_price"`
+	OrderDate       time.Time       `json:"order_date"`
+	OrderedAt       time.Time       `json:"ordered_at"`
+	RazorpayOrderId string          `json:"razorpay_order_id"`
+	Status          OrderStatus     `json:"status"`
+	PriceBreakdown  *PriceBreakdown `json:"price_breakdown"`
+	HoldExpiresAt   *time.Time      `json:"hold_expires_at,omitempty"`
+	AccessToken     string          `json:"access_token,omitempty"`
+	// UserId is set when a logged in customer makes the order, guest orders have none
+	UserId string `json:"user_id,omitempty"`
 }
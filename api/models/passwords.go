@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// MinPasswordLength is the shortest password a user can have
+const MinPasswordLength = 8
+
+var (
+	ErrWrongPassword      = errors.New("current password is not correct")
+	ErrInvalidResetToken  = errors.New("password reset token is invalid or expired")
+	ErrPasswordNotChanged = errors.New("new password must be different from the current password")
+)
+
+type ChangePasswordParams struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+func (cp ChangePasswordParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if cp.CurrentPassword == "" {
+		errs["current_password"] = "current password can not be empty"
+	}
+	if len(cp.NewPassword) < MinPasswordLength {
+		errs["new_password"] = "new password should be at least 8 characters"
+	}
+	return errs
+}
+
+type ForgotPasswordParams struct {
+	Email string `json:"email"`
+}
+
+func (fp ForgotPasswordParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if !isEmailValid(fp.Email) {
+		errs["email"] = "invalid email format"
+	}
+	return errs
+}
+
+type ResetPasswordParams struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"new_password"`
+}
+
+func (rp ResetPasswordParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if rp.Token == "" {
+		errs["token"] = "reset token can not be empty"
+	}
+	if len(rp.NewPassword) < MinPasswordLength {
+		errs["new_password"] = "new password should be at least 8 characters"
+	}
+	return errs
+}
+
+// PasswordReset is a single use token to set a new password, only its hash is stored
+type PasswordReset struct {
+	ID        string
+	UserId    string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
@@ -1,8 +1,21 @@
 package models
 
+const (
+	RazorpayGateway = "razorpay"
+	FakeGateway     = "fake"
+)
+
+// MinPaymentAmount is the smallest amount in paise the gateway takes a payment for
+const MinPaymentAmount = 100
+
+type PaymentsConfig struct {
+	Gateway string
+}
+
 type RazorpayConfig struct {
-	Key    string
-	Secret string
+	Key           string
+	Secret        string
+	WebhookSecret string
 }
 
 type PaymentVerificationBody struct {
@@ -30,3 +43,27 @@ type OrderPayment struct {
 	PaymentVerificationBody
 	Status PaymentStatus `json:"status"`
 }
+
+// GatewayPayment is a payment as reported by the payment gateway, the amount is in paise
+type GatewayPayment struct {
+	ID      string `json:"id"`
+	OrderId string `json:"order_id"`
+	Amount  int    `json:"amount"`
+	Status  string `json:"status"`
+}
+
+// GatewayRefund is a refund as reported by the payment gateway, the amount is in paise
+type GatewayRefund struct {
+	ID        string `json:"id"`
+	PaymentId string `json:"payment_id"`
+	Amount    int    `json:"amount"`
+	Status    string `json:"status"`
+}
+
+// CapturedPayment is a payment collected for an order with how much of it is refunded, the amounts are in paise
+type CapturedPayment struct {
+	RazorpayOrderId   string `json:"razorpay_order_id"`
+	RazorpayPaymentId string `json:"razorpay_payment_id"`
+	Amount            int    `json:"amount"`
+	Refunded          int    `json:"refunded"`
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+)
+
+// permissions checked by the routes, roles are stored as sets of them
+const (
+	TheatresWritePermission = "theatres:write"
+	AddonsWritePermission   = "addons:write"
+	OrdersReadPermission    = "orders:read"
+	OrdersWritePermission   = "orders:write"
+	OrdersCheckInPermission = "orders:check_in"
+	OrdersRefundPermission  = "orders:refund"
+	UsersReadPermission     = "users:read"
+	UsersWritePermission    = "users:write"
+	RolesWritePermission    = "roles:write"
+	ReportsReadPermission   = "reports:read"
+	CouponsWritePermission  = "coupons:write"
+)
+
+var Permissions = []string{
+	TheatresWritePermission,
+	AddonsWritePermission,
+	OrdersReadPermission,
+	OrdersWritePermission,
+	OrdersCheckInPermission,
+	OrdersRefundPermission,
+	UsersReadPermission,
+	UsersWritePermission,
+	RolesWritePermission,
+	ReportsReadPermission,
+	CouponsWritePermission,
+}
+
+var (
+	ErrUnknownRole    = errors.New("unknown role")
+	ErrAdminRoleFixed = errors.New("the admin role always has every permission")
+	ErrGrantNotHeld   = errors.New("can not grant a permission or role you do not hold")
+)
+
+// Grantor is the logged in user handing out permissions or roles, which they can only do with the ones they hold
+type Grantor struct {
+	Roles       []string
+	Permissions []string
+}
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type RoleParams struct {
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+func (roleParams RoleParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if roleParams.Permissions == nil {
+		errs["permissions"] = "permissions can not be empty, send an empty list for a role without permissions"
+	}
+	for _, permission := range roleParams.Permissions {
+		if !slices.Contains(Permissions, permission) {
+			errs["permissions"] = permission + " is not a valid permission"
+			break
+		}
+	}
+	return errs
+}
+
+func IsRoleNameValid(name string) bool {
+	roleNameRegex := regexp.MustCompile(`^[a-z][a-z0-9_]{1,31}$`)
+	return roleNameRegex.MatchString(name)
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"math"
+)
+
+type PricingConfig struct {
+	TaxPercent float64
+}
+
+type PriceItemType string
+
+var (
+	BasePriceItem   PriceItemType = "base"
+	ExtraPersonItem PriceItemType = "extra_person"
+	AddonPriceItem  PriceItemType = "addon"
+	AdjustmentItem  PriceItemType = "adjustment"
+	DiscountItem    PriceItemType = "discount"
+)
+
+// PriceLineItem is a single charge of an order, all the amounts are in paise
+type PriceLineItem struct {
+	Type      PriceItemType `json:"type"`
+	Name      string        `json:"name"`
+	AddonId   string        `json:"addon_id,omitempty"`
+	RuleId    string        `json:"rule_id,omitempty"`
+	CouponId  string        `json:"coupon_id,omitempty"`
+	Quantity  int           `json:"quantity"`
+	UnitPrice int           `json:"unit_price"`
+	Amount    int           `json:"amount"`
+}
+
+// PriceBreakdown is the itemised price of an order, all the amounts are in paise
+type PriceBreakdown struct {
+	Items      []PriceLineItem `json:"items"`
+	Subtotal   int             `json:"subtotal"`
+	TaxPercent float64         `json:"tax_percent"`
+	Tax        int             `json:"tax"`
+	Total      int             `json:"total"`
+	Currency   string          `json:"currency"`
+}
+
+// TotalInRupees returns the total rounded to the nearest rupee, which is how orders store their total price
+func (pb PriceBreakdown) TotalInRupees() int {
+	return int(math.Round(float64(pb.Total) / 100))
+}
+
+// AddonUnitPrice returns the unit price the addon was priced at, in paise
+func (pb PriceBreakdown) AddonUnitPrice(addonId string) (int, bool) {
+	for _, item := range pb.Items {
+		if item.Type == AddonPriceItem && item.AddonId == addonId {
+			return item.UnitPrice, true
+		}
+	}
+	return 0, false
+}
+
+// CouponDiscount returns the discount item of the coupon the order was priced with
+func (pb PriceBreakdown) CouponDiscount() (PriceLineItem, bool) {
+	for _, item := range pb.Items {
+		if item.Type == DiscountItem && item.CouponId != "" {
+			return item, true
+		}
+	}
+	return PriceLineItem{}, false
+}
+
+func (pb PriceBreakdown) Value() (driver.Value, error) {
+	return json.Marshal(pb)
+}
+
+func (pb *PriceBreakdown) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, pb)
+	case string:
+		return json.Unmarshal([]byte(v), pb)
+	default:
+		return errors.New("type assertion to []byte failed")
+	}
+}
+
+// ToPaise converts the rupee amount to paise
+func ToPaise(rupees float64) int {
+	return int(math.Round(rupees * 100))
+}
+
+// ToRupees converts the paise amount to rupees
+func ToRupees(paise int) float64 {
+	return float64(paise) / 100
+}
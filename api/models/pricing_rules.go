@@ -0,0 +1,161 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrUnknownPricingTarget = errors.New("no theatre or slot found for the pricing rule")
+
+type PriceAdjustmentType string
+
+var (
+	PercentAdjustment PriceAdjustmentType = "percent"
+	FlatAdjustment    PriceAdjustmentType = "flat"
+)
+
+// PricingRule changes the theatre charges of the bookings matching all of its conditions, a condition left out
+// matches every booking. Rules are applied one after the other from the highest priority, a percent adjustment
+// applying to the charges as changed by the rules before it, and an exclusive rule stops the rules after it.
+type PricingRule struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	TheatreId *string `json:"theatre_id"`
+	SlotId    *string `json:"slot_id"`
+	// Weekdays are the days of the order date, 0 being Sunday
+	Weekdays  []int   `json:"weekdays"`
+	ValidFrom *string `json:"valid_from"`
+	ValidTo   *string `json:"valid_to"`
+	// MinLeadDays and MaxLeadDays bound the days from the day of booking to the order date
+	MinLeadDays    *int                `json:"min_lead_days"`
+	MaxLeadDays    *int                `json:"max_lead_days"`
+	AdjustmentType PriceAdjustmentType `json:"adjustment_type"`
+	// Adjustment is the percent or the amount in rupees added to the charges, negative for discounts
+	Adjustment float64   `json:"adjustment"`
+	Priority   int       `json:"priority"`
+	Exclusive  bool      `json:"exclusive"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+	CreatedBy  string    `json:"created_by"`
+	UpdatedBy  string    `json:"updated_by"`
+}
+
+// Matches reports whether the booking of the theatre slot on the order date, made leadDays before it,
+// meets all the conditions of the rule
+func (pr PricingRule) Matches(theatreId, slotId string, orderDate time.Time, leadDays int) bool {
+	day := orderDate.Format(time.DateOnly)
+	switch {
+	case pr.TheatreId != nil && *pr.TheatreId != theatreId,
+		pr.SlotId != nil && *pr.SlotId != slotId,
+		len(pr.Weekdays) > 0 && !slices.Contains(pr.Weekdays, int(orderDate.Weekday())),
+		pr.ValidFrom != nil && day < *pr.ValidFrom,
+		pr.ValidTo != nil && day > *pr.ValidTo,
+		pr.MinLeadDays != nil && leadDays < *pr.MinLeadDays,
+		pr.MaxLeadDays != nil && leadDays > *pr.MaxLeadDays:
+		return false
+	}
+	return true
+}
+
+// AdjustmentFor returns the change the rule makes to the charges, in paise. The charges never go below zero.
+func (pr PricingRule) AdjustmentFor(charges int) int {
+	adjustment := ToPaise(pr.Adjustment)
+	if pr.AdjustmentType == PercentAdjustment {
+		adjustment = int(math.Round(float64(charges) * pr.Adjustment / 100))
+	}
+	return max(adjustment, -charges)
+}
+
+type PricingRuleParams struct {
+	Name           string              `json:"name"`
+	TheatreId      *string             `json:"theatre_id"`
+	SlotId         *string             `json:"slot_id"`
+	Weekdays       []int               `json:"weekdays"`
+	ValidFrom      *string             `json:"valid_from"`
+	ValidTo        *string             `json:"valid_to"`
+	MinLeadDays    *int                `json:"min_lead_days"`
+	MaxLeadDays    *int                `json:"max_lead_days"`
+	AdjustmentType PriceAdjustmentType `json:"adjustment_type"`
+	Adjustment     float64             `json:"adjustment"`
+	Priority       int                 `json:"priority"`
+	Exclusive      bool                `json:"exclusive"`
+}
+
+func (prp PricingRuleParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if prp.Name == "" {
+		errs["name"] = "name of the pricing rule can not be empty"
+	}
+	if prp.TheatreId != nil {
+		if _, err := uuid.Parse(*prp.TheatreId); err != nil {
+			errs["theatre_id"] = "theatre id must be a valid uuid"
+		}
+	}
+	if prp.SlotId != nil {
+		if _, err := uuid.Parse(*prp.SlotId); err != nil {
+			errs["slot_id"] = "slot id must be a valid uuid"
+		}
+	}
+	for _, weekday := range prp.Weekdays {
+		if weekday < int(time.Sunday) || weekday > int(time.Saturday) {
+			errs["weekdays"] = "weekdays should be between 0 for sunday and 6 for saturday"
+			break
+		}
+	}
+	if prp.ValidFrom != nil && !isDateValid(*prp.ValidFrom) {
+		errs["valid_from"] = "valid from should be a valid date in YYYY-MM-DD format"
+	}
+	if prp.ValidTo != nil && !isDateValid(*prp.ValidTo) {
+		errs["valid_to"] = "valid to should be a valid date in YYYY-MM-DD format"
+	}
+	if prp.ValidFrom != nil && prp.ValidTo != nil && *prp.ValidTo < *prp.ValidFrom {
+		errs["valid_to"] = "valid to can not be before valid from"
+	}
+	if prp.MinLeadDays != nil && *prp.MinLeadDays < 0 {
+		errs["min_lead_days"] = "min lead days can not be negative"
+	}
+	if prp.MaxLeadDays != nil && *prp.MaxLeadDays < 0 {
+		errs["max_lead_days"] = "max lead days can not be negative"
+	}
+	if prp.MinLeadDays != nil && prp.MaxLeadDays != nil && *prp.MaxLeadDays < *prp.MinLeadDays {
+		errs["max_lead_days"] = "max lead days can not be less than min lead days"
+	}
+	switch prp.AdjustmentType {
+	case PercentAdjustment:
+		if prp.Adjustment <= -100 {
+			errs["adjustment"] = "a percent adjustment has to take off less than 100 percent"
+		}
+	case FlatAdjustment:
+	default:
+		errs["adjustment_type"] = "adjustment type should be either percent or flat"
+	}
+	if prp.Adjustment == 0 {
+		errs["adjustment"] = "adjustment can not be zero"
+	}
+	return errs
+}
+
+// Rule returns the pricing rule with the conditions and the adjustment of the params
+func (prp PricingRuleParams) Rule(rule PricingRule) PricingRule {
+	rule.Name = prp.Name
+	rule.TheatreId = prp.TheatreId
+	rule.SlotId = prp.SlotId
+	rule.Weekdays = prp.Weekdays
+	if rule.Weekdays == nil {
+		rule.Weekdays = []int{}
+	}
+	rule.ValidFrom = prp.ValidFrom
+	rule.ValidTo = prp.ValidTo
+	rule.MinLeadDays = prp.MinLeadDays
+	rule.MaxLeadDays = prp.MaxLeadDays
+	rule.AdjustmentType = prp.AdjustmentType
+	rule.Adjustment = prp.Adjustment
+	rule.Priority = prp.Priority
+	rule.Exclusive = prp.Exclusive
+	return rule
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrOrderAlreadyCancelled = errors.New("order is already cancelled")
+	ErrOrderAlreadyStarted   = errors.New("order can not be cancelled once its slot has started")
+	ErrRefundPending         = errors.New("refund could not be made and is pending")
+)
+
+// CancellationPolicy decides how much of the payment is refunded, based on how long before
+// the slot starts the order is cancelled
+type CancellationPolicy struct {
+	FullRefundHours      int
+	NoRefundHours        int
+	PartialRefundPercent int
+}
+
+// RefundPercent returns the percentage of the payment to refund when cancelling the given time before the slot
+func (cp CancellationPolicy) RefundPercent(beforeSlot time.Duration) int {
+	switch {
+	case beforeSlot >= time.Duration(cp.FullRefundHours)*time.Hour:
+		return 100
+	case beforeSlot < time.Duration(cp.NoRefundHours)*time.Hour:
+		return 0
+	default:
+		return cp.PartialRefundPercent
+	}
+}
+
+// RefundProcessed is the status of the refunds which reached the customer
+const RefundProcessed = "processed"
+
+// Refund is money returned for an order's payment, the amount is in paise
+type Refund struct {
+	ID                string    `json:"id"`
+	OrderId           string    `json:"order_id"`
+	RazorpayOrderId   string    `json:"razorpay_order_id"`
+	RazorpayPaymentId string    `json:"razorpay_payment_id"`
+	Amount            int       `json:"amount"`
+	Status            string    `json:"status"`
+	RefundRequestId   string    `json:"refund_request_id,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+// RefundRequest is an amount owed back for an order, refunded from its payments under the request id.
+// It is recorded along with what owes it, so a refund failing half way can be retried for what is left.
+// The amounts are in paise.
+type RefundRequest struct {
+	ID        string    `json:"id"`
+	OrderId   string    `json:"order_id"`
+	Amount    int       `json:"amount"`
+	Refunds   []Refund  `json:"refunds"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Outstanding returns how much of the request is yet to be refunded
+func (rr RefundRequest) Outstanding() int {
+	outstanding := rr.Amount
+	for _, refund := range rr.Refunds {
+		outstanding -= refund.Amount
+	}
+	return max(outstanding, 0)
+}
+
+type Cancellation struct {
+	OrderId       string    `json:"order_id"`
+	CancelledAt   time.Time `json:"cancelled_at"`
+	RefundPercent int       `json:"refund_percent"`
+	Refunds       []Refund  `json:"refunds,omitempty"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrOrderNotReschedulable = errors.New("only confirmed orders whose slot has not started can be rescheduled")
+	ErrRescheduleFailed      = errors.New("order could not be moved to the rescheduled booking")
+)
+
+type RescheduleStatus string
+
+const (
+	ReschedulePendingPayment RescheduleStatus = "pending_payment"
+	RescheduleApplied        RescheduleStatus = "applied"
+	RescheduleFailed         RescheduleStatus = "failed"
+)
+
+type RescheduleParams struct {
+	TheatreId string    `json:"theatre_id"`
+	SlotId    string    `json:"slot_id"`
+	OrderDate time.Time `json:"order_date"`
+}
+
+func (rp RescheduleParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if rp.TheatreId != "" {
+		if _, err := uuid.Parse(rp.TheatreId); err != nil {
+			errs["theatre_id"] = "theatre id must be a valid uuid"
+		}
+	}
+	if _, err := uuid.Parse(rp.SlotId); err != nil {
+		errs["slot_id"] = "slot id must be a valid uuid"
+	}
+	today := time.Now().Format(time.DateOnly)
+	if rp.OrderDate.Format(time.DateOnly) < today {
+		errs["order_date"] = "order date can not be in the past"
+	}
+	return errs
+}
+
+// Booking is where and when an order takes place
+type Booking struct {
+	TheatreId string    `json:"theatre_id"`
+	SlotId    string    `json:"slot_id"`
+	OrderDate time.Time `json:"order_date"`
+}
+
+// Reschedule moves an order to another booking. A positive price difference is collected through
+// the top up razorpay order and the order only moves once it is paid, the new booking is held until then.
+// A negative one is refunded right away, a refund which could not be made is left pending. The amounts are in paise.
+type Reschedule struct {
+	ID                   string           `json:"id"`
+	OrderId              string           `json:"order_id"`
+	From                 Booking          `json:"from"`
+	To                   Booking          `json:"to"`
+	Status               RescheduleStatus `json:"status"`
+	PriceDifference      int              `json:"price_difference"`
+	PriceBreakdown       *PriceBreakdown  `json:"price_breakdown"`
+	TopUpRazorpayOrderId string           `json:"top_up_razorpay_order_id,omitempty"`
+	HoldExpiresAt        *time.Time       `json:"hold_expires_at,omitempty"`
+	Refunds              []Refund         `json:"refunds,omitempty"`
+	RefundPending        bool             `json:"refund_pending,omitempty"`
+	Actor                string           `json:"actor"`
+	CreatedAt            time.Time        `json:"created_at"`
+}
@@ -0,0 +1,163 @@
+package models
+
+import (
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrScheduleHasBookings = errors.New("theatre has bookings on the dates the schedule closes")
+	ErrSlotClosed          = errors.New("slot is closed on the date")
+)
+
+// ScheduleRule runs a slot of a theatre on the weekdays, 0 being Sunday, between the valid dates when they are given.
+// Dates are in YYYY-MM-DD format.
+type ScheduleRule struct {
+	ID        string    `json:"id"`
+	TheatreId string    `json:"theatre_id"`
+	SlotId    string    `json:"slot_id"`
+	Weekdays  []int     `json:"weekdays"`
+	ValidFrom *string   `json:"valid_from"`
+	ValidTo   *string   `json:"valid_to"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+}
+
+// Matches reports whether the rule runs its slot on the date
+func (sr ScheduleRule) Matches(date time.Time) bool {
+	day := date.Format(time.DateOnly)
+	if sr.ValidFrom != nil && day < *sr.ValidFrom {
+		return false
+	}
+	if sr.ValidTo != nil && day > *sr.ValidTo {
+		return false
+	}
+	return slices.Contains(sr.Weekdays, int(date.Weekday()))
+}
+
+// RulesMatch reports whether the slot with the rules runs on the date, a slot without rules runs every day
+func RulesMatch(rules []ScheduleRule, date time.Time) bool {
+	if len(rules) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(rules, func(rule ScheduleRule) bool { return rule.Matches(date) })
+}
+
+// Blackout closes a theatre, or only one of its slots, from FromDate to ToDate, both included
+type Blackout struct {
+	ID        string `json:"id"`
+	TheatreId string `json:"theatre_id"`
+	// SlotId is the slot which is closed, every slot of the theatre is closed when it is nil
+	SlotId    *string   `json:"slot_id"`
+	FromDate  string    `json:"from_date"`
+	ToDate    string    `json:"to_date"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+}
+
+// Covers reports whether the blackout closes the slot on the date
+func (b Blackout) Covers(slotId string, date time.Time) bool {
+	day := date.Format(time.DateOnly)
+	if day < b.FromDate || day > b.ToDate {
+		return false
+	}
+	return b.SlotId == nil || *b.SlotId == slotId
+}
+
+// TheatreSchedule is when the slots of a theatre can be booked
+type TheatreSchedule struct {
+	TheatreId string         `json:"theatre_id"`
+	Rules     []ScheduleRule `json:"rules"`
+	Blackouts []Blackout     `json:"blackouts"`
+}
+
+// Runs reports whether the slot can be booked on the date, it has to match one of its rules
+// when it has any and must not be blacked out
+func (ts TheatreSchedule) Runs(slotId string, date time.Time) bool {
+	var slotRules []ScheduleRule
+	for _, rule := range ts.Rules {
+		if rule.SlotId == slotId {
+			slotRules = append(slotRules, rule)
+		}
+	}
+	if !RulesMatch(slotRules, date) {
+		return false
+	}
+	return !slices.ContainsFunc(ts.Blackouts, func(blackout Blackout) bool { return blackout.Covers(slotId, date) })
+}
+
+type ScheduleRuleParams struct {
+	Weekdays  []int   `json:"weekdays"`
+	ValidFrom *string `json:"valid_from"`
+	ValidTo   *string `json:"valid_to"`
+}
+
+// SlotScheduleParams replace the rules of a slot, a slot without rules runs every day
+type SlotScheduleParams struct {
+	Rules []ScheduleRuleParams `json:"rules"`
+}
+
+func (ssp SlotScheduleParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	for _, rule := range ssp.Rules {
+		if len(rule.Weekdays) == 0 {
+			errs["weekdays"] = "every rule should run on at least one weekday"
+		}
+		for _, weekday := range rule.Weekdays {
+			if weekday < int(time.Sunday) || weekday > int(time.Saturday) {
+				errs["weekdays"] = "weekdays should be between 0 for sunday and 6 for saturday"
+				break
+			}
+		}
+		if rule.ValidFrom != nil && !isDateValid(*rule.ValidFrom) {
+			errs["valid_from"] = "valid from should be a valid date in YYYY-MM-DD format"
+		}
+		if rule.ValidTo != nil && !isDateValid(*rule.ValidTo) {
+			errs["valid_to"] = "valid to should be a valid date in YYYY-MM-DD format"
+		}
+		if rule.ValidFrom != nil && rule.ValidTo != nil && *rule.ValidTo < *rule.ValidFrom {
+			errs["valid_to"] = "valid to can not be before valid from"
+		}
+	}
+	return errs
+}
+
+type BlackoutParams struct {
+	SlotId   *string `json:"slot_id"`
+	FromDate string  `json:"from_date"`
+	ToDate   string  `json:"to_date"`
+	Reason   string  `json:"reason"`
+}
+
+func (bp BlackoutParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if bp.SlotId != nil {
+		if _, err := uuid.Parse(*bp.SlotId); err != nil {
+			errs["slot_id"] = "slot id must be a valid uuid"
+		}
+	}
+	if !isDateValid(bp.FromDate) {
+		errs["from_date"] = "from date should be a valid date in YYYY-MM-DD format"
+	}
+	if !isDateValid(bp.ToDate) {
+		errs["to_date"] = "to date should be a valid date in YYYY-MM-DD format"
+	}
+	if len(errs) == 0 && bp.ToDate < bp.FromDate {
+		errs["to_date"] = "to date can not be before from date"
+	}
+	if bp.Reason == "" {
+		errs["reason"] = "reason can not be empty"
+	}
+	return errs
+}
+
+func isDateValid(date string) bool {
+	_, err := time.Parse(time.DateOnly, date)
+	return err == nil
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type SessionsConfig struct {
+	RefreshTokenTTL time.Duration
+}
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used, all the sessions it started are logged out")
+)
+
+// RefreshToken is a single use token, only its hash is stored. Every refresh replaces the token with a new one
+// of the same family, so a reused token reveals the family was leaked.
+type RefreshToken struct {
+	ID        string
+	UserId    string
+	FamilyId  string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+type RefreshTokenParams struct {
+	RefreshToken string `json:"refresh_token"`
+}
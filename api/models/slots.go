@@ -1,7 +1,10 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 )
 
@@ -10,38 +13,195 @@ const (
 	MaxTime = 1440
 )
 
+var (
+	ErrSlotExists      = errors.New("a slot with the same start and end time already exists")
+	ErrSlotHasBookings = errors.New("slot has upcoming bookings")
+)
+
+// TimeOfDay is a local time of day in minutes from midnight, it is written out both in minutes and as HH:MM
+type TimeOfDay int
+
+// ParseTimeOfDay parses the HH:MM time of day, 24:00 being the midnight at the end of the day
+func ParseTimeOfDay(value string) (TimeOfDay, error) {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(value, "%2d:%2d", &hours, &minutes); err != nil || len(value) != len("15:04") {
+		return 0, fmt.Errorf("invalid time of day %q, it should be HH:MM", value)
+	}
+	if minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid time of day %q, minutes should be between 0 and 59", value)
+	}
+	return TimeOfDay(hours*60 + minutes), nil
+}
+
+func (t TimeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", t/60, t%60)
+}
+
+// Normalize turns the midnight at the end of the day, 24:00, into 00:00
+func (t TimeOfDay) Normalize() TimeOfDay {
+	return t % MaxTime
+}
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Minutes int    `json:"minutes"`
+		Time    string `json:"time"`
+	}{int(t), t.String()})
+}
+
+// UnmarshalJSON reads the time of day given in minutes from midnight or as HH:MM
+func (t *TimeOfDay) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		parsed, err := ParseTimeOfDay(value)
+		if err != nil {
+			return err
+		}
+		*t = parsed
+		return nil
+	}
+
+	var minutes int
+	if err := json.Unmarshal(data, &minutes); err != nil {
+		return fmt.Errorf("time of day should be minutes from midnight or HH:MM: %w", err)
+	}
+	*t = TimeOfDay(minutes)
+	return nil
+}
+
+// Slot is a local time of day range, it is booked for a date in the timezone of the theatre.
+// A slot ending at or before its start time crosses midnight and ends on the next day.
 type Slot struct {
 	ID        string    `json:"id"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
+	StartTime TimeOfDay `json:"start_time"`
+	EndTime   TimeOfDay `json:"end_time"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	UpdatedBy string    `json:"updated_by"`
 	CreatedBy string    `json:"created_by"`
+	// DeletedAt is set for the slots which were deleted, they are kept for their past orders
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// CrossesMidnight reports whether the slot ends on the day after it starts
+func (s Slot) CrossesMidnight() bool {
+	return s.EndTime <= s.StartTime
+}
+
+// Minutes is the length of the slot in minutes
+func (s Slot) Minutes() int {
+	return int((s.EndTime - s.StartTime + MaxTime) % MaxTime)
+}
+
+// StartsAt returns when the slot starts on the date in the location
+func (s Slot) StartsAt(date time.Time, loc *time.Location) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), int(s.StartTime/60), int(s.StartTime%60), 0, 0, loc)
+}
+
+// EndsAt returns when the slot booked for the date ends in the location
+func (s Slot) EndsAt(date time.Time, loc *time.Location) time.Time {
+	if s.CrossesMidnight() {
+		date = date.AddDate(0, 0, 1)
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), int(s.EndTime/60), int(s.EndTime%60), 0, 0, loc)
+}
+
+// Overlaps reports whether the slots share any time on the same or on consecutive days,
+// slots where one ends as the other starts do not overlap
+func (s Slot) Overlaps(other Slot) bool {
+	start, otherStart := int(s.StartTime), int(other.StartTime)
+	for _, dayShift := range []int{-MaxTime, 0, MaxTime} {
+		shiftedStart := otherStart + dayShift
+		if start < shiftedStart+other.Minutes() && shiftedStart < start+s.Minutes() {
+			return true
+		}
+	}
+	return false
+}
+
+// FindOverlaps returns the slots of every overlapping pair between the added slots, or between an added slot and
+// an existing one, ordered by their start time. Overlaps among the existing slots are left alone.
+func FindOverlaps(existing, added []Slot) []Slot {
+	var conflicts []Slot
+	addConflict := func(slot Slot) {
+		if !slices.ContainsFunc(conflicts, func(conflict Slot) bool { return conflict.ID == slot.ID }) {
+			conflicts = append(conflicts, slot)
+		}
+	}
+
+	for i, slot := range added {
+		for _, other := range append(slices.Clone(existing), added[i+1:]...) {
+			if slot.ID != other.ID && slot.Overlaps(other) {
+				addConflict(slot)
+				addConflict(other)
+			}
+		}
+	}
+
+	slices.SortFunc(conflicts, func(a, b Slot) int { return int(a.StartTime - b.StartTime) })
+	return conflicts
+}
+
+// SlotConflictError is returned when slots of a theatre would overlap, it lists the overlapping slots
+type SlotConflictError struct {
+	Slots []Slot
+}
+
+func (sce *SlotConflictError) Error() string {
+	return "slots of a theatre can not overlap"
 }
 
+// CreateSlotParams are the times of a slot, in minutes from midnight or as HH:MM. An end time at or before
+// the start time makes the slot cross midnight.
 type CreateSlotParams struct {
-	StartTime int `json:"start_time"`
-	EndTime   int `json:"end_time"`
+	StartTime TimeOfDay `json:"start_time"`
+	EndTime   TimeOfDay `json:"end_time"`
 }
 
 func (csp CreateSlotParams) Validate() map[string]string {
 	errs := map[string]string{}
-	if csp.StartTime < MinTime {
-		errs["start_time"] = fmt.Sprintf("start time should be minimum of %d", MinTime)
+	if csp.StartTime < MinTime || csp.StartTime >= MaxTime {
+		errs["start_time"] = fmt.Sprintf("start time should be between %d and %d", MinTime, MaxTime-1)
 	}
-	if csp.StartTime > MaxTime {
-		errs["start_time"] = fmt.Sprintf("start time should be maximum of %d", MaxTime)
+	if csp.EndTime < MinTime || csp.EndTime > MaxTime {
+		errs["end_time"] = fmt.Sprintf("end time should be between %d and %d", MinTime, MaxTime)
 	}
-	if csp.EndTime < MinTime {
-		errs["start_time"] = fmt.Sprintf("start time should be minimum of %d", MinTime)
+	if len(errs) == 0 && csp.StartTime == csp.EndTime.Normalize() {
+		errs["end_time"] = fmt.Sprintf("end time: %s, should be different from start time: %s", csp.EndTime, csp.StartTime)
 	}
-	if csp.EndTime > MaxTime {
-		errs["start_time"] = fmt.Sprintf("start time should be maximum of %d", MaxTime)
+
+	return errs
+}
+
+// Slot returns the slot with the times, ending at 00:00 instead of 24:00
+func (csp CreateSlotParams) Slot() Slot {
+	return Slot{StartTime: csp.StartTime, EndTime: csp.EndTime.Normalize()}
+}
+
+// UpdateSlotParams are the new times of a slot, a time left out is kept
+type UpdateSlotParams struct {
+	StartTime *TimeOfDay `json:"start_time"`
+	EndTime   *TimeOfDay `json:"end_time"`
+}
+
+// Apply returns the slot with the new times, validated like the times of a new slot
+func (usp UpdateSlotParams) Apply(slot Slot) (Slot, map[string]string) {
+	if usp.StartTime == nil && usp.EndTime == nil {
+		return slot, map[string]string{"slot": "nothing to update"}
+	}
+
+	times := CreateSlotParams{StartTime: slot.StartTime, EndTime: slot.EndTime}
+	if usp.StartTime != nil {
+		times.StartTime = *usp.StartTime
+	}
+	if usp.EndTime != nil {
+		times.EndTime = *usp.EndTime
 	}
-	if csp.StartTime >= csp.EndTime {
-		errs["start_time"] = fmt.Sprintf("start time: %d, should be lessthan endtime: %d", csp.StartTime, csp.EndTime)
+	if errs := times.Validate(); len(errs) > 0 {
+		return slot, errs
 	}
 
-	return errs
+	updated := times.Slot()
+	slot.StartTime, slot.EndTime = updated.StartTime, updated.EndTime
+	return slot, nil
 }
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+// testSlot builds the slot with the HH:MM times the way a new slot is made, 24:00 ending at 00:00
+func testSlot(t *testing.T, id, start, end string) Slot {
+	t.Helper()
+
+	startTime, err := ParseTimeOfDay(start)
+	if err != nil {
+		t.Fatalf("parse start time: %v", err)
+	}
+	endTime, err := ParseTimeOfDay(end)
+	if err != nil {
+		t.Fatalf("parse end time: %v", err)
+	}
+	slot := CreateSlotParams{StartTime: startTime, EndTime: endTime}.Slot()
+	slot.ID = id
+	return slot
+}
+
+func TestSlotOverlaps(t *testing.T) {
+	tests := []struct {
+		name     string
+		slot     [2]string
+		other    [2]string
+		overlaps bool
+	}{
+		{"same day overlapping", [2]string{"10:00", "13:00"}, [2]string{"12:00", "15:00"}, true},
+		{"same day apart", [2]string{"10:00", "12:00"}, [2]string{"13:00", "15:00"}, false},
+		{"same day contained", [2]string{"10:00", "16:00"}, [2]string{"12:00", "13:00"}, true},
+		{"same times", [2]string{"10:00", "13:00"}, [2]string{"10:00", "13:00"}, true},
+		{"back to back", [2]string{"10:00", "13:00"}, [2]string{"13:00", "16:00"}, false},
+		{"cross midnight into the next morning", [2]string{"22:30", "01:00"}, [2]string{"00:30", "02:00"}, true},
+		{"cross midnight back to back with the next morning", [2]string{"22:00", "01:00"}, [2]string{"01:00", "03:00"}, false},
+		{"cross midnight back to back with the evening", [2]string{"22:00", "01:00"}, [2]string{"20:00", "22:00"}, false},
+		{"cross midnight overlapping the evening", [2]string{"22:00", "01:00"}, [2]string{"21:00", "23:00"}, true},
+		{"both cross midnight", [2]string{"23:00", "02:00"}, [2]string{"22:00", "00:30"}, true},
+		{"ending at 24:00 back to back with the next morning", [2]string{"21:00", "24:00"}, [2]string{"00:00", "02:00"}, false},
+		{"ending at 24:00 back to back with the evening", [2]string{"21:00", "24:00"}, [2]string{"18:00", "21:00"}, false},
+		{"ending at 24:00 overlapping a cross midnight slot", [2]string{"21:00", "24:00"}, [2]string{"23:00", "01:00"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			slot := testSlot(t, "slot", tt.slot[0], tt.slot[1])
+			other := testSlot(t, "other", tt.other[0], tt.other[1])
+
+			if got := slot.Overlaps(other); got != tt.overlaps {
+				t.Errorf("expected %s-%s overlapping %s-%s to be %v, got %v", slot.StartTime, slot.EndTime, other.StartTime, other.EndTime, tt.overlaps, got)
+			}
+			if got := other.Overlaps(slot); got != tt.overlaps {
+				t.Errorf("expected %s-%s overlapping %s-%s to be %v, got %v", other.StartTime, other.EndTime, slot.StartTime, slot.EndTime, tt.overlaps, got)
+			}
+		})
+	}
+}
+
+func TestFindOverlaps(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  [][3]string
+		added     [][3]string
+		conflicts []string
+	}{
+		{
+			name:     "back to back",
+			existing: [][3]string{{"a", "10:00", "13:00"}},
+			added:    [][3]string{{"b", "13:00", "16:00"}},
+		},
+		{
+			name:      "added across midnight",
+			existing:  [][3]string{{"a", "22:30", "01:00"}, {"c", "10:00", "12:00"}},
+			added:     [][3]string{{"b", "00:30", "02:00"}},
+			conflicts: []string{"b", "a"},
+		},
+		{
+			name:      "among the added",
+			added:     [][3]string{{"b", "10:00", "13:00"}, {"d", "12:00", "14:00"}, {"e", "21:00", "24:00"}},
+			conflicts: []string{"b", "d"},
+		},
+		{
+			name:     "among the existing",
+			existing: [][3]string{{"a", "10:00", "13:00"}, {"c", "12:00", "14:00"}},
+			added:    [][3]string{{"b", "15:00", "16:00"}},
+		},
+		{
+			name:     "updated slot with its old times",
+			existing: [][3]string{{"a", "10:00", "13:00"}},
+			added:    [][3]string{{"a", "11:00", "14:00"}},
+		},
+	}
+
+	toSlots := func(t *testing.T, slots [][3]string) []Slot {
+		var built []Slot
+		for _, slot := range slots {
+			built = append(built, testSlot(t, slot[0], slot[1], slot[2]))
+		}
+		return built
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ids []string
+			for _, conflict := range FindOverlaps(toSlots(t, tt.existing), toSlots(t, tt.added)) {
+				ids = append(ids, conflict.ID)
+			}
+			if !slices.Equal(ids, tt.conflicts) {
+				t.Errorf("expected the conflicts %v, got %v", tt.conflicts, ids)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,6 +15,7 @@ type CreateTheatreParams struct {
 	MaxCapacity            int      `json:"max_capacity"`
 	MinCapacity            int      `json:"min_capacity"`
 	DefaultCapacity        int      `json:"default_capacity"`
+	Timezone               string   `json:"timezone"`
 	Slots                  []string `json:"slots"`
 }
 
@@ -36,6 +38,10 @@ func (ctp CreateTheatreParams) Validate() map[string]string {
 		errors["additional_price_per_head"] = "additional price per head should be a positive number"
 	}
 
+	if ctp.Timezone != "" && !IsTimezoneValid(ctp.Timezone) {
+		errors["timezone"] = "timezone should be a valid IANA timezone like Asia/Kolkata"
+	}
+
 	if len(ctp.Slots) == 0 {
 		errors["slots"] = "theatre should have at least one slot allocated"
 	}
@@ -49,6 +55,122 @@ func (ctp CreateTheatreParams) Validate() map[string]string {
 	return errors
 }
 
+// DefaultTimezone is the timezone of the theatres created without one
+const DefaultTimezone = "Asia/Kolkata"
+
+// IsTimezoneValid reports whether the timezone is a known IANA timezone
+func IsTimezoneValid(timezone string) bool {
+	_, err := time.LoadLocation(timezone)
+	return err == nil && timezone != "" && timezone != "Local"
+}
+
+var (
+	ErrTheatreHasBookings = errors.New("theatre has upcoming bookings")
+	ErrUnknownSlot        = errors.New("no slot found with given id")
+)
+
+// UpdateTheatreParams are the changes to a theatre, fields left out are kept
+type UpdateTheatreParams struct {
+	Name                   *string  `json:"name"`
+	Description            *string  `json:"description"`
+	Price                  *float64 `json:"price"`
+	AdditionalPricePerHead *float64 `json:"additional_price_per_head"`
+	MaxCapacity            *int     `json:"max_capacity"`
+	MinCapacity            *int     `json:"min_capacity"`
+	DefaultCapacity        *int     `json:"default_capacity"`
+	Timezone               *string  `json:"timezone"`
+}
+
+func (utp UpdateTheatreParams) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if utp == (UpdateTheatreParams{}) {
+		errors["theatre"] = "nothing to update"
+	}
+
+	if utp.Name != nil && *utp.Name == "" {
+		errors["name"] = "name of the theatre can not be empty"
+	}
+
+	if utp.Description != nil && *utp.Description == "" {
+		errors["description"] = "description of the theatre can not be empty"
+	}
+
+	if utp.Price != nil && *utp.Price <= 0 {
+		errors["price"] = "price of the theatre can not be zero or negative"
+	}
+
+	if utp.AdditionalPricePerHead != nil && *utp.AdditionalPricePerHead <= 0 {
+		errors["additional_price_per_head"] = "additional price per head should be a positive number"
+	}
+
+	if utp.MinCapacity != nil && *utp.MinCapacity <= 0 {
+		errors["min_capacity"] = "min capacity should be a positive number"
+	}
+
+	if utp.Timezone != nil && !IsTimezoneValid(*utp.Timezone) {
+		errors["timezone"] = "timezone should be a valid IANA timezone like Asia/Kolkata"
+	}
+	return errors
+}
+
+// Apply returns the theatre with the changes, capacities are checked once they are combined with the current ones
+func (utp UpdateTheatreParams) Apply(theatre Theatre) (Theatre, map[string]string) {
+	if utp.Name != nil {
+		theatre.Name = *utp.Name
+	}
+	if utp.Description != nil {
+		theatre.Description = *utp.Description
+	}
+	if utp.Price != nil {
+		theatre.Price = *utp.Price
+	}
+	if utp.AdditionalPricePerHead != nil {
+		theatre.AdditionalPricePerHead = *utp.AdditionalPricePerHead
+	}
+	if utp.MaxCapacity != nil {
+		theatre.MaxCapacity = *utp.MaxCapacity
+	}
+	if utp.MinCapacity != nil {
+		theatre.MinCapacity = *utp.MinCapacity
+	}
+	if utp.DefaultCapacity != nil {
+		theatre.DefaultCapacity = *utp.DefaultCapacity
+	}
+	if utp.Timezone != nil {
+		theatre.Timezone = *utp.Timezone
+	}
+
+	errors := make(map[string]string)
+	if theatre.MinCapacity > theatre.MaxCapacity {
+		errors["min_capacity"] = "min capacity can not be more than max capacity"
+	}
+	if theatre.DefaultCapacity < theatre.MinCapacity || theatre.DefaultCapacity > theatre.MaxCapacity {
+		errors["default_capacity"] = "default capacity should be between min and max capacity"
+	}
+	return theatre, errors
+}
+
+type TheatreSlotsParams struct {
+	Slots []string `json:"slots"`
+}
+
+func (tsp TheatreSlotsParams) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if len(tsp.Slots) == 0 {
+		errors["slots"] = "at least one slot should be given"
+	}
+
+	for _, val := range tsp.Slots {
+		if _, err := uuid.Parse(val); err != nil {
+			errors["slots"] = "invalid slot_id, it should be a valid uuid"
+			break
+		}
+	}
+	return errors
+}
+
 type Theatre struct {
 	ID                     string    `json:"id"`
 	Name                   string    `json:"name"`
@@ -58,10 +180,23 @@ type Theatre struct {
 	MaxCapacity            int       `json:"max_capacity"`
 	MinCapacity            int       `json:"min_capacity"`
 	DefaultCapacity        int       `json:"default_capacity"`
+	Timezone               string    `json:"timezone"`
 	CreatedAt              time.Time `json:"created_at"`
 	UpdatedAt              time.Time `json:"updated_at"`
 	UpdatedBy              string    `json:"updated_by"`
 	CreatedBy              string    `json:"created_by"`
+	// ArchivedAt is set for the theatres which can no longer be booked, they are kept for their past orders
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
+
+// Location is the timezone of the theatre, which its slots are in. Timezones are checked when the theatre is
+// saved, so UTC is only a fallback.
+func (t Theatre) Location() *time.Location {
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
 }
 
 type TheatreWithSlots struct {
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	DefaultUsersPageSize = 20
+	MaxUsersPageSize     = 100
+)
+
+// UsersFilter narrows down the users listing, which is ordered newest first
+type UsersFilter struct {
+	Role   string
+	Limit  int
+	Cursor *UsersCursor
+}
+
+// UsersCursor points at the last user of a page, the next page starts right after it
+type UsersCursor struct {
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"id"`
+}
+
+func (uc UsersCursor) Encode() string {
+	b, _ := json.Marshal(uc)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeUsersCursor(cursor string) (*UsersCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var uc UsersCursor
+	if err := json.Unmarshal(b, &uc); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(uc.ID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &uc, nil
+}
+
+// ParseUsersFilter parses the query of the users listing
+func ParseUsersFilter(query url.Values) (UsersFilter, map[string]string) {
+	errs := make(map[string]string)
+	filter := UsersFilter{
+		Role:  query.Get("role"),
+		Limit: DefaultUsersPageSize,
+	}
+
+	if filter.Role != "" && !IsRoleNameValid(filter.Role) {
+		errs["role"] = filter.Role + " is not a valid role"
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit <= 0 || filter.Limit > MaxUsersPageSize {
+			errs["limit"] = "limit must be a number between 1 and " + strconv.Itoa(MaxUsersPageSize)
+		}
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		var err error
+		filter.Cursor, err = DecodeUsersCursor(cursor)
+		if err != nil {
+			errs["cursor"] = err.Error()
+		}
+	}
+
+	return filter, errs
+}
+
+type UsersPage struct {
+	Users      []User `json:"users"`
+	TotalCount int    `json:"total_count"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -2,19 +2,25 @@ package models
 
 import (
 	"errors"
+	"regexp"
 	"slices"
+	"time"
 )
 
-var UserRoles = []string{
-	"admin",
-	// this role is for future purpose, where we need to have authentication
-	// for the normal users as well
-	"customer",
-}
+// roles every installation has, more can be added as sets of permissions
+const (
+	AdminRole = "admin"
+	// staff work the front desk, checking the customers in
+	StaffRole = "staff"
+	// customers only get to see and manage their own orders
+	CustomerRole = "customer"
+)
 
 var (
 	ErrNoUserWithEmail = errors.New("no user found with given email id")
 	ErrNoUserWithId    = errors.New("no user found with given user id")
+	ErrUserDisabled    = errors.New("user is disabled")
+	ErrLastAdmin       = errors.New("the last admin can not be disabled or lose the admin role")
 )
 
 type UserParams struct {
@@ -33,11 +39,11 @@ func (userParams UserParams) Validate() map[string]string {
 	if !isEmailValid(userParams.Email) {
 		errs["email"] = "user email should be a valid email address"
 	}
-	if len(userParams.Password) < 8 {
+	if len(userParams.Password) < MinPasswordLength {
 		errs["password"] = "user password should be at least 8 characters"
 	}
 	for _, role := range userParams.Roles {
-		if !slices.Contains(UserRoles, role) {
+		if !IsRoleNameValid(role) {
 			errs["roles"] = role + " is not a valid role"
 			break
 		}
@@ -45,10 +51,52 @@ func (userParams UserParams) Validate() map[string]string {
 	return errs
 }
 
+// UpdateUserParams are the changes admins can make to a user, fields left out are kept
+type UpdateUserParams struct {
+	Name  *string   `json:"name"`
+	Roles *[]string `json:"roles"`
+}
+
+func (updateParams UpdateUserParams) Validate() map[string]string {
+	errs := make(map[string]string)
+
+	if updateParams.Name == nil && updateParams.Roles == nil {
+		errs["user"] = "nothing to update"
+	}
+	if updateParams.Name != nil && *updateParams.Name == "" {
+		errs["name"] = "user name can not be empty"
+	}
+	if updateParams.Roles != nil {
+		if len(*updateParams.Roles) == 0 {
+			errs["roles"] = "user should have at least one role"
+		}
+		for _, role := range *updateParams.Roles {
+			if !IsRoleNameValid(role) {
+				errs["roles"] = role + " is not a valid role"
+				break
+			}
+		}
+	}
+	return errs
+}
+
 type User struct {
-	ID       string   `json:"id"`
-	Name     string   `json:"name"`
-	Email    string   `json:"email"`
-	Password string   `json:"-"`
-	Roles    []string `json:"roles"`
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Password        string     `json:"-"`
+	Roles           []string   `json:"roles"`
+	PhoneNumber     string     `json:"phone_number,omitempty"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty"`
+}
+
+func (user User) IsAdmin() bool {
+	return slices.Contains(user.Roles, AdminRole)
+}
+
+func isPhoneNumberValid(phoneNumber string) bool {
+	phoneRegex := regexp.MustCompile(`^\+?[0-9]{10,15}$`)
+	return phoneRegex.MatchString(phoneNumber)
 }
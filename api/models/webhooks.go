@@ -0,0 +1,49 @@
+package models
+
+const (
+	PaymentCapturedEvent = "payment.captured"
+	PaymentFailedEvent   = "payment.failed"
+	OrderPaidEvent       = "order.paid"
+	RefundProcessedEvent = "refund.processed"
+)
+
+type WebhookPaymentEntity struct {
+	ID               string `json:"id"`
+	OrderId          string `json:"order_id"`
+	Amount           int    `json:"amount"`
+	AmountRefunded   int    `json:"amount_refunded"`
+	Status           string `json:"status"`
+	ErrorDescription string `json:"error_description"`
+}
+
+type WebhookOrderEntity struct {
+	ID         string `json:"id"`
+	AmountPaid int    `json:"amount_paid"`
+	Status     string `json:"status"`
+}
+
+type WebhookRefundEntity struct {
+	ID        string `json:"id"`
+	PaymentId string `json:"payment_id"`
+	Amount    int    `json:"amount"`
+	Status    string `json:"status"`
+}
+
+// RazorpayWebhookEvent is the body razorpay posts to the webhook, only the entities
+// listed in Contains are present in the payload
+type RazorpayWebhookEvent struct {
+	Event    string   `json:"event"`
+	Contains []string `json:"contains"`
+	Payload  struct {
+		Payment *struct {
+			Entity WebhookPaymentEntity `json:"entity"`
+		} `json:"payment,omitempty"`
+		Order *struct {
+			Entity WebhookOrderEntity `json:"entity"`
+		} `json:"order,omitempty"`
+		Refund *struct {
+			Entity WebhookRefundEntity `json:"entity"`
+		} `json:"refund,omitempty"`
+	} `json:"payload"`
+	CreatedAt int64 `json:"created_at"`
+}
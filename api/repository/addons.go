@@ -3,7 +3,9 @@ package repository
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/ortin779/private_theatre_api/api/models"
 )
 
@@ -11,6 +13,12 @@ type AddonRepository interface {
 	Create(addon models.Addon) error
 	GetCategories() []string
 	GetAllAddons() ([]models.Addon, error)
+	GetByIds(ids []string) ([]models.Addon, error)
+	GetById(id string) (*models.Addon, error)
+	Update(addon models.Addon) error
+	GetPrices(id string) ([]models.AddonPrice, error)
+	Archive(id, updatedBy string, archivedAt time.Time) error
+	Unarchive(id, updatedBy string, updatedAt time.Time) error
 }
 
 type addonRepository struct {
@@ -23,14 +31,30 @@ func NewAddonRepository(db *sql.DB) AddonRepository {
 	}
 }
 
+const addonColumns = `id, name, category, price, meta_data, updated_at, created_at, created_by, updated_by, archived_at`
+
 func (as *addonRepository) Create(addon models.Addon) error {
-	_, err := as.db.Exec(`INSERT INTO addons(id, name, category, price, meta_data, created_at, updated_at, created_by, updated_by)
+	tx, err := as.db.Begin()
+	if err != nil {
+		return fmt.Errorf("create addon: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO addons(id, name, category, price, meta_data, created_at, updated_at, created_by, updated_by)
         VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
     `, addon.ID, addon.Name, addon.Category, addon.Price, addon.MetaData, addon.CreatedAt, addon.UpdatedAt, addon.CreatedBy, addon.UpdatedBy)
 
 	if err != nil {
 		return fmt.Errorf("create addon: %w", err)
 	}
+
+	if err := insertAddonPrice(tx, addon.ID, addon.Price, addon.CreatedAt, addon.CreatedBy); err != nil {
+		return fmt.Errorf("create addon: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("create addon: %w", err)
+	}
 	return nil
 }
 
@@ -38,8 +62,9 @@ func (as *addonRepository) GetCategories() []string {
 	return models.AddonCategories
 }
 
+// GetAllAddons returns the addons which can be ordered, the archived ones are left out
 func (as *addonRepository) GetAllAddons() ([]models.Addon, error) {
-	rows, err := as.db.Query(`SELECT * FROM addons;`)
+	rows, err := as.db.Query(`SELECT ` + addonColumns + ` FROM addons WHERE archived_at IS NULL;`)
 	if err != nil {
 		return nil, fmt.Errorf("get addons: %w", err)
 	}
@@ -47,12 +72,11 @@ func (as *addonRepository) GetAllAddons() ([]models.Addon, error) {
 	var addons []models.Addon
 
 	for rows.Next() {
-		var addon models.Addon
-		err = rows.Scan(&addon.ID, &addon.Name, &addon.Category, &addon.Price, &addon.MetaData, &addon.UpdatedAt, &addon.CreatedAt, &addon.CreatedBy, &addon.UpdatedBy)
+		addon, err := scanAddon(rows)
 		if err != nil {
 			return nil, fmt.Errorf("get addons: %w", err)
 		}
-		addons = append(addons, addon)
+		addons = append(addons, *addon)
 	}
 
 	if rows.Err() != nil {
@@ -61,3 +85,137 @@ func (as *addonRepository) GetAllAddons() ([]models.Addon, error) {
 
 	return addons, nil
 }
+
+func (as *addonRepository) GetByIds(ids []string) ([]models.Addon, error) {
+	rows, err := as.db.Query(`SELECT `+addonColumns+`
+        FROM addons
+        WHERE id = ANY($1::uuid[]);
+    `, ids)
+	if err != nil {
+		return nil, fmt.Errorf("get addons by ids: %w", err)
+	}
+	defer rows.Close()
+
+	addons := make([]models.Addon, 0, len(ids))
+	for rows.Next() {
+		addon, err := scanAddon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get addons by ids: %w", err)
+		}
+		addons = append(addons, *addon)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("get addons by ids: %w", rows.Err())
+	}
+
+	return addons, nil
+}
+
+func (as *addonRepository) GetById(id string) (*models.Addon, error) {
+	row := as.db.QueryRow(`SELECT `+addonColumns+` FROM addons WHERE id = $1;`, id)
+
+	addon, err := scanAddon(row)
+	if err != nil {
+		return nil, fmt.Errorf("get addon: %w", err)
+	}
+	return addon, nil
+}
+
+// Update saves the details of the addon, a new price is added to its price history.
+// The orders already made keep the unit price they were made with.
+func (as *addonRepository) Update(addon models.Addon) error {
+	tx, err := as.db.Begin()
+	if err != nil {
+		return fmt.Errorf("update addon: %w", err)
+	}
+	defer tx.Rollback()
+
+	var currentPrice float64
+	row := tx.QueryRow(`SELECT price FROM addons WHERE id = $1 FOR UPDATE;`, addon.ID)
+	if err := row.Scan(&currentPrice); err != nil {
+		return fmt.Errorf("update addon: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE addons SET name = $2, category = $3, price = $4, meta_data = $5, updated_at = $6, updated_by = $7
+        WHERE id = $1;
+    `, addon.ID, addon.Name, addon.Category, addon.Price, addon.MetaData, addon.UpdatedAt, addon.UpdatedBy)
+	if err != nil {
+		return fmt.Errorf("update addon: %w", err)
+	}
+
+	if addon.Price != currentPrice {
+		if err := insertAddonPrice(tx, addon.ID, addon.Price, addon.UpdatedAt, addon.UpdatedBy); err != nil {
+			return fmt.Errorf("update addon: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("update addon: %w", err)
+	}
+	return nil
+}
+
+// GetPrices returns the price history of the addon, newest first
+func (as *addonRepository) GetPrices(id string) ([]models.AddonPrice, error) {
+	rows, err := as.db.Query(`SELECT id, addon_id, price, effective_from, created_by FROM addon_prices
+        WHERE addon_id = $1
+        ORDER BY effective_from DESC;
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("get addon prices: %w", err)
+	}
+	defer rows.Close()
+
+	prices := make([]models.AddonPrice, 0, 1)
+	for rows.Next() {
+		var price models.AddonPrice
+		if err := rows.Scan(&price.ID, &price.AddonId, &price.Price, &price.EffectiveFrom, &price.CreatedBy); err != nil {
+			return nil, fmt.Errorf("get addon prices: %w", err)
+		}
+		prices = append(prices, price)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("get addon prices: %w", rows.Err())
+	}
+	return prices, nil
+}
+
+// Archive keeps the addon from being ordered, the orders already made with it keep it
+func (as *addonRepository) Archive(id, updatedBy string, archivedAt time.Time) error {
+	result, err := as.db.Exec(`UPDATE addons SET archived_at = COALESCE(archived_at, $3), updated_at = $3, updated_by = $2
+        WHERE id = $1;
+    `, id, updatedBy, archivedAt)
+	if err != nil {
+		return fmt.Errorf("archive addon: %w", err)
+	}
+	return checkUpdated(result, "archive addon")
+}
+
+// Unarchive makes an archived addon orderable again
+func (as *addonRepository) Unarchive(id, updatedBy string, updatedAt time.Time) error {
+	result, err := as.db.Exec(`UPDATE addons SET archived_at = NULL, updated_at = $3, updated_by = $2
+        WHERE id = $1;
+    `, id, updatedBy, updatedAt)
+	if err != nil {
+		return fmt.Errorf("unarchive addon: %w", err)
+	}
+	return checkUpdated(result, "unarchive addon")
+}
+
+func insertAddonPrice(db execer, addonId string, price float64, effectiveFrom time.Time, createdBy string) error {
+	_, err := db.Exec(`INSERT INTO addon_prices(id, addon_id, price, effective_from, created_by)
+        VALUES ($1, $2, $3, $4, $5);
+    `, uuid.NewString(), addonId, price, effectiveFrom, createdBy)
+	return err
+}
+
+func scanAddon(row rowScanner) (*models.Addon, error) {
+	var addon models.Addon
+	err := row.Scan(&addon.ID, &addon.Name, &addon.Category, &addon.Price, &addon.MetaData, &addon.UpdatedAt, &addon.CreatedAt, &addon.CreatedBy, &addon.UpdatedBy, &addon.ArchivedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &addon, nil
+}
@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type CouponsRepository interface {
+	GetAll() ([]models.Coupon, error)
+	GetById(id string) (*models.Coupon, error)
+	GetByCode(code string) (*models.Coupon, error)
+	Create(coupon models.Coupon) error
+	Update(coupon models.Coupon) error
+	Archive(id string, by string, at time.Time) error
+	GetRedemptions(couponId string) ([]models.CouponRedemption, error)
+}
+
+// couponRedemptionsCount counts the redemptions of the coupon whose orders are not expired or cancelled
+const couponRedemptionsCount = `(
+	SELECT COUNT(*) FROM coupon_redemptions JOIN orders ON orders.id = coupon_redemptions.order_id
+	WHERE coupon_redemptions.coupon_id = coupons.id AND ` + activeOrdersCondition + `
+)`
+
+const couponColumns = `id, code, description, discount_type, discount, max_discount, min_order_value, valid_from, valid_to,
+	max_redemptions, max_redemptions_per_customer, theatre_ids, addon_ids, ` + couponRedemptionsCount + `, archived_at,
+	created_at, updated_at, created_by, updated_by`
+
+type couponsRepository struct {
+	db *sql.DB
+}
+
+func NewCouponsRepository(db *sql.DB) CouponsRepository {
+	return &couponsRepository{
+		db: db,
+	}
+}
+
+func (cr *couponsRepository) GetAll() ([]models.Coupon, error) {
+	rows, err := cr.db.Query(`SELECT ` + couponColumns + ` FROM coupons ORDER BY created_at DESC, id;`)
+	if err != nil {
+		return nil, fmt.Errorf("get coupons: %w", err)
+	}
+	defer rows.Close()
+
+	coupons := []models.Coupon{}
+	for rows.Next() {
+		coupon, err := scanCoupon(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get coupons: %w", err)
+		}
+		coupons = append(coupons, *coupon)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get coupons: %w", err)
+	}
+	return coupons, nil
+}
+
+func (cr *couponsRepository) GetById(id string) (*models.Coupon, error) {
+	row := cr.db.QueryRow(`SELECT `+couponColumns+` FROM coupons WHERE id = $1;`, id)
+	coupon, err := scanCoupon(row)
+	if err != nil {
+		return nil, fmt.Errorf("get coupon: %w", err)
+	}
+	return coupon, nil
+}
+
+// GetByCode returns the coupon with the code, whatever its case
+func (cr *couponsRepository) GetByCode(code string) (*models.Coupon, error) {
+	row := cr.db.QueryRow(`SELECT `+couponColumns+` FROM coupons WHERE UPPER(code) = UPPER($1);`, code)
+	coupon, err := scanCoupon(row)
+	if err != nil {
+		return nil, fmt.Errorf("get coupon: %w", err)
+	}
+	return coupon, nil
+}
+
+func (cr *couponsRepository) Create(coupon models.Coupon) error {
+	_, err := cr.db.Exec(`
+		INSERT INTO coupons(id, code, description, discount_type, discount, max_discount, min_order_value, valid_from, valid_to,
+			max_redemptions, max_redemptions_per_customer, theatre_ids, addon_ids, created_at, updated_at, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17);
+	`, coupon.ID, coupon.Code, coupon.Description, coupon.DiscountType, coupon.Discount, coupon.MaxDiscount, coupon.MinOrderValue,
+		coupon.ValidFrom, coupon.ValidTo, coupon.MaxRedemptions, coupon.MaxRedemptionsPerCustomer, coupon.TheatreIds, coupon.AddonIds,
+		coupon.CreatedAt, coupon.UpdatedAt, coupon.CreatedBy, coupon.UpdatedBy)
+	return couponError(err, "create coupon")
+}
+
+func (cr *couponsRepository) Update(coupon models.Coupon) error {
+	result, err := cr.db.Exec(`
+		UPDATE coupons SET code = $2, description = $3, discount_type = $4, discount = $5, max_discount = $6, min_order_value = $7,
+			valid_from = $8, valid_to = $9, max_redemptions = $10, max_redemptions_per_customer = $11, theatre_ids = $12,
+			addon_ids = $13, updated_at = $14, updated_by = $15
+		WHERE id = $1;
+	`, coupon.ID, coupon.Code, coupon.Description, coupon.DiscountType, coupon.Discount, coupon.MaxDiscount, coupon.MinOrderValue,
+		coupon.ValidFrom, coupon.ValidTo, coupon.MaxRedemptions, coupon.MaxRedemptionsPerCustomer, coupon.TheatreIds, coupon.AddonIds,
+		coupon.UpdatedAt, coupon.UpdatedBy)
+	if err := couponError(err, "update coupon"); err != nil {
+		return err
+	}
+	return checkUpdated(result, "update coupon")
+}
+
+// Archive stops the coupon from being used, the orders which used it keep their discount
+func (cr *couponsRepository) Archive(id string, by string, at time.Time) error {
+	result, err := cr.db.Exec(`
+		UPDATE coupons SET archived_at = $2, updated_at = $2, updated_by = $3
+		WHERE id = $1 AND archived_at IS NULL;
+	`, id, at, by)
+	if err != nil {
+		return fmt.Errorf("archive coupon: %w", err)
+	}
+	return checkUpdated(result, "archive coupon")
+}
+
+func (cr *couponsRepository) GetRedemptions(couponId string) ([]models.CouponRedemption, error) {
+	rows, err := cr.db.Query(`
+		SELECT coupon_redemptions.id, coupon_redemptions.coupon_id, coupon_redemptions.order_id, coupon_redemptions.customer_email,
+			coupon_redemptions.user_id, coupon_redemptions.discount, coupon_redemptions.redeemed_at, orders.status
+		FROM coupon_redemptions JOIN orders ON orders.id = coupon_redemptions.order_id
+		WHERE coupon_redemptions.coupon_id = $1
+		ORDER BY coupon_redemptions.redeemed_at DESC, coupon_redemptions.id;
+	`, couponId)
+	if err != nil {
+		return nil, fmt.Errorf("get coupon redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	redemptions := []models.CouponRedemption{}
+	for rows.Next() {
+		var redemption models.CouponRedemption
+		err := rows.Scan(&redemption.ID, &redemption.CouponId, &redemption.OrderId, &redemption.CustomerEmail, &redemption.UserId,
+			&redemption.Discount, &redemption.RedeemedAt, &redemption.OrderStatus)
+		if err != nil {
+			return nil, fmt.Errorf("get coupon redemptions: %w", err)
+		}
+		redemptions = append(redemptions, redemption)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get coupon redemptions: %w", err)
+	}
+	return redemptions, nil
+}
+
+// redeemCoupon records the use of the coupon by the order. The coupon is locked until the order is saved, so that
+// orders made at the same time can not go over its limits.
+// It returns models.ErrCouponExhausted when the coupon or the customer has no uses left.
+func redeemCoupon(tx *sql.Tx, order models.Order, discount models.PriceLineItem) error {
+	var maxRedemptions, maxRedemptionsPerCustomer *int
+	err := tx.QueryRow(`
+		SELECT max_redemptions, max_redemptions_per_customer FROM coupons WHERE id = $1 FOR UPDATE;
+	`, discount.CouponId).Scan(&maxRedemptions, &maxRedemptionsPerCustomer)
+	if err != nil {
+		return err
+	}
+
+	var redemptions, customerRedemptions int
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE LOWER(coupon_redemptions.customer_email) = LOWER($2) OR coupon_redemptions.user_id = $3)
+		FROM coupon_redemptions JOIN orders ON orders.id = coupon_redemptions.order_id
+		WHERE coupon_redemptions.coupon_id = $1 AND `+activeOrdersCondition+`;
+	`, discount.CouponId, order.CustomerEmail, nullString(order.UserId)).Scan(&redemptions, &customerRedemptions)
+	if err != nil {
+		return err
+	}
+
+	if maxRedemptions != nil && redemptions >= *maxRedemptions {
+		return models.ErrCouponExhausted
+	}
+	if maxRedemptionsPerCustomer != nil && customerRedemptions >= *maxRedemptionsPerCustomer {
+		return models.ErrCouponExhausted
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO coupon_redemptions(id, coupon_id, order_id, customer_email, user_id, discount, redeemed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`, uuid.NewString(), discount.CouponId, order.ID, order.CustomerEmail, nullString(order.UserId), -discount.Amount, order.OrderedAt)
+	return err
+}
+
+// couponError returns models.ErrCouponExists when another coupon has the code
+func couponError(err error, action string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		return models.ErrCouponExists
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	return nil
+}
+
+func scanCoupon(row rowScanner) (*models.Coupon, error) {
+	var coupon models.Coupon
+	typeMap := pgtype.NewMap()
+	err := row.Scan(&coupon.ID, &coupon.Code, &coupon.Description, &coupon.DiscountType, &coupon.Discount, &coupon.MaxDiscount,
+		&coupon.MinOrderValue, &coupon.ValidFrom, &coupon.ValidTo, &coupon.MaxRedemptions, &coupon.MaxRedemptionsPerCustomer,
+		typeMap.SQLScanner(&coupon.TheatreIds), typeMap.SQLScanner(&coupon.AddonIds), &coupon.Redemptions, &coupon.ArchivedAt,
+		&coupon.CreatedAt, &coupon.UpdatedAt, &coupon.CreatedBy, &coupon.UpdatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type EmailVerificationsRepository interface {
+	Save(verification models.EmailVerification, resendAfter time.Time) (bool, error)
+	Get(userId string) (*models.EmailVerification, error)
+	UseAttempt(userId string, maxAttempts int) (*models.EmailVerification, error)
+}
+
+type emailVerificationsRepository struct {
+	db *sql.DB
+}
+
+func NewEmailVerificationsRepository(db *sql.DB) EmailVerificationsRepository {
+	return &emailVerificationsRepository{
+		db: db,
+	}
+}
+
+// Save stores the verification code of the user, replacing the one sent earlier when it was sent at or before
+// resendAfter. The attempts made with the earlier code carry over to the new one until the earlier code expires,
+// so resending does not buy more guesses. It reports whether the code was stored.
+func (evr *emailVerificationsRepository) Save(verification models.EmailVerification, resendAfter time.Time) (bool, error) {
+	result, err := evr.db.Exec(`INSERT INTO email_verifications(user_id, code_hash, attempts, expires_at, created_at)
+        VALUES ($1, $2, 0, $3, $4)
+        ON CONFLICT (user_id) DO UPDATE
+        SET code_hash = EXCLUDED.code_hash,
+            attempts = CASE WHEN email_verifications.expires_at < EXCLUDED.created_at THEN 0 ELSE email_verifications.attempts END,
+            expires_at = EXCLUDED.expires_at,
+            created_at = EXCLUDED.created_at
+        WHERE email_verifications.created_at <= $5;
+    `, verification.UserId, verification.CodeHash, verification.ExpiresAt, verification.CreatedAt, resendAfter)
+	if err != nil {
+		return false, fmt.Errorf("save email verification: %w", err)
+	}
+
+	saved, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("save email verification: %w", err)
+	}
+	return saved > 0, nil
+}
+
+func (evr *emailVerificationsRepository) Get(userId string) (*models.EmailVerification, error) {
+	row := evr.db.QueryRow(`SELECT user_id, code_hash, attempts, expires_at, created_at
+        FROM email_verifications
+        WHERE user_id = $1;
+    `, userId)
+
+	verification, err := scanEmailVerification(row)
+	if err != nil {
+		return nil, fmt.Errorf("get email verification: %w", err)
+	}
+	return verification, nil
+}
+
+// UseAttempt counts an attempt at the verification code of the user and returns the code to check it against.
+// The attempt is only counted while fewer than maxAttempts were made, models.ErrTooManyVerificationAttempts is
+// returned otherwise.
+func (evr *emailVerificationsRepository) UseAttempt(userId string, maxAttempts int) (*models.EmailVerification, error) {
+	row := evr.db.QueryRow(`UPDATE email_verifications SET attempts = attempts + 1
+        WHERE user_id = $1 AND attempts < $2
+        RETURNING user_id, code_hash, attempts, expires_at, created_at;
+    `, userId, maxAttempts)
+
+	verification, err := scanEmailVerification(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		// tell an exhausted code apart from a missing one
+		if _, err := evr.Get(userId); err != nil {
+			return nil, err
+		}
+		return nil, models.ErrTooManyVerificationAttempts
+	}
+	if err != nil {
+		return nil, fmt.Errorf("use email verification attempt: %w", err)
+	}
+	return verification, nil
+}
+
+func scanEmailVerification(row rowScanner) (*models.EmailVerification, error) {
+	var verification models.EmailVerification
+	err := row.Scan(&verification.UserId, &verification.CodeHash, &verification.Attempts, &verification.ExpiresAt, &verification.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
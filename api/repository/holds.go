@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+const (
+	uniqueViolationCode     = "23505"
+	foreignKeyViolationCode = "23503"
+)
+
+type HoldsRepository interface {
+	Create(hold models.Hold) error
+	Delete(id string) error
+	GetExpired(now time.Time) ([]models.Hold, error)
+	GetExpiredOnSlot(theatreId, slotId string, orderDate, now time.Time) ([]models.Hold, error)
+	GetActiveHolds(theatreId string, from, to, now time.Time) ([]models.Hold, error)
+}
+
+type holdsRepository struct {
+	db *sql.DB
+}
+
+func NewHoldsRepository(db *sql.DB) HoldsRepository {
+	return &holdsRepository{
+		db: db,
+	}
+}
+
+// Create places the hold on the theatre slot. It returns models.ErrSlotUnavailable when
+// the slot is already booked or held by someone else.
+func (hr *holdsRepository) Create(hold models.Hold) error {
+	tx, err := hr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("create hold: %w", err)
+	}
+	defer tx.Rollback()
+
+	var booked bool
+	row := tx.QueryRow(`SELECT EXISTS(
+        SELECT 1 FROM orders
+        WHERE theatre_id = $1 AND slot_id = $2 AND order_date = $3 AND `+activeOrdersCondition+`
+    );`, hold.TheatreId, hold.SlotId, hold.OrderDate.Format(time.DateOnly))
+	if err := row.Scan(&booked); err != nil {
+		return fmt.Errorf("create hold: %w", err)
+	}
+	if booked {
+		return models.ErrSlotUnavailable
+	}
+
+	_, err = tx.Exec(`INSERT INTO holds(id, theatre_id, slot_id, order_date, order_id, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7);
+    `, hold.ID, hold.TheatreId, hold.SlotId, hold.OrderDate.Format(time.DateOnly), hold.OrderId, hold.ExpiresAt, hold.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return models.ErrSlotUnavailable
+		}
+		return fmt.Errorf("create hold: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("create hold: %w", err)
+	}
+	return nil
+}
+
+func (hr *holdsRepository) Delete(id string) error {
+	_, err := hr.db.Exec(`DELETE FROM holds WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("delete hold: %w", err)
+	}
+	return nil
+}
+
+func (hr *holdsRepository) GetExpired(now time.Time) ([]models.Hold, error) {
+	rows, err := hr.db.Query(`SELECT id, theatre_id, slot_id, order_date, order_id, expires_at, created_at
+        FROM holds
+        WHERE expires_at < $1;
+    `, now)
+	if err != nil {
+		return nil, fmt.Errorf("get expired holds: %w", err)
+	}
+	defer rows.Close()
+
+	return scanHolds(rows)
+}
+
+func (hr *holdsRepository) GetExpiredOnSlot(theatreId, slotId string, orderDate, now time.Time) ([]models.Hold, error) {
+	rows, err := hr.db.Query(`SELECT id, theatre_id, slot_id, order_date, order_id, expires_at, created_at
+        FROM holds
+        WHERE theatre_id = $1 AND slot_id = $2 AND order_date = $3 AND expires_at < $4;
+    `, theatreId, slotId, orderDate.Format(time.DateOnly), now)
+	if err != nil {
+		return nil, fmt.Errorf("get expired holds on slot: %w", err)
+	}
+	defer rows.Close()
+
+	return scanHolds(rows)
+}
+
+func (hr *holdsRepository) GetActiveHolds(theatreId string, from, to, now time.Time) ([]models.Hold, error) {
+	rows, err := hr.db.Query(`SELECT id, theatre_id, slot_id, order_date, order_id, expires_at, created_at
+        FROM holds
+        WHERE theatre_id = $1 AND order_date BETWEEN $2 AND $3 AND expires_at >= $4;
+    `, theatreId, from.Format(time.DateOnly), to.Format(time.DateOnly), now)
+	if err != nil {
+		return nil, fmt.Errorf("get active holds: %w", err)
+	}
+	defer rows.Close()
+
+	return scanHolds(rows)
+}
+
+func scanHolds(rows *sql.Rows) ([]models.Hold, error) {
+	holds := make([]models.Hold, 0)
+	for rows.Next() {
+		var hold models.Hold
+		err := rows.Scan(&hold.ID, &hold.TheatreId, &hold.SlotId, &hold.OrderDate, &hold.OrderId, &hold.ExpiresAt, &hold.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("scan holds: %w", err)
+		}
+		holds = append(holds, hold)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("scan holds: %w", rows.Err())
+	}
+	return holds, nil
+}
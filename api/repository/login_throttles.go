@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type LoginThrottlesRepository interface {
+	GetLockedUntil(keys []string, now time.Time) (*time.Time, error)
+	Attempt(key string, limits models.LoginLimits, now time.Time) (bool, error)
+	Forgive(key string, limits models.LoginLimits, now time.Time) error
+	Clear(key string) error
+}
+
+type loginThrottlesRepository struct {
+	db *sql.DB
+}
+
+func NewLoginThrottlesRepository(db *sql.DB) LoginThrottlesRepository {
+	return &loginThrottlesRepository{
+		db: db,
+	}
+}
+
+// GetLockedUntil returns until when the keys are locked out, nil when none of them is
+func (ltr *loginThrottlesRepository) GetLockedUntil(keys []string, now time.Time) (*time.Time, error) {
+	var lockedUntil *time.Time
+	row := ltr.db.QueryRow(`SELECT MAX(locked_until) FROM login_throttles
+        WHERE key = ANY($1) AND locked_until > $2;
+    `, keys, now)
+
+	if err := row.Scan(&lockedUntil); err != nil {
+		return nil, fmt.Errorf("get login lockout: %w", err)
+	}
+	return lockedUntil, nil
+}
+
+// Attempt counts a login attempt as a failure for the key unless the key is locked out, and locks the key out
+// for as long as the failures so far call for. Failures older than the lockout duration are forgotten.
+// It reports whether the attempt was counted, the check and the count are a single statement so that
+// parallel attempts can not get past the lockout.
+func (ltr *loginThrottlesRepository) Attempt(key string, limits models.LoginLimits, now time.Time) (bool, error) {
+	failures := `CASE WHEN login_throttles.last_failure_at < $7 THEN 1 ELSE login_throttles.failures + 1 END`
+
+	var counted int
+	row := ltr.db.QueryRow(`INSERT INTO login_throttles(key, failures, last_failure_at, locked_until)
+        VALUES ($1, 1, $2, `+lockedUntil("1")+`)
+        ON CONFLICT (key) DO UPDATE
+        SET failures = `+failures+`,
+            last_failure_at = EXCLUDED.last_failure_at,
+            locked_until = `+lockedUntil(failures)+`
+        WHERE login_throttles.locked_until IS NULL OR login_throttles.locked_until <= $2
+        RETURNING failures;
+    `, lockoutArgs(key, limits, now, now.Add(-limits.LockoutDuration))...)
+
+	err := row.Scan(&counted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("record login attempt: %w", err)
+	}
+	return true, nil
+}
+
+// Forgive takes back an attempt counted for the key, lifting the lockout it caused
+func (ltr *loginThrottlesRepository) Forgive(key string, limits models.LoginLimits, now time.Time) error {
+	_, err := ltr.db.Exec(`UPDATE login_throttles
+        SET failures = failures - 1,
+            locked_until = `+lockedUntil("(failures - 1)")+`
+        WHERE key = $1 AND failures > 0;
+    `, lockoutArgs(key, limits, now)...)
+	if err != nil {
+		return fmt.Errorf("forgive login attempt: %w", err)
+	}
+	return nil
+}
+
+func (ltr *loginThrottlesRepository) Clear(key string) error {
+	_, err := ltr.db.Exec(`DELETE FROM login_throttles WHERE key = $1;`, key)
+	if err != nil {
+		return fmt.Errorf("clear login throttle: %w", err)
+	}
+	return nil
+}
+
+// lockedUntil is the SQL for until when the given failures lock a key out. After the free attempts every
+// failure waits twice as long as the previous one, up to the lockout duration. It takes the arguments
+// of lockoutArgs.
+func lockedUntil(failures string) string {
+	return `CASE
+            WHEN ` + failures + ` <= $3::INTEGER THEN NULL
+            WHEN ` + failures + ` >= $4::INTEGER THEN $2::TIMESTAMP + $6::BIGINT * INTERVAL '1 millisecond'
+            ELSE $2::TIMESTAMP + LEAST($5::BIGINT * POWER(2, LEAST(` + failures + ` - $3::INTEGER - 1, 30)), $6::BIGINT) * INTERVAL '1 millisecond'
+        END`
+}
+
+func lockoutArgs(key string, limits models.LoginLimits, now time.Time, extra ...any) []any {
+	args := []any{key, now, limits.FreeAttempts, limits.LockoutAttempts, limits.BaseDelay.Milliseconds(), limits.LockoutDuration.Milliseconds()}
+	return append(args, extra...)
+}
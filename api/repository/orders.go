@@ -2,19 +2,35 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/ortin779/private_theatre_api/api/models"
 )
 
 type OrdersRepository interface {
 	Create(order models.Order) error
-	GetAll() ([]models.OrderDetails, error)
+	List(filter models.OrdersFilter) (*models.OrdersPage, error)
 	GetById(id string) (*models.OrderDetails, error)
 	GetOrderByTheatreIdAndSlotIdAndOrderDate(slotId, theatreId string, orderDate time.Time) (*models.OrderDetails, error)
+	GetBookedSlots(theatreId string, from, to time.Time) ([]models.BookedSlot, error)
+	GetIdByRazorpayOrderId(razorpayOrderId string) (string, error)
+	GetStatus(orderId string) (models.OrderStatus, error)
+	Transition(transition models.OrderTransition) error
+	Cancel(transition models.OrderTransition, refund *models.RefundRequest) error
+	GetHistory(orderId string) ([]models.OrderTransition, error)
+	Reschedule(reschedule models.Reschedule) error
+	ApplyReschedule(razorpayOrderId string, at time.Time) error
+	IsOwnedBy(orderId, userId string) (bool, error)
 }
 
+// activeOrdersCondition matches the orders which keep their theatre slot booked
+const activeOrdersCondition = "status IN ('pending_payment', 'confirmed', 'checked_in', 'completed')"
+
 type ordersRepository struct {
 	db *sql.DB
 }
@@ -28,7 +44,7 @@ func NewOrderRepository(db *sql.DB) OrdersRepository {
 func (ordersRepo *ordersRepository) GetOrderByTheatreIdAndSlotIdAndOrderDate(slotId, theatreId string, orderDate time.Time) (*models.OrderDetails, error) {
 	var orderId string
 	row := ordersRepo.db.QueryRow(`SELECT id FROM orders
-        WHERE theatre_id = $1 AND slot_id = $2 AND order_date = $3;
+        WHERE theatre_id = $1 AND slot_id = $2 AND order_date = $3 AND `+activeOrdersCondition+`;
     `, theatreId, slotId, orderDate.Format(time.DateOnly))
 
 	err := row.Scan(&orderId)
@@ -39,6 +55,365 @@ func (ordersRepo *ordersRepository) GetOrderByTheatreIdAndSlotIdAndOrderDate(slo
 	return ordersRepo.GetById(orderId)
 }
 
+func (ordersRepo *ordersRepository) GetBookedSlots(theatreId string, from, to time.Time) ([]models.BookedSlot, error) {
+	rows, err := ordersRepo.db.Query(`SELECT slot_id, order_date FROM orders
+        WHERE theatre_id = $1 AND order_date BETWEEN $2 AND $3 AND `+activeOrdersCondition+`;
+    `, theatreId, from.Format(time.DateOnly), to.Format(time.DateOnly))
+
+	if err != nil {
+		return nil, fmt.Errorf("get booked slots: %w", err)
+	}
+	defer rows.Close()
+
+	bookedSlots := make([]models.BookedSlot, 0, 5)
+	for rows.Next() {
+		var bookedSlot models.BookedSlot
+		err := rows.Scan(&bookedSlot.SlotId, &bookedSlot.OrderDate)
+		if err != nil {
+			return nil, fmt.Errorf("get booked slots: %w", err)
+		}
+		bookedSlots = append(bookedSlots, bookedSlot)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("get booked slots: %w", rows.Err())
+	}
+
+	return bookedSlots, nil
+}
+
+func (ordersRepo *ordersRepository) GetIdByRazorpayOrderId(razorpayOrderId string) (string, error) {
+	var orderId string
+	row := ordersRepo.db.QueryRow(`SELECT id FROM orders WHERE razorpay_order_id = $1
+        UNION
+        SELECT order_id FROM order_reschedules WHERE razorpay_order_id = $1;
+    `, razorpayOrderId)
+
+	if err := row.Scan(&orderId); err != nil {
+		return "", fmt.Errorf("get order by razorpay order: %w", err)
+	}
+	return orderId, nil
+}
+
+// IsOwnedBy reports whether the order was made by the user, or with the email of the user once it is verified
+func (ordersRepo *ordersRepository) IsOwnedBy(orderId, userId string) (bool, error) {
+	var owned bool
+	row := ordersRepo.db.QueryRow(`SELECT EXISTS(
+        SELECT 1 FROM orders
+        JOIN users ON users.id = $2
+        WHERE orders.id = $1 AND (
+            orders.user_id = users.id OR
+            (users.email_verified_at IS NOT NULL AND LOWER(users.email) = LOWER(orders.customer_email))
+        )
+    );`, orderId, userId)
+
+	if err := row.Scan(&owned); err != nil {
+		return false, fmt.Errorf("check order owner: %w", err)
+	}
+	return owned, nil
+}
+
+func (ordersRepo *ordersRepository) GetStatus(orderId string) (models.OrderStatus, error) {
+	var status models.OrderStatus
+	row := ordersRepo.db.QueryRow(`SELECT status FROM orders WHERE id = $1;`, orderId)
+
+	if err := row.Scan(&status); err != nil {
+		return "", fmt.Errorf("get order status: %w", err)
+	}
+	return status, nil
+}
+
+// Transition moves the order from its current status to the next one and records it in the order history.
+// The status is only changed if the order is still in the transition's from status, otherwise
+// models.ErrInvalidOrderTransition is returned.
+func (ordersRepo *ordersRepository) Transition(transition models.OrderTransition) error {
+	tx, err := ordersRepo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("transition order: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := transitionOrder(tx, transition); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("transition order: %w", err)
+	}
+	return nil
+}
+
+// Cancel moves the order to cancelled the way Transition does and records the refund request it owes, if any,
+// along with it. Only the request which gets to cancel the order records the refund it owes.
+func (ordersRepo *ordersRepository) Cancel(transition models.OrderTransition, refund *models.RefundRequest) error {
+	tx, err := ordersRepo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("cancel order: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := transitionOrder(tx, transition); err != nil {
+		return err
+	}
+
+	if refund != nil {
+		if err := insertRefundRequest(tx, *refund); err != nil {
+			return fmt.Errorf("cancel order: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cancel order: %w", err)
+	}
+	return nil
+}
+
+func transitionOrder(tx *sql.Tx, transition models.OrderTransition) error {
+	result, err := tx.Exec(`UPDATE orders
+        SET status = $3,
+            cancelled_at = CASE WHEN $3 = $4 THEN $5 ELSE cancelled_at END
+        WHERE id = $1 AND status = $2;
+    `, transition.OrderId, string(transition.FromStatus), string(transition.ToStatus), string(models.OrderCancelled), transition.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("transition order: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("transition order: %w", err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: order is no longer %s", models.ErrInvalidOrderTransition, transition.FromStatus)
+	}
+
+	if err := insertOrderHistory(tx, transition); err != nil {
+		return fmt.Errorf("transition order: %w", err)
+	}
+
+	// the hold is of no use once the order is no longer waiting on its payment
+	if transition.FromStatus == models.OrderPendingPayment {
+		if _, err := tx.Exec(`DELETE FROM holds WHERE order_id = $1;`, transition.OrderId); err != nil {
+			return fmt.Errorf("transition order: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func insertRefundRequest(tx *sql.Tx, refund models.RefundRequest) error {
+	_, err := tx.Exec(`INSERT INTO refund_requests(id, order_id, amount, created_at) VALUES ($1, $2, $3, $4);`,
+		refund.ID, refund.OrderId, refund.Amount, refund.CreatedAt)
+	return err
+}
+
+func (ordersRepo *ordersRepository) GetHistory(orderId string) ([]models.OrderTransition, error) {
+	rows, err := ordersRepo.db.Query(`SELECT id, order_id, from_status, to_status, actor, note, details, created_at
+        FROM order_history
+        WHERE order_id = $1
+        ORDER BY created_at;
+    `, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("get order history: %w", err)
+	}
+	defer rows.Close()
+
+	history := make([]models.OrderTransition, 0, 5)
+	for rows.Next() {
+		var transition models.OrderTransition
+		var fromStatus sql.NullString
+		err := rows.Scan(&transition.ID, &transition.OrderId, &fromStatus, &transition.ToStatus, &transition.Actor, &transition.Note, &transition.Details, &transition.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("get order history: %w", err)
+		}
+		transition.FromStatus = models.OrderStatus(fromStatus.String)
+		history = append(history, transition)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("get order history: %w", rows.Err())
+	}
+	return history, nil
+}
+
+func insertOrderHistory(tx *sql.Tx, transition models.OrderTransition) error {
+	var fromStatus sql.NullString
+	if transition.FromStatus != "" {
+		fromStatus = sql.NullString{String: string(transition.FromStatus), Valid: true}
+	}
+
+	var details any
+	if len(transition.Details) > 0 {
+		details = transition.Details
+	}
+
+	_, err := tx.Exec(`INSERT INTO order_history(id, order_id, from_status, to_status, actor, note, details, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+    `, transition.ID, transition.OrderId, fromStatus, string(transition.ToStatus), transition.Actor, transition.Note, details, transition.CreatedAt)
+	return err
+}
+
+// Reschedule records the reschedule of the order, moving the order to its new booking right away unless the
+// reschedule waits on its top up payment.
+// The unique index on the active orders keeps the move atomic, models.ErrSlotUnavailable is returned
+// when the new booking is already taken, models.ErrSlotClosed when its slot no longer runs on the date and
+// models.ErrOrderNotReschedulable when the order changed meanwhile.
+func (ordersRepo *ordersRepository) Reschedule(reschedule models.Reschedule) error {
+	tx, err := ordersRepo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("reschedule order: %w", err)
+	}
+	defer tx.Rollback()
+
+	if reschedule.Status == models.RescheduleApplied {
+		if err := moveOrder(tx, reschedule, reschedule.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	var topUpRazorpayOrderId sql.NullString
+	if reschedule.TopUpRazorpayOrderId != "" {
+		topUpRazorpayOrderId = sql.NullString{String: reschedule.TopUpRazorpayOrderId, Valid: true}
+	}
+
+	_, err = tx.Exec(`INSERT INTO order_reschedules(
+    id, order_id, from_theatre_id, from_slot_id, from_order_date, to_theatre_id, to_slot_id, to_order_date, price_difference, razorpay_order_id, actor, created_at, status, price_breakdown)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14);
+    `, reschedule.ID, reschedule.OrderId, reschedule.From.TheatreId, reschedule.From.SlotId, reschedule.From.OrderDate.Format(time.DateOnly),
+		reschedule.To.TheatreId, reschedule.To.SlotId, reschedule.To.OrderDate.Format(time.DateOnly),
+		reschedule.PriceDifference, topUpRazorpayOrderId, reschedule.Actor, reschedule.CreatedAt, string(reschedule.Status), reschedule.PriceBreakdown)
+	if err != nil {
+		return fmt.Errorf("reschedule order: %w", err)
+	}
+
+	// a cheaper booking owes the difference back, under the reschedule id
+	if reschedule.Status == models.RescheduleApplied && reschedule.PriceDifference < 0 {
+		err = insertRefundRequest(tx, models.RefundRequest{
+			ID:        reschedule.ID,
+			OrderId:   reschedule.OrderId,
+			Amount:    -reschedule.PriceDifference,
+			CreatedAt: reschedule.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("reschedule order: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("reschedule order: %w", err)
+	}
+	return nil
+}
+
+// ApplyReschedule moves the order to the booking of the reschedule paid with the razorpay top up order and
+// releases the hold on it. Reschedules already applied are left as they are, and sql.ErrNoRows is returned
+// when the razorpay order is not the top up of a reschedule.
+// When the order can no longer move, the reschedule is marked failed and models.ErrRescheduleFailed is returned.
+func (ordersRepo *ordersRepository) ApplyReschedule(razorpayOrderId string, at time.Time) error {
+	tx, err := ordersRepo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("apply reschedule: %w", err)
+	}
+	defer tx.Rollback()
+
+	var reschedule models.Reschedule
+	row := tx.QueryRow(`SELECT id, order_id, from_theatre_id, from_slot_id, from_order_date, to_theatre_id, to_slot_id, to_order_date,
+            price_difference, price_breakdown, actor, status
+        FROM order_reschedules
+        WHERE razorpay_order_id = $1
+        FOR UPDATE;
+    `, razorpayOrderId)
+	err = row.Scan(&reschedule.ID, &reschedule.OrderId, &reschedule.From.TheatreId, &reschedule.From.SlotId, &reschedule.From.OrderDate,
+		&reschedule.To.TheatreId, &reschedule.To.SlotId, &reschedule.To.OrderDate, &reschedule.PriceDifference, &reschedule.PriceBreakdown,
+		&reschedule.Actor, &reschedule.Status)
+	if err != nil {
+		return fmt.Errorf("apply reschedule: %w", err)
+	}
+
+	switch reschedule.Status {
+	case models.RescheduleApplied:
+		return nil
+	case models.RescheduleFailed:
+		return models.ErrRescheduleFailed
+	}
+
+	err = moveOrder(tx, reschedule, at)
+	if errors.Is(err, models.ErrSlotUnavailable) || errors.Is(err, models.ErrSlotClosed) || errors.Is(err, models.ErrOrderNotReschedulable) {
+		tx.Rollback()
+		_, failErr := ordersRepo.db.Exec(`UPDATE order_reschedules SET status = $2 WHERE id = $1 AND status = $3;`,
+			reschedule.ID, string(models.RescheduleFailed), string(models.ReschedulePendingPayment))
+		if failErr != nil {
+			return fmt.Errorf("apply reschedule: %w", errors.Join(err, failErr))
+		}
+		return fmt.Errorf("%w: %w", models.ErrRescheduleFailed, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE order_reschedules SET status = $2 WHERE id = $1;`, reschedule.ID, string(models.RescheduleApplied))
+	if err != nil {
+		return fmt.Errorf("apply reschedule: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM holds WHERE order_id = $1;`, reschedule.OrderId); err != nil {
+		return fmt.Errorf("apply reschedule: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("apply reschedule: %w", err)
+	}
+	return nil
+}
+
+// moveOrder moves the confirmed order from the booking of the reschedule to its new one and records it in the order history
+func moveOrder(tx *sql.Tx, reschedule models.Reschedule, at time.Time) error {
+	if err := checkSlotRuns(tx, reschedule.To.TheatreId, reschedule.To.SlotId, reschedule.To.OrderDate, "reschedule order"); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE orders
+        SET theatre_id = $5, slot_id = $6, order_date = $7, total_price = $8, price_breakdown = $9
+        WHERE id = $1 AND theatre_id = $2 AND slot_id = $3 AND order_date = $4 AND status = $10;
+    `, reschedule.OrderId, reschedule.From.TheatreId, reschedule.From.SlotId, reschedule.From.OrderDate.Format(time.DateOnly),
+		reschedule.To.TheatreId, reschedule.To.SlotId, reschedule.To.OrderDate.Format(time.DateOnly),
+		reschedule.PriceBreakdown.TotalInRupees(), reschedule.PriceBreakdown, string(models.OrderConfirmed))
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return models.ErrSlotUnavailable
+		}
+		return fmt.Errorf("reschedule order: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("reschedule order: %w", err)
+	}
+	if affected == 0 {
+		return models.ErrOrderNotReschedulable
+	}
+
+	err = insertOrderHistory(tx, models.OrderTransition{
+		ID:         uuid.NewString(),
+		OrderId:    reschedule.OrderId,
+		FromStatus: models.OrderConfirmed,
+		ToStatus:   models.OrderConfirmed,
+		Actor:      reschedule.Actor,
+		Note:       "rescheduled",
+		Details: models.MetaData{
+			"reschedule_id":    reschedule.ID,
+			"from":             reschedule.From,
+			"to":               reschedule.To,
+			"price_difference": reschedule.PriceDifference,
+		},
+		CreatedAt: at,
+	})
+	if err != nil {
+		return fmt.Errorf("reschedule order: %w", err)
+	}
+	return nil
+}
+
 func (ordersRepo *ordersRepository) Create(order models.Order) error {
 	tx, err := ordersRepo.db.Begin()
 
@@ -47,24 +422,58 @@ func (ordersRepo *ordersRepository) Create(order models.Order) error {
 	}
 	defer tx.Rollback()
 
+	if err := checkSlotRuns(tx, order.TheatreId, order.SlotId, order.OrderDate, "create order"); err != nil {
+		return err
+	}
+
 	row := tx.QueryRow(`INSERT INTO orders(
-    id,customer_name,customer_email,phone_number,no_of_persons,total_price,order_date,theatre_id, slot_id, razorpay_order_id) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10) RETURNING ordered_at;`, order.ID, order.CustomerName, order.CustomerEmail, order.PhoneNumber, order.NoOfPersons, order.TotalPrice, order.OrderDate.Format(time.DateOnly), order.TheatreId, order.SlotId, order.RazorpayOrderId)
+    id,customer_name,customer_email,phone_number,no_of_persons,total_price,order_date,theatre_id, slot_id, razorpay_order_id, price_breakdown, status, user_id) VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13) RETURNING ordered_at;`, order.ID, order.CustomerName, order.CustomerEmail, order.PhoneNumber, order.NoOfPersons, order.TotalPrice, order.OrderDate.Format(time.DateOnly), order.TheatreId, order.SlotId, order.RazorpayOrderId, order.PriceBreakdown, string(order.Status), nullString(order.UserId))
 
 	if err := row.Scan(&order.OrderedAt); err != nil {
 		return fmt.Errorf("create order: %w", err)
 	}
 
-	stmt, err := tx.Prepare("INSERT INTO order_addons(order_id, addon_id, quantity) VALUES ($1,$2,$3);")
+	stmt, err := tx.Prepare("INSERT INTO order_addons(order_id, addon_id, quantity, unit_price) VALUES ($1,$2,$3,$4);")
 	if err != nil {
 		return fmt.Errorf("create order: %w", err)
 	}
 
+	// the unit prices are kept with the order, so later price changes of the addons do not change it
 	for _, addon := range order.Addons {
-		_, err = stmt.Exec(order.ID, addon.ID, addon.Quantity)
+		var unitPrice int
+		var ok bool
+		if order.PriceBreakdown != nil {
+			unitPrice, ok = order.PriceBreakdown.AddonUnitPrice(addon.ID)
+		}
+		if !ok {
+			return fmt.Errorf("create order: addon %s is not priced", addon.ID)
+		}
+
+		_, err = stmt.Exec(order.ID, addon.ID, addon.Quantity, models.ToRupees(unitPrice))
 		if err != nil {
 			return fmt.Errorf("create order: %w", err)
 		}
 	}
+
+	if order.PriceBreakdown != nil {
+		if discount, ok := order.PriceBreakdown.CouponDiscount(); ok {
+			if err := redeemCoupon(tx, order, discount); err != nil {
+				return fmt.Errorf("create order: %w", err)
+			}
+		}
+	}
+
+	err = insertOrderHistory(tx, models.OrderTransition{
+		ID:        uuid.NewString(),
+		OrderId:   order.ID,
+		ToStatus:  order.Status,
+		Actor:     models.SystemActor,
+		CreatedAt: order.OrderedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("create order: %w", err)
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		return fmt.Errorf("create order: %w", err)
@@ -73,7 +482,59 @@ func (ordersRepo *ordersRepository) Create(order models.Order) error {
 	return nil
 }
 
-func (ordersRepo *ordersRepository) GetAll() ([]models.OrderDetails, error) {
+// orderSortColumns maps the sort fields to their columns along with the type their cursor values are cast to
+var orderSortColumns = map[models.OrderSortField][2]string{
+	models.SortByOrderedAt:  {"orders.ordered_at", "timestamp"},
+	models.SortByOrderDate:  {"orders.order_date", "date"},
+	models.SortByTotalPrice: {"orders.total_price", "integer"},
+}
+
+// ordersFilterQuery builds the conditions of the filter, leaving out its cursor
+func ordersFilterQuery(filter models.OrdersFilter) *queryBuilder {
+	qb := &queryBuilder{}
+	qb.whereIf(filter.TheatreId != "", "orders.theatre_id = ?", filter.TheatreId).
+		whereIf(filter.SlotId != "", "orders.slot_id = ?", filter.SlotId).
+		whereIf(filter.PaymentStatus != "", "payments.status = ?", string(filter.PaymentStatus)).
+		whereIf(filter.Status != "", "orders.status = ?", string(filter.Status)).
+		whereIf(filter.CustomerEmail != "", "LOWER(orders.customer_email) = LOWER(?)", filter.CustomerEmail).
+		whereIf(filter.PhoneNumber != "", "orders.phone_number = ?", filter.PhoneNumber).
+		whereIf(filter.CustomerUserId != "", "(orders.user_id = ? OR LOWER(orders.customer_email) = (SELECT LOWER(email) FROM users WHERE id = ? AND email_verified_at IS NOT NULL))", filter.CustomerUserId, filter.CustomerUserId)
+	if filter.From != nil {
+		qb.where("orders.order_date >= ?", filter.From.Format(time.DateOnly))
+	}
+	if filter.To != nil {
+		qb.where("orders.order_date <= ?", filter.To.Format(time.DateOnly))
+	}
+	return qb
+}
+
+// List returns a page of the orders matching the filter along with the count of all matching orders.
+// Pages are keyed on the sort column and the order id, so orders added meanwhile do not shift the pages.
+func (ordersRepo *ordersRepository) List(filter models.OrdersFilter) (*models.OrdersPage, error) {
+	filterQuery := ordersFilterQuery(filter)
+
+	page := models.OrdersPage{
+		Orders: make([]models.OrderDetails, 0, filter.Limit),
+	}
+	row := ordersRepo.db.QueryRow(`SELECT COUNT(*) FROM orders
+	JOIN payments ON
+		orders.razorpay_order_id = payments.razorpay_order_id`+filterQuery.whereClause()+`;`, filterQuery.args...)
+	if err := row.Scan(&page.TotalCount); err != nil {
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+
+	sortColumn, castType := orderSortColumns[filter.SortBy][0], orderSortColumns[filter.SortBy][1]
+	direction, comparison := "ASC", ">"
+	if filter.Descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	pageQuery := filterQuery.clone()
+	if filter.Cursor != nil {
+		pageQuery.where(fmt.Sprintf("(%s, orders.id) %s (?::%s, ?::uuid)", sortColumn, comparison, castType), filter.Cursor.Value, filter.Cursor.ID)
+	}
+	// one more order than needed tells whether there is a next page
+	limit := pageQuery.placeholder(filter.Limit + 1)
 
 	rows, err := ordersRepo.db.Query(`SELECT
 		orders.id,
@@ -84,6 +545,10 @@ func (ordersRepo *ordersRepository) GetAll() ([]models.OrderDetails, error) {
 		orders.total_price,
 		orders.order_date,
 		orders.ordered_at,
+		orders.price_breakdown,
+		orders.cancelled_at,
+		orders.status,
+		orders.user_id,
 		theatres.id,
 		theatres."name" ,
 		theatres.description ,
@@ -92,9 +557,10 @@ func (ordersRepo *ordersRepository) GetAll() ([]models.OrderDetails, error) {
 		theatres.max_capacity ,
 		theatres.min_capacity ,
 		theatres.default_capacity ,
+		theatres.timezone,
 		slots.id ,
-		slots.start_time ,
-		slots.end_time,
+		slots.start_minute,
+		slots.end_minute,
 		payments.razorpay_order_id,
 		payments.razorpay_payment_id,
 		payments.razorpay_signature,
@@ -106,38 +572,68 @@ func (ordersRepo *ordersRepository) GetAll() ([]models.OrderDetails, error) {
 	JOIN slots ON
 		slots.id = orders.slot_id
 	JOIN payments ON
-		orders.razorpay_order_id = payments.razorpay_order_id;`)
+		orders.razorpay_order_id = payments.razorpay_order_id`+pageQuery.whereClause()+`
+	ORDER BY `+sortColumn+` `+direction+`, orders.id `+direction+`
+	LIMIT `+limit+`;`, pageQuery.args...)
 
 	if err != nil {
-		return nil, fmt.Errorf("get orders: %w", err)
+		return nil, fmt.Errorf("list orders: %w", err)
 	}
 	defer rows.Close()
 
-	orderDetailsList := make([]models.OrderDetails, 0, 5)
+	orderIds := make([]string, 0, filter.Limit)
 	for rows.Next() {
 		var orderDetails models.OrderDetails
-		err := rows.Scan(&orderDetails.ID, &orderDetails.CustomerName, &orderDetails.CustomerEmail, &orderDetails.PhoneNumber, &orderDetails.NoOfPersons, &orderDetails.TotalPrice, &orderDetails.OrderDate, &orderDetails.OrderedAt, &orderDetails.Theatre.ID, &orderDetails.Theatre.Name, &orderDetails.Theatre.Description, &orderDetails.Theatre.Price, &orderDetails.Theatre.AdditionalPricePerHead, &orderDetails.Theatre.MaxCapacity, &orderDetails.Theatre.MinCapacity, &orderDetails.Theatre.DefaultCapacity, &orderDetails.Slot.ID, &orderDetails.Slot.StartTime, &orderDetails.Slot.EndTime, &orderDetails.PaymentDetails.RazorpayOrderId, &orderDetails.PaymentDetails.RazorpayPaymentId, &orderDetails.PaymentDetails.RazorpaySignature, &orderDetails.PaymentDetails.Status)
+		err := rows.Scan(&orderDetails.ID, &orderDetails.CustomerName, &orderDetails.CustomerEmail, &orderDetails.PhoneNumber, &orderDetails.NoOfPersons, &orderDetails.TotalPrice, &orderDetails.OrderDate, &orderDetails.OrderedAt, &orderDetails.PriceBreakdown, &orderDetails.CancelledAt, &orderDetails.Status, &orderDetails.UserId, &orderDetails.Theatre.ID, &orderDetails.Theatre.Name, &orderDetails.Theatre.Description, &orderDetails.Theatre.Price, &orderDetails.Theatre.AdditionalPricePerHead, &orderDetails.Theatre.MaxCapacity, &orderDetails.Theatre.MinCapacity, &orderDetails.Theatre.DefaultCapacity, &orderDetails.Theatre.Timezone, &orderDetails.Slot.ID, &orderDetails.Slot.StartTime, &orderDetails.Slot.EndTime, &orderDetails.PaymentDetails.RazorpayOrderId, &orderDetails.PaymentDetails.RazorpayPaymentId, &orderDetails.PaymentDetails.RazorpaySignature, &orderDetails.PaymentDetails.Status)
 
 		if err != nil {
-			return nil, fmt.Errorf("get orders: %w", err)
+			return nil, fmt.Errorf("list orders: %w", err)
 		}
 
-		addons, err := ordersRepo.getAddonsForOrder(orderDetails.ID)
-
-		if err != nil {
-			return nil, fmt.Errorf("get orders: %w", err)
-		}
+		page.Orders = append(page.Orders, orderDetails)
+		orderIds = append(orderIds, orderDetails.ID)
+	}
 
-		orderDetails.Addons = addons
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("list orders: %w", rows.Err())
+	}
 
-		orderDetailsList = append(orderDetailsList, orderDetails)
+	if len(page.Orders) > filter.Limit {
+		page.Orders = page.Orders[:filter.Limit]
+		orderIds = orderIds[:filter.Limit]
+
+		last := page.Orders[len(page.Orders)-1]
+		page.NextCursor = models.OrdersCursor{
+			SortBy:     filter.SortBy,
+			Descending: filter.Descending,
+			Value:      orderSortValue(last, filter.SortBy),
+			ID:         last.ID,
+		}.Encode()
 	}
 
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("get orders: %w", rows.Err())
+	addons, err := ordersRepo.getAddonsForOrders(orderIds)
+	if err != nil {
+		return nil, fmt.Errorf("list orders: %w", err)
+	}
+	for i := range page.Orders {
+		page.Orders[i].Addons = addons[page.Orders[i].ID]
+		if page.Orders[i].Addons == nil {
+			page.Orders[i].Addons = []models.OrderAddonDetails{}
+		}
 	}
 
-	return orderDetailsList, nil
+	return &page, nil
+}
+
+func orderSortValue(order models.OrderDetails, sortBy models.OrderSortField) string {
+	switch sortBy {
+	case models.SortByOrderDate:
+		return order.OrderDate.Format(time.DateOnly)
+	case models.SortByTotalPrice:
+		return strconv.Itoa(order.TotalPrice)
+	default:
+		return order.OrderedAt.Format("2006-01-02 15:04:05.999999")
+	}
 }
 
 func (ordersRepo *ordersRepository) GetById(id string) (*models.OrderDetails, error) {
@@ -150,6 +646,10 @@ func (ordersRepo *ordersRepository) GetById(id string) (*models.OrderDetails, er
 		orders.total_price,
 		orders.order_date,
 		orders.ordered_at,
+		orders.price_breakdown,
+		orders.cancelled_at,
+		orders.status,
+		orders.user_id,
 		theatres.id,
 		theatres."name" ,
 		theatres.description ,
@@ -161,14 +661,15 @@ func (ordersRepo *ordersRepository) GetById(id string) (*models.OrderDetails, er
 		theatres.created_at,
 		theatres.updated_at,
 		theatres.created_by,
-		theatres.updated_by
+		theatres.updated_by,
+		theatres.timezone,
 		slots.id ,
-		slots.start_time ,
-		slots.end_time,
+		slots.start_minute,
+		slots.end_minute,
 		slots.created_at,
 		slots.updated_at,
 		slots.created_by,
-		slots.updated_by
+		slots.updated_by,
 		payments.razorpay_order_id,
 		payments.razorpay_payment_id,
 		payments.razorpay_signature,
@@ -179,10 +680,12 @@ func (ordersRepo *ordersRepository) GetById(id string) (*models.OrderDetails, er
 		orders.theatre_id = theatres.id
 	JOIN slots ON
 		slots.id = orders.slot_id
+	JOIN payments ON
+		orders.razorpay_order_id = payments.razorpay_order_id
 	WHERE orders.id=$1;`, id)
 
 	var orderDetails models.OrderDetails
-	err := row.Scan(&orderDetails.ID, &orderDetails.CustomerName, &orderDetails.CustomerEmail, &orderDetails.PhoneNumber, &orderDetails.NoOfPersons, &orderDetails.TotalPrice, &orderDetails.OrderDate, &orderDetails.OrderedAt, &orderDetails.Theatre.ID, &orderDetails.Theatre.Name, &orderDetails.Theatre.Description, &orderDetails.Theatre.Price, &orderDetails.Theatre.AdditionalPricePerHead, &orderDetails.Theatre.MaxCapacity, &orderDetails.Theatre.MinCapacity, &orderDetails.Theatre.DefaultCapacity, &orderDetails.Theatre.CreatedAt, &orderDetails.Theatre.UpdatedAt, &orderDetails.Theatre.CreatedBy, &orderDetails.Theatre.UpdatedBy, &orderDetails.Slot.ID, &orderDetails.Slot.StartTime, &orderDetails.Slot.EndTime, &orderDetails.Slot.CreatedAt, &orderDetails.Slot.UpdatedAt, &orderDetails.Slot.CreatedBy, &orderDetails.Slot.UpdatedBy, &orderDetails.PaymentDetails.RazorpayOrderId, &orderDetails.PaymentDetails.RazorpayPaymentId, &orderDetails.PaymentDetails.RazorpaySignature, &orderDetails.PaymentDetails.Status)
+	err := row.Scan(&orderDetails.ID, &orderDetails.CustomerName, &orderDetails.CustomerEmail, &orderDetails.PhoneNumber, &orderDetails.NoOfPersons, &orderDetails.TotalPrice, &orderDetails.OrderDate, &orderDetails.OrderedAt, &orderDetails.PriceBreakdown, &orderDetails.CancelledAt, &orderDetails.Status, &orderDetails.UserId, &orderDetails.Theatre.ID, &orderDetails.Theatre.Name, &orderDetails.Theatre.Description, &orderDetails.Theatre.Price, &orderDetails.Theatre.AdditionalPricePerHead, &orderDetails.Theatre.MaxCapacity, &orderDetails.Theatre.MinCapacity, &orderDetails.Theatre.DefaultCapacity, &orderDetails.Theatre.CreatedAt, &orderDetails.Theatre.UpdatedAt, &orderDetails.Theatre.CreatedBy, &orderDetails.Theatre.UpdatedBy, &orderDetails.Theatre.Timezone, &orderDetails.Slot.ID, &orderDetails.Slot.StartTime, &orderDetails.Slot.EndTime, &orderDetails.Slot.CreatedAt, &orderDetails.Slot.UpdatedAt, &orderDetails.Slot.CreatedBy, &orderDetails.Slot.UpdatedBy, &orderDetails.PaymentDetails.RazorpayOrderId, &orderDetails.PaymentDetails.RazorpayPaymentId, &orderDetails.PaymentDetails.RazorpaySignature, &orderDetails.PaymentDetails.Status)
 
 	if err != nil {
 		return nil, fmt.Errorf("get orders: %w", err)
@@ -196,46 +699,107 @@ func (ordersRepo *ordersRepository) GetById(id string) (*models.OrderDetails, er
 
 	orderDetails.Addons = addons
 
+	orderDetails.Payments, err = ordersRepo.getPaymentsForOrder(orderDetails.ID)
+	if err != nil {
+		return nil, fmt.Errorf("get orders: %w", err)
+	}
+
 	return &orderDetails, nil
 }
 
+// getPaymentsForOrder loads the captured payments of the order, its first payment and the top ups of
+// its applied reschedules, in the order they were made
+func (ordersRepo *ordersRepository) getPaymentsForOrder(id string) ([]models.CapturedPayment, error) {
+	rows, err := ordersRepo.db.Query(`SELECT
+		payments.razorpay_order_id,
+		payments.razorpay_payment_id,
+		payments.amount,
+		(SELECT COALESCE(SUM(refunds.amount), 0) FROM refunds WHERE refunds.razorpay_order_id = payments.razorpay_order_id)
+	FROM
+		payments
+	JOIN (
+		SELECT razorpay_order_id, ordered_at AS paid_at FROM orders WHERE id = $1
+		UNION ALL
+		SELECT razorpay_order_id, created_at FROM order_reschedules WHERE order_id = $1 AND status = $2 AND razorpay_order_id IS NOT NULL
+	) order_payments ON
+		order_payments.razorpay_order_id = payments.razorpay_order_id
+	WHERE payments.status IN ($3, $4)
+	ORDER BY order_payments.paid_at;`, id, string(models.RescheduleApplied), string(models.Success), string(models.Refunded))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	payments := make([]models.CapturedPayment, 0, 1)
+	for rows.Next() {
+		var payment models.CapturedPayment
+		if err := rows.Scan(&payment.RazorpayOrderId, &payment.RazorpayPaymentId, &payment.Amount, &payment.Refunded); err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return payments, nil
+}
+
 func (ordersRepo *ordersRepository) getAddonsForOrder(id string) ([]models.OrderAddonDetails, error) {
+	addons, err := ordersRepo.getAddonsForOrders([]string{id})
+	if err != nil {
+		return nil, err
+	}
+	if addons[id] == nil {
+		return []models.OrderAddonDetails{}, nil
+	}
+	return addons[id], nil
+}
+
+// getAddonsForOrders loads the addons of all the given orders in one query, keyed by the order id
+func (ordersRepo *ordersRepository) getAddonsForOrders(ids []string) (map[string][]models.OrderAddonDetails, error) {
+	addons := make(map[string][]models.OrderAddonDetails, len(ids))
+	if len(ids) == 0 {
+		return addons, nil
+	}
+
 	rows, err := ordersRepo.db.Query(`SELECT
+		order_addons.order_id,
 		addons.id,
 		addons.name,
 		addons.category,
 		addons.meta_data,
-		addons.price,
+		order_addons.unit_price,
 		addons.created_at,
 		addons.updated_at,
 		addons.created_by,
 		addons.updated_by,
+		addons.archived_at,
 		order_addons.quantity
 	FROM
 		order_addons
 	JOIN addons ON
 		order_addons.addon_id = addons.id
-	WHERE order_addons.order_id = $1;`, id)
+	WHERE order_addons.order_id = ANY($1::uuid[]);`, ids)
 
 	if err != nil {
-		return nil, fmt.Errorf("get orders: %w", err)
+		return nil, fmt.Errorf("get order addons: %w", err)
 	}
 	defer rows.Close()
 
-	addons := make([]models.OrderAddonDetails, 0, 5)
 	for rows.Next() {
+		var orderId string
 		var addonDetails models.OrderAddonDetails
-		err := rows.Scan(&addonDetails.ID, &addonDetails.Name, &addonDetails.Category, &addonDetails.MetaData, &addonDetails.Price, &addonDetails.CreatedAt, &addonDetails.UpdatedAt, &addonDetails.CreatedBy, &addonDetails.UpdatedBy, &addonDetails.Quantity)
+		err := rows.Scan(&orderId, &addonDetails.ID, &addonDetails.Name, &addonDetails.Category, &addonDetails.MetaData, &addonDetails.Price, &addonDetails.CreatedAt, &addonDetails.UpdatedAt, &addonDetails.CreatedBy, &addonDetails.UpdatedBy, &addonDetails.ArchivedAt, &addonDetails.Quantity)
 
 		if err != nil {
-			return nil, fmt.Errorf("get orders: %w", err)
+			return nil, fmt.Errorf("get order addons: %w", err)
 		}
-		addons = append(addons, addonDetails)
+		addons[orderId] = append(addons[orderId], addonDetails)
 	}
 
 	if rows.Err() != nil {
-		return nil, fmt.Errorf("get orders: %w", rows.Err())
+		return nil, fmt.Errorf("get order addons: %w", rows.Err())
 	}
 
-	return addons, err
+	return addons, nil
 }
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type PasswordResetsRepository interface {
+	Create(reset models.PasswordReset) error
+	GetByHash(tokenHash string) (*models.PasswordReset, error)
+	Redeem(reset models.PasswordReset, passwordHash string, usedAt time.Time) (bool, error)
+}
+
+type passwordResetsRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetsRepository(db *sql.DB) PasswordResetsRepository {
+	return &passwordResetsRepository{
+		db: db,
+	}
+}
+
+func (prr *passwordResetsRepository) Create(reset models.PasswordReset) error {
+	_, err := prr.db.Exec(`INSERT INTO password_resets(id, user_id, token_hash, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5);
+    `, reset.ID, reset.UserId, reset.TokenHash, reset.ExpiresAt, reset.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create password reset: %w", err)
+	}
+	return nil
+}
+
+func (prr *passwordResetsRepository) GetByHash(tokenHash string) (*models.PasswordReset, error) {
+	row := prr.db.QueryRow(`SELECT id, user_id, token_hash, expires_at, used_at, created_at
+        FROM password_resets
+        WHERE token_hash = $1;
+    `, tokenHash)
+
+	var reset models.PasswordReset
+	err := row.Scan(&reset.ID, &reset.UserId, &reset.TokenHash, &reset.ExpiresAt, &reset.UsedAt, &reset.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get password reset: %w", err)
+	}
+	return &reset, nil
+}
+
+// Redeem sets the new password of the user and uses up all of the user's reset tokens. The email is verified
+// along the way, since the token was delivered to it. It reports false when the token was already used.
+func (prr *passwordResetsRepository) Redeem(reset models.PasswordReset, passwordHash string, usedAt time.Time) (bool, error) {
+	tx, err := prr.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("redeem password reset: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE password_resets SET used_at = $2
+        WHERE id = $1 AND used_at IS NULL;
+    `, reset.ID, usedAt)
+	if err != nil {
+		return false, fmt.Errorf("redeem password reset: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("redeem password reset: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	_, err = tx.Exec(`UPDATE password_resets SET used_at = $2
+        WHERE user_id = $1 AND used_at IS NULL;
+    `, reset.UserId, usedAt)
+	if err != nil {
+		return false, fmt.Errorf("redeem password reset: %w", err)
+	}
+
+	_, err = tx.Exec(`UPDATE users SET password = $2, email_verified_at = COALESCE(email_verified_at, $3)
+        WHERE id = $1;
+    `, reset.UserId, passwordHash, usedAt)
+	if err != nil {
+		return false, fmt.Errorf("redeem password reset: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("redeem password reset: %w", err)
+	}
+	return true, nil
+}
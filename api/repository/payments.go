@@ -2,11 +2,26 @@ package repository
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
 )
 
 type PaymentsRepository interface {
-	Create(orderId, status string) error
+	Create(orderId, status string, amount int) error
+	GetPayment(orderId string) (*models.CapturedPayment, error)
 	Update(orderId, signature, paymentId string) error
+	MarkCaptured(orderId, paymentId string) error
+	MarkFailed(orderId, paymentId string) error
+	MarkRefunded(paymentId string) error
+	CreateRefund(refund models.Refund) error
+	GetRefund(refundId string) (*models.Refund, error)
+	GetRefundRequest(id string) (*models.RefundRequest, error)
+	HasPendingRefunds(orderId string) (bool, error)
+	UpdateRefundStatus(refundId, status string) error
+	RecordWebhookEvent(eventId, event string, receivedAt time.Time) (bool, error)
+	DeleteWebhookEvent(eventId string) error
 }
 
 type paymentsRepository struct {
@@ -19,14 +34,29 @@ func NewPaymentsRepository(db *sql.DB) PaymentsRepository {
 	}
 }
 
-func (pr *paymentsRepository) Create(orderId, status string) error {
-	_, err := pr.db.Exec(`INSERT INTO payments(razorpay_order_id, status ,razorpay_payment_id, razorpay_signature)
-		VALUES ($1, $2, '' ,'')
-	`, orderId, status)
+func (pr *paymentsRepository) Create(orderId, status string, amount int) error {
+	_, err := pr.db.Exec(`INSERT INTO payments(razorpay_order_id, status ,razorpay_payment_id, razorpay_signature, amount)
+		VALUES ($1, $2, '' ,'', $3)
+	`, orderId, status, amount)
 
 	return err
 }
 
+// GetPayment returns the payment of the razorpay order along with how much of it is refunded
+func (pr *paymentsRepository) GetPayment(orderId string) (*models.CapturedPayment, error) {
+	row := pr.db.QueryRow(`SELECT razorpay_order_id, razorpay_payment_id, amount,
+            (SELECT COALESCE(SUM(refunds.amount), 0) FROM refunds WHERE refunds.razorpay_order_id = payments.razorpay_order_id)
+        FROM payments
+        WHERE razorpay_order_id = $1;
+    `, orderId)
+
+	var payment models.CapturedPayment
+	if err := row.Scan(&payment.RazorpayOrderId, &payment.RazorpayPaymentId, &payment.Amount, &payment.Refunded); err != nil {
+		return nil, fmt.Errorf("get payment: %w", err)
+	}
+	return &payment, nil
+}
+
 func (pr *paymentsRepository) Update(orderId, signature, paymentId string) error {
 	_, err := pr.db.Exec(`
         UPDATE payments
@@ -38,3 +68,164 @@ func (pr *paymentsRepository) Update(orderId, signature, paymentId string) error
 
 	return err
 }
+
+// MarkCaptured marks the payment of the razorpay order as successful, unless it is already settled
+func (pr *paymentsRepository) MarkCaptured(orderId, paymentId string) error {
+	_, err := pr.db.Exec(`
+        UPDATE payments
+        SET razorpay_payment_id=$2,
+            status=$3
+        WHERE razorpay_order_id = $1 AND status IN ($4, $5);
+    `, orderId, paymentId, string(models.Success), string(models.Pending), string(models.Failure))
+
+	if err != nil {
+		return fmt.Errorf("mark payment captured: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed marks the pending payment of the razorpay order as failed
+func (pr *paymentsRepository) MarkFailed(orderId, paymentId string) error {
+	_, err := pr.db.Exec(`
+        UPDATE payments
+        SET razorpay_payment_id=$2,
+            status=$3
+        WHERE razorpay_order_id = $1 AND status = $4;
+    `, orderId, paymentId, string(models.Failure), string(models.Pending))
+
+	if err != nil {
+		return fmt.Errorf("mark payment failed: %w", err)
+	}
+	return nil
+}
+
+func (pr *paymentsRepository) MarkRefunded(paymentId string) error {
+	_, err := pr.db.Exec(`
+        UPDATE payments
+        SET status=$2
+        WHERE razorpay_payment_id = $1;
+    `, paymentId, string(models.Refunded))
+
+	if err != nil {
+		return fmt.Errorf("mark payment refunded: %w", err)
+	}
+	return nil
+}
+
+// CreateRefund records the refund, a refund the gateway returned again for the same idempotency key is recorded once
+func (pr *paymentsRepository) CreateRefund(refund models.Refund) error {
+	_, err := pr.db.Exec(`INSERT INTO refunds(id, order_id, razorpay_order_id, razorpay_payment_id, amount, status, refund_request_id, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        ON CONFLICT (id) DO NOTHING;
+    `, refund.ID, refund.OrderId, refund.RazorpayOrderId, refund.RazorpayPaymentId, refund.Amount, refund.Status, nullString(refund.RefundRequestId), refund.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create refund: %w", err)
+	}
+	return nil
+}
+
+func (pr *paymentsRepository) GetRefund(refundId string) (*models.Refund, error) {
+	row := pr.db.QueryRow(`SELECT `+refundColumns+`
+        FROM refunds
+        WHERE id = $1;
+    `, refundId)
+
+	refund, err := scanRefund(row)
+	if err != nil {
+		return nil, fmt.Errorf("get refund: %w", err)
+	}
+	return refund, nil
+}
+
+// GetRefundRequest returns the refund request along with the refunds made for it so far
+func (pr *paymentsRepository) GetRefundRequest(id string) (*models.RefundRequest, error) {
+	var request models.RefundRequest
+	row := pr.db.QueryRow(`SELECT id, order_id, amount, created_at FROM refund_requests WHERE id = $1;`, id)
+	if err := row.Scan(&request.ID, &request.OrderId, &request.Amount, &request.CreatedAt); err != nil {
+		return nil, fmt.Errorf("get refund request: %w", err)
+	}
+
+	rows, err := pr.db.Query(`SELECT `+refundColumns+`
+        FROM refunds
+        WHERE refund_request_id = $1
+        ORDER BY created_at;
+    `, id)
+	if err != nil {
+		return nil, fmt.Errorf("get refund request: %w", err)
+	}
+	defer rows.Close()
+
+	request.Refunds = make([]models.Refund, 0, 1)
+	for rows.Next() {
+		refund, err := scanRefund(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get refund request: %w", err)
+		}
+		request.Refunds = append(request.Refunds, *refund)
+	}
+
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("get refund request: %w", rows.Err())
+	}
+	return &request, nil
+}
+
+const refundColumns = "id, order_id, razorpay_order_id, razorpay_payment_id, amount, status, COALESCE(refund_request_id, ''), created_at"
+
+func scanRefund(row rowScanner) (*models.Refund, error) {
+	var refund models.Refund
+	err := row.Scan(&refund.ID, &refund.OrderId, &refund.RazorpayOrderId, &refund.RazorpayPaymentId, &refund.Amount, &refund.Status, &refund.RefundRequestId, &refund.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &refund, nil
+}
+
+// HasPendingRefunds reports whether any refund of the order is yet to be processed,
+// or any of its refund requests is yet to be refunded in full
+func (pr *paymentsRepository) HasPendingRefunds(orderId string) (bool, error) {
+	var pending bool
+	row := pr.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM refunds WHERE order_id = $1 AND status <> $2)
+        OR EXISTS(
+            SELECT 1 FROM refund_requests
+            WHERE order_id = $1 AND amount > (SELECT COALESCE(SUM(amount), 0) FROM refunds WHERE refund_request_id = refund_requests.id)
+        );
+    `, orderId, models.RefundProcessed)
+	if err := row.Scan(&pending); err != nil {
+		return false, fmt.Errorf("check pending refunds: %w", err)
+	}
+	return pending, nil
+}
+
+func (pr *paymentsRepository) UpdateRefundStatus(refundId, status string) error {
+	_, err := pr.db.Exec(`UPDATE refunds SET status=$2 WHERE id = $1;`, refundId, status)
+	if err != nil {
+		return fmt.Errorf("update refund status: %w", err)
+	}
+	return nil
+}
+
+// RecordWebhookEvent stores the webhook event id, reporting false when the event was already recorded
+func (pr *paymentsRepository) RecordWebhookEvent(eventId, event string, receivedAt time.Time) (bool, error) {
+	result, err := pr.db.Exec(`
+        INSERT INTO webhook_events(id, event, received_at) VALUES ($1, $2, $3)
+        ON CONFLICT (id) DO NOTHING;
+    `, eventId, event, receivedAt)
+	if err != nil {
+		return false, fmt.Errorf("record webhook event: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("record webhook event: %w", err)
+	}
+	return affected > 0, nil
+}
+
+func (pr *paymentsRepository) DeleteWebhookEvent(eventId string) error {
+	_, err := pr.db.Exec(`DELETE FROM webhook_events WHERE id = $1;`, eventId)
+	if err != nil {
+		return fmt.Errorf("delete webhook event: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type PricingRulesRepository interface {
+	GetAll() ([]models.PricingRule, error)
+	GetById(id string) (*models.PricingRule, error)
+	GetForTheatre(theatreId string) ([]models.PricingRule, error)
+	Create(rule models.PricingRule) error
+	Update(rule models.PricingRule) error
+	Delete(id string) error
+}
+
+const pricingRuleColumns = `id, name, theatre_id, slot_id, weekdays, TO_CHAR(valid_from, 'YYYY-MM-DD'), TO_CHAR(valid_to, 'YYYY-MM-DD'),
+	min_lead_days, max_lead_days, adjustment_type, adjustment, priority, exclusive, created_at, updated_at, created_by, updated_by`
+
+// pricingRulesOrder is the order the rules are applied in
+const pricingRulesOrder = `ORDER BY priority DESC, created_at, id`
+
+type pricingRulesRepository struct {
+	db *sql.DB
+}
+
+func NewPricingRulesRepository(db *sql.DB) PricingRulesRepository {
+	return &pricingRulesRepository{
+		db: db,
+	}
+}
+
+func (pr *pricingRulesRepository) GetAll() ([]models.PricingRule, error) {
+	rules, err := pr.queryRules(`SELECT ` + pricingRuleColumns + ` FROM pricing_rules ` + pricingRulesOrder + `;`)
+	if err != nil {
+		return nil, fmt.Errorf("get pricing rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (pr *pricingRulesRepository) GetById(id string) (*models.PricingRule, error) {
+	row := pr.db.QueryRow(`SELECT `+pricingRuleColumns+` FROM pricing_rules WHERE id = $1;`, id)
+	rule, err := scanPricingRule(row)
+	if err != nil {
+		return nil, fmt.Errorf("get pricing rule: %w", err)
+	}
+	return rule, nil
+}
+
+// GetForTheatre returns the rules of the theatre and the rules of every theatre, in the order they are applied
+func (pr *pricingRulesRepository) GetForTheatre(theatreId string) ([]models.PricingRule, error) {
+	rules, err := pr.queryRules(`
+		SELECT `+pricingRuleColumns+` FROM pricing_rules
+		WHERE theatre_id IS NULL OR theatre_id = $1
+		`+pricingRulesOrder+`;
+	`, theatreId)
+	if err != nil {
+		return nil, fmt.Errorf("get theatre pricing rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (pr *pricingRulesRepository) Create(rule models.PricingRule) error {
+	_, err := pr.db.Exec(`
+		INSERT INTO pricing_rules(id, name, theatre_id, slot_id, weekdays, valid_from, valid_to, min_lead_days, max_lead_days,
+			adjustment_type, adjustment, priority, exclusive, created_at, updated_at, created_by, updated_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17);
+	`, rule.ID, rule.Name, rule.TheatreId, rule.SlotId, rule.Weekdays, rule.ValidFrom, rule.ValidTo, rule.MinLeadDays, rule.MaxLeadDays,
+		rule.AdjustmentType, rule.Adjustment, rule.Priority, rule.Exclusive, rule.CreatedAt, rule.UpdatedAt, rule.CreatedBy, rule.UpdatedBy)
+	return pricingRuleError(err, "create pricing rule")
+}
+
+func (pr *pricingRulesRepository) Update(rule models.PricingRule) error {
+	result, err := pr.db.Exec(`
+		UPDATE pricing_rules SET name = $2, theatre_id = $3, slot_id = $4, weekdays = $5, valid_from = $6, valid_to = $7,
+			min_lead_days = $8, max_lead_days = $9, adjustment_type = $10, adjustment = $11, priority = $12, exclusive = $13,
+			updated_at = $14, updated_by = $15
+		WHERE id = $1;
+	`, rule.ID, rule.Name, rule.TheatreId, rule.SlotId, rule.Weekdays, rule.ValidFrom, rule.ValidTo, rule.MinLeadDays, rule.MaxLeadDays,
+		rule.AdjustmentType, rule.Adjustment, rule.Priority, rule.Exclusive, rule.UpdatedAt, rule.UpdatedBy)
+	if err := pricingRuleError(err, "update pricing rule"); err != nil {
+		return err
+	}
+	return checkUpdated(result, "update pricing rule")
+}
+
+// Delete removes the rule, the orders priced with it keep it in their price breakdown
+func (pr *pricingRulesRepository) Delete(id string) error {
+	result, err := pr.db.Exec(`DELETE FROM pricing_rules WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("delete pricing rule: %w", err)
+	}
+	return checkUpdated(result, "delete pricing rule")
+}
+
+func (pr *pricingRulesRepository) queryRules(query string, args ...any) ([]models.PricingRule, error) {
+	rows, err := pr.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []models.PricingRule{}
+	for rows.Next() {
+		rule, err := scanPricingRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// pricingRuleError returns models.ErrUnknownPricingTarget when the theatre or the slot of the rule does not exist
+func pricingRuleError(err error, action string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+		return models.ErrUnknownPricingTarget
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	return nil
+}
+
+func scanPricingRule(row rowScanner) (*models.PricingRule, error) {
+	var rule models.PricingRule
+	err := row.Scan(&rule.ID, &rule.Name, &rule.TheatreId, &rule.SlotId, pgtype.NewMap().SQLScanner(&rule.Weekdays), &rule.ValidFrom, &rule.ValidTo,
+		&rule.MinLeadDays, &rule.MaxLeadDays, &rule.AdjustmentType, &rule.Adjustment, &rule.Priority, &rule.Exclusive, &rule.CreatedAt,
+		&rule.UpdatedAt, &rule.CreatedBy, &rule.UpdatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
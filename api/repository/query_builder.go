@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+)
+
+// queryBuilder collects the WHERE conditions of a query along with their arguments.
+// Conditions use ? for their arguments, which get replaced by numbered placeholders as they are added.
+type queryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+func (qb *queryBuilder) where(condition string, args ...any) *queryBuilder {
+	for _, arg := range args {
+		qb.args = append(qb.args, arg)
+		condition = strings.Replace(condition, "?", fmt.Sprintf("$%d", len(qb.args)), 1)
+	}
+	qb.conditions = append(qb.conditions, condition)
+	return qb
+}
+
+// whereIf adds the condition only when ok is true, which keeps optional filters in one line
+func (qb *queryBuilder) whereIf(ok bool, condition string, args ...any) *queryBuilder {
+	if ok {
+		return qb.where(condition, args...)
+	}
+	return qb
+}
+
+// placeholder adds an argument which is not part of the conditions, like a limit, and returns its placeholder
+func (qb *queryBuilder) placeholder(arg any) string {
+	qb.args = append(qb.args, arg)
+	return fmt.Sprintf("$%d", len(qb.args))
+}
+
+func (qb *queryBuilder) whereClause() string {
+	if len(qb.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(qb.conditions, " AND ")
+}
+
+// clone copies the builder so the copy can get more conditions without changing the original
+func (qb *queryBuilder) clone() *queryBuilder {
+	return &queryBuilder{
+		conditions: append([]string(nil), qb.conditions...),
+		args:       append([]any(nil), qb.args...),
+	}
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type RefreshTokensRepository interface {
+	Create(token models.RefreshToken) error
+	GetByHash(tokenHash string) (*models.RefreshToken, error)
+	Rotate(usedId string, next models.RefreshToken) (bool, error)
+	RevokeFamily(familyId string, revokedAt time.Time) error
+	RevokeAllForUser(userId string, revokedAt time.Time) error
+}
+
+type refreshTokensRepository struct {
+	db *sql.DB
+}
+
+func NewRefreshTokensRepository(db *sql.DB) RefreshTokensRepository {
+	return &refreshTokensRepository{
+		db: db,
+	}
+}
+
+func (rtr *refreshTokensRepository) Create(token models.RefreshToken) error {
+	if err := insertRefreshToken(rtr.db, token); err != nil {
+		return fmt.Errorf("create refresh token: %w", err)
+	}
+	return nil
+}
+
+func (rtr *refreshTokensRepository) GetByHash(tokenHash string) (*models.RefreshToken, error) {
+	row := rtr.db.QueryRow(`SELECT id, user_id, family_id, token_hash, expires_at, used_at, revoked_at, created_at
+        FROM refresh_tokens
+        WHERE token_hash = $1;
+    `, tokenHash)
+
+	var token models.RefreshToken
+	err := row.Scan(&token.ID, &token.UserId, &token.FamilyId, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.RevokedAt, &token.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("get refresh token: %w", err)
+	}
+	return &token, nil
+}
+
+// Rotate marks the token used and stores the next token of its family. It reports false without storing
+// the next token when the token was already used or revoked, which happens when it is reused concurrently.
+func (rtr *refreshTokensRepository) Rotate(usedId string, next models.RefreshToken) (bool, error) {
+	tx, err := rtr.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("rotate refresh token: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE refresh_tokens SET used_at = $2
+        WHERE id = $1 AND used_at IS NULL AND revoked_at IS NULL;
+    `, usedId, next.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("rotate refresh token: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("rotate refresh token: %w", err)
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if err := insertRefreshToken(tx, next); err != nil {
+		return false, fmt.Errorf("rotate refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("rotate refresh token: %w", err)
+	}
+	return true, nil
+}
+
+func (rtr *refreshTokensRepository) RevokeFamily(familyId string, revokedAt time.Time) error {
+	_, err := rtr.db.Exec(`UPDATE refresh_tokens SET revoked_at = $2
+        WHERE family_id = $1 AND revoked_at IS NULL;
+    `, familyId, revokedAt)
+	if err != nil {
+		return fmt.Errorf("revoke refresh token family: %w", err)
+	}
+	return nil
+}
+
+func (rtr *refreshTokensRepository) RevokeAllForUser(userId string, revokedAt time.Time) error {
+	_, err := rtr.db.Exec(`UPDATE refresh_tokens SET revoked_at = $2
+        WHERE user_id = $1 AND revoked_at IS NULL;
+    `, userId, revokedAt)
+	if err != nil {
+		return fmt.Errorf("revoke user refresh tokens: %w", err)
+	}
+	return nil
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func insertRefreshToken(db execer, token models.RefreshToken) error {
+	_, err := db.Exec(`INSERT INTO refresh_tokens(id, user_id, family_id, token_hash, expires_at, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6);
+    `, token.ID, token.UserId, token.FamilyId, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+	return err
+}
@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type RolesRepository interface {
+	GetAll() ([]models.Role, error)
+	Save(role models.Role) error
+	GetPermissions(roles []string) ([]string, error)
+	CountExisting(roles []string) (int, error)
+}
+
+type rolesRepository struct {
+	db *sql.DB
+}
+
+func NewRolesRepository(db *sql.DB) RolesRepository {
+	return &rolesRepository{
+		db: db,
+	}
+}
+
+func (rr *rolesRepository) GetAll() ([]models.Role, error) {
+	rows, err := rr.db.Query(`SELECT roles.name, roles.description, role_permissions.permission FROM roles
+        LEFT JOIN role_permissions ON role_permissions.role = roles.name
+        ORDER BY roles.name, role_permissions.permission;
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("get roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make([]models.Role, 0, 3)
+	for rows.Next() {
+		var name, description string
+		var permission sql.NullString
+		if err := rows.Scan(&name, &description, &permission); err != nil {
+			return nil, fmt.Errorf("get roles: %w", err)
+		}
+
+		if len(roles) == 0 || roles[len(roles)-1].Name != name {
+			roles = append(roles, models.Role{Name: name, Description: description, Permissions: []string{}})
+		}
+		if permission.Valid {
+			role := &roles[len(roles)-1]
+			role.Permissions = append(role.Permissions, permission.String)
+		}
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("get roles: %w", rows.Err())
+	}
+	return roles, nil
+}
+
+// Save creates the role or replaces the description and the permissions of the existing one
+func (rr *rolesRepository) Save(role models.Role) error {
+	tx, err := rr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("save role: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`INSERT INTO roles(name, description) VALUES ($1, $2)
+        ON CONFLICT (name) DO UPDATE SET description = EXCLUDED.description;
+    `, role.Name, role.Description)
+	if err != nil {
+		return fmt.Errorf("save role: %w", err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM role_permissions WHERE role = $1;`, role.Name)
+	if err != nil {
+		return fmt.Errorf("save role: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO role_permissions(role, permission)
+        SELECT $1, permission FROM UNNEST($2::text[]) AS permission;
+    `, role.Name, role.Permissions)
+	if err != nil {
+		return fmt.Errorf("save role: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("save role: %w", err)
+	}
+	return nil
+}
+
+// GetPermissions returns the permissions granted by any of the roles
+func (rr *rolesRepository) GetPermissions(roles []string) ([]string, error) {
+	rows, err := rr.db.Query(`SELECT DISTINCT permission FROM role_permissions
+        WHERE role = ANY($1)
+        ORDER BY permission;
+    `, roles)
+	if err != nil {
+		return nil, fmt.Errorf("get role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := make([]string, 0, len(models.Permissions))
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, fmt.Errorf("get role permissions: %w", err)
+		}
+		permissions = append(permissions, permission)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("get role permissions: %w", rows.Err())
+	}
+	return permissions, nil
+}
+
+// CountExisting returns how many of the roles exist
+func (rr *rolesRepository) CountExisting(roles []string) (int, error) {
+	var count int
+	row := rr.db.QueryRow(`SELECT COUNT(*) FROM roles WHERE name = ANY($1);`, roles)
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("count roles: %w", err)
+	}
+	return count, nil
+}
@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type SchedulesRepository interface {
+	GetRules(theatreId string) ([]models.ScheduleRule, error)
+	ReplaceSlotRules(theatreId, slotId string, rules []models.ScheduleRule, updatedBy string, updatedAt time.Time) error
+	GetBlackouts(theatreId string, from time.Time, to *time.Time) ([]models.Blackout, error)
+	AddBlackout(blackout models.Blackout) error
+	DeleteBlackout(theatreId, blackoutId string) error
+}
+
+const scheduleRuleColumns = `id, theatre_id, slot_id, weekdays, TO_CHAR(valid_from, 'YYYY-MM-DD'), TO_CHAR(valid_to, 'YYYY-MM-DD'),
+	created_at, created_by`
+
+const blackoutColumns = `id, theatre_id, slot_id, TO_CHAR(from_date, 'YYYY-MM-DD'), TO_CHAR(to_date, 'YYYY-MM-DD'), reason,
+	created_at, created_by`
+
+type schedulesRepository struct {
+	db *sql.DB
+}
+
+func NewSchedulesRepository(db *sql.DB) SchedulesRepository {
+	return &schedulesRepository{
+		db: db,
+	}
+}
+
+func (sr *schedulesRepository) GetRules(theatreId string) ([]models.ScheduleRule, error) {
+	rows, err := sr.db.Query(`
+		SELECT `+scheduleRuleColumns+` FROM slot_schedule_rules
+		WHERE theatre_id = $1
+		ORDER BY slot_id, created_at;
+	`, theatreId)
+	if err != nil {
+		return nil, fmt.Errorf("get schedule rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []models.ScheduleRule{}
+	for rows.Next() {
+		rule, err := scanScheduleRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("get schedule rules: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get schedule rules: %w", err)
+	}
+	return rules, nil
+}
+
+// ReplaceSlotRules swaps the rules of the theatre slot for the given ones, failing with models.ErrUnknownSlot
+// when the slot is not assigned to the theatre and with models.ErrScheduleHasBookings when the slot would no
+// longer run on a date it has orders for from updatedAt onwards
+func (sr *schedulesRepository) ReplaceSlotRules(theatreId, slotId string, rules []models.ScheduleRule, updatedBy string, updatedAt time.Time) error {
+	tx, err := sr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("replace schedule rules: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchTheatre(tx, theatreId, updatedBy, updatedAt, "replace schedule rules"); err != nil {
+		return err
+	}
+	if err := checkTheatreSlot(tx, theatreId, slotId, "replace schedule rules"); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`
+		SELECT order_date FROM orders
+		WHERE theatre_id = $1 AND slot_id = $2 AND order_date >= $3 AND `+activeOrdersCondition+`;
+	`, theatreId, slotId, updatedAt.Format(time.DateOnly))
+	if err != nil {
+		return fmt.Errorf("replace schedule rules: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var orderDate time.Time
+		if err := rows.Scan(&orderDate); err != nil {
+			return fmt.Errorf("replace schedule rules: %w", err)
+		}
+		if !models.RulesMatch(rules, orderDate) {
+			return fmt.Errorf("%w: %s", models.ErrScheduleHasBookings, orderDate.Format(time.DateOnly))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("replace schedule rules: %w", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM slot_schedule_rules WHERE theatre_id = $1 AND slot_id = $2;`, theatreId, slotId); err != nil {
+		return fmt.Errorf("replace schedule rules: %w", err)
+	}
+
+	for _, rule := range rules {
+		_, err := tx.Exec(`
+			INSERT INTO slot_schedule_rules(id, theatre_id, slot_id, weekdays, valid_from, valid_to, created_at, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+		`, rule.ID, rule.TheatreId, rule.SlotId, rule.Weekdays, rule.ValidFrom, rule.ValidTo, rule.CreatedAt, rule.CreatedBy)
+		if err != nil {
+			return fmt.Errorf("replace schedule rules: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("replace schedule rules: %w", err)
+	}
+	return nil
+}
+
+// GetBlackouts returns the blackouts of the theatre which overlap the dates, to being nil returns every
+// blackout ending on from or later
+func (sr *schedulesRepository) GetBlackouts(theatreId string, from time.Time, to *time.Time) ([]models.Blackout, error) {
+	query := &queryBuilder{}
+	query.where("theatre_id = ?", theatreId).
+		where("to_date >= ?", from.Format(time.DateOnly))
+	if to != nil {
+		query.where("from_date <= ?", to.Format(time.DateOnly))
+	}
+
+	rows, err := sr.db.Query(`SELECT `+blackoutColumns+` FROM theatre_blackouts`+query.whereClause()+`
+		ORDER BY from_date, created_at;`, query.args...)
+	if err != nil {
+		return nil, fmt.Errorf("get blackouts: %w", err)
+	}
+	defer rows.Close()
+
+	blackouts := []models.Blackout{}
+	for rows.Next() {
+		var blackout models.Blackout
+		err := rows.Scan(&blackout.ID, &blackout.TheatreId, &blackout.SlotId, &blackout.FromDate, &blackout.ToDate, &blackout.Reason, &blackout.CreatedAt, &blackout.CreatedBy)
+		if err != nil {
+			return nil, fmt.Errorf("get blackouts: %w", err)
+		}
+		blackouts = append(blackouts, blackout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get blackouts: %w", err)
+	}
+	return blackouts, nil
+}
+
+// AddBlackout closes the theatre, or its slot, for the dates, failing with models.ErrUnknownSlot when the slot
+// is not assigned to the theatre and with models.ErrScheduleHasBookings when there are orders on the dates
+func (sr *schedulesRepository) AddBlackout(blackout models.Blackout) error {
+	tx, err := sr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("add blackout: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchTheatre(tx, blackout.TheatreId, blackout.CreatedBy, blackout.CreatedAt, "add blackout"); err != nil {
+		return err
+	}
+	if blackout.SlotId != nil {
+		if err := checkTheatreSlot(tx, blackout.TheatreId, *blackout.SlotId, "add blackout"); err != nil {
+			return err
+		}
+	}
+
+	var booked bool
+	row := tx.QueryRow(`SELECT EXISTS(
+		SELECT 1 FROM orders
+		WHERE theatre_id = $1 AND ($2::uuid IS NULL OR slot_id = $2) AND order_date BETWEEN $3 AND $4 AND `+activeOrdersCondition+`
+	);`, blackout.TheatreId, blackout.SlotId, blackout.FromDate, blackout.ToDate)
+	if err := row.Scan(&booked); err != nil {
+		return fmt.Errorf("add blackout: %w", err)
+	}
+	if booked {
+		return models.ErrScheduleHasBookings
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO theatre_blackouts(id, theatre_id, slot_id, from_date, to_date, reason, created_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+	`, blackout.ID, blackout.TheatreId, blackout.SlotId, blackout.FromDate, blackout.ToDate, blackout.Reason, blackout.CreatedAt, blackout.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("add blackout: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("add blackout: %w", err)
+	}
+	return nil
+}
+
+func (sr *schedulesRepository) DeleteBlackout(theatreId, blackoutId string) error {
+	result, err := sr.db.Exec(`DELETE FROM theatre_blackouts WHERE id = $1 AND theatre_id = $2;`, blackoutId, theatreId)
+	if err != nil {
+		return fmt.Errorf("delete blackout: %w", err)
+	}
+	return checkUpdated(result, "delete blackout")
+}
+
+// checkTheatreSlot returns models.ErrUnknownSlot when the slot is not assigned to the theatre
+func checkTheatreSlot(tx *sql.Tx, theatreId, slotId, action string) error {
+	var assigned bool
+	row := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM theatre_slots WHERE theatre_id = $1 AND slot_id = $2);`, theatreId, slotId)
+	if err := row.Scan(&assigned); err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if !assigned {
+		return fmt.Errorf("%w: %s is not a slot of the theatre", models.ErrUnknownSlot, slotId)
+	}
+	return nil
+}
+
+// checkSlotRuns makes sure the slot of the theatre runs on the date, returning models.ErrSlotClosed otherwise.
+// It locks the theatre against changes of its schedule until the transaction ends, so a booking can not slip
+// past a blackout or a rule added meanwhile, or into a theatre archived or a slot removed or deleted meanwhile.
+func checkSlotRuns(tx *sql.Tx, theatreId, slotId string, date time.Time, action string) error {
+	var archived bool
+	row := tx.QueryRow(`SELECT archived_at IS NOT NULL FROM theatres WHERE id = $1 FOR SHARE;`, theatreId)
+	if err := row.Scan(&archived); err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if archived {
+		return fmt.Errorf("%w: theatre is archived", models.ErrSlotClosed)
+	}
+
+	var assigned bool
+	row = tx.QueryRow(`SELECT EXISTS(
+		SELECT 1 FROM theatre_slots
+		JOIN slots ON slots.id = theatre_slots.slot_id
+		WHERE theatre_slots.theatre_id = $1 AND theatre_slots.slot_id = $2 AND slots.deleted_at IS NULL
+	);`, theatreId, slotId)
+	if err := row.Scan(&assigned); err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if !assigned {
+		return fmt.Errorf("%w: slot is not a slot of the theatre", models.ErrSlotClosed)
+	}
+
+	var blackedOut bool
+	row = tx.QueryRow(`SELECT EXISTS(
+		SELECT 1 FROM theatre_blackouts
+		WHERE theatre_id = $1 AND (slot_id IS NULL OR slot_id = $2) AND $3 BETWEEN from_date AND to_date
+	);`, theatreId, slotId, date.Format(time.DateOnly))
+	if err := row.Scan(&blackedOut); err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if blackedOut {
+		return fmt.Errorf("%w: %s", models.ErrSlotClosed, date.Format(time.DateOnly))
+	}
+
+	rows, err := tx.Query(`
+		SELECT `+scheduleRuleColumns+` FROM slot_schedule_rules
+		WHERE theatre_id = $1 AND slot_id = $2;
+	`, theatreId, slotId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	defer rows.Close()
+
+	var rules []models.ScheduleRule
+	for rows.Next() {
+		rule, err := scanScheduleRule(rows)
+		if err != nil {
+			return fmt.Errorf("%s: %w", action, err)
+		}
+		rules = append(rules, *rule)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+
+	if !models.RulesMatch(rules, date) {
+		return fmt.Errorf("%w: %s", models.ErrSlotClosed, date.Format(time.DateOnly))
+	}
+	return nil
+}
+
+func scanScheduleRule(row rowScanner) (*models.ScheduleRule, error) {
+	var rule models.ScheduleRule
+	err := row.Scan(&rule.ID, &rule.TheatreId, &rule.SlotId, pgtype.NewMap().SQLScanner(&rule.Weekdays), &rule.ValidFrom, &rule.ValidTo, &rule.CreatedAt, &rule.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
@@ -2,6 +2,11 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/ortin779/private_theatre_api/api/models"
 )
@@ -9,8 +14,15 @@ import (
 type SlotsRepository interface {
 	GetSlots() ([]models.Slot, error)
 	AddSlot(slot models.Slot) error
+	GetById(id string) (*models.Slot, error)
+	GetByIds(ids []string) ([]models.Slot, error)
+	Update(slot models.Slot) error
+	Delete(id, deletedBy string, deletedAt time.Time) error
 }
 
+const slotColumns = `slots.id, slots.start_minute, slots.end_minute, slots.updated_at, slots.created_at, slots.created_by,
+	slots.updated_by, slots.deleted_at`
+
 type slotsRepository struct {
 	db *sql.DB
 }
@@ -22,35 +34,217 @@ func NewSlotsRepo(db *sql.DB) SlotsRepository {
 }
 
 func (sr *slotsRepository) GetSlots() ([]models.Slot, error) {
-	var slots []models.Slot
-	slotRows, err := sr.db.Query(`SELECT * FROM slots;`)
+	slots, err := querySlots(sr.db, `SELECT `+slotColumns+` FROM slots WHERE deleted_at IS NULL;`)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("get slots: %w", err)
 	}
-	defer slotRows.Close()
+	return slots, nil
+}
 
-	for slotRows.Next() {
-		var slot models.Slot
-		err := slotRows.Scan(&slot.ID, &slot.StartTime, &slot.EndTime, &slot.UpdatedAt, &slot.CreatedAt, &slot.CreatedBy, &slot.UpdatedBy)
-		if err != nil {
-			return nil, err
+func (sr *slotsRepository) AddSlot(slot models.Slot) error {
+	_, err := sr.db.Exec(`
+		INSERT INTO slots(id, start_minute, end_minute, created_at, updated_at, created_by, updated_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`, slot.ID, int(slot.StartTime), int(slot.EndTime), slot.CreatedAt, slot.UpdatedAt, slot.CreatedBy, slot.UpdatedBy)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		return models.ErrSlotExists
+	}
+	if err != nil {
+		return fmt.Errorf("add slot: %w", err)
+	}
+	return nil
+}
+
+// GetById returns the slot, deleted slots included
+func (sr *slotsRepository) GetById(id string) (*models.Slot, error) {
+	row := sr.db.QueryRow(`SELECT `+slotColumns+` FROM slots WHERE id = $1;`, id)
+	slot, err := scanSlot(row)
+	if err != nil {
+		return nil, fmt.Errorf("get slot: %w", err)
+	}
+	return slot, nil
+}
+
+// GetByIds returns the slots with the given ids, deleted slots included, ids without a slot are left out
+func (sr *slotsRepository) GetByIds(ids []string) ([]models.Slot, error) {
+	slots, err := querySlots(sr.db, `SELECT `+slotColumns+` FROM slots WHERE id = ANY($1::uuid[]);`, ids)
+	if err != nil {
+		return nil, fmt.Errorf("get slots by ids: %w", err)
+	}
+	return slots, nil
+}
+
+// Update changes the times of the slot, failing with models.ErrSlotHasBookings while the slot has orders
+// from the day it is updated onwards, as they were booked for the old times, and with a *models.SlotConflictError
+// when the new times overlap the other slots of a theatre the slot is assigned to. Both are checked while the
+// theatres of the slot are locked, so slots added or booked at the same time can not slip past them.
+func (sr *slotsRepository) Update(slot models.Slot) error {
+	tx, err := sr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("update slot: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchSlotTheatres(tx, slot.ID, slot.UpdatedBy, slot.UpdatedAt, "update slot"); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE slots SET start_minute = $2, end_minute = $3, updated_at = $4, updated_by = $5
+		WHERE id = $1 AND deleted_at IS NULL;
+	`, slot.ID, int(slot.StartTime), int(slot.EndTime), slot.UpdatedAt, slot.UpdatedBy)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+		return models.ErrSlotExists
+	}
+	if err != nil {
+		return fmt.Errorf("update slot: %w", err)
+	}
+	if err := checkUpdated(result, "update slot"); err != nil {
+		return err
+	}
+
+	siblings, err := querySlots(tx, `
+		SELECT `+slotColumns+` FROM slots
+			WHERE slots.id <> $1 AND slots.id IN (
+				SELECT siblings.slot_id FROM theatre_slots
+				JOIN theatre_slots siblings ON siblings.theatre_id = theatre_slots.theatre_id
+				WHERE theatre_slots.slot_id = $1
+			);
+	`, slot.ID)
+	if err != nil {
+		return fmt.Errorf("update slot: %w", err)
+	}
+	if conflicts := models.FindOverlaps(siblings, []models.Slot{slot}); len(conflicts) > 0 {
+		return &models.SlotConflictError{Slots: conflicts}
+	}
+
+	if err := checkSlotBookings(tx, slot.ID, slot.UpdatedAt, "update slot"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("update slot: %w", err)
+	}
+	return nil
+}
+
+// Delete takes the slot away from every theatre and stops it from being used, failing with
+// models.ErrSlotHasBookings while it has orders from deletedAt onwards. The theatres of the slot are locked
+// meanwhile so it can not be booked in between. The slot is kept for its past orders.
+func (sr *slotsRepository) Delete(id, deletedBy string, deletedAt time.Time) error {
+	tx, err := sr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("delete slot: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchSlotTheatres(tx, id, deletedBy, deletedAt, "delete slot"); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`
+		UPDATE slots SET deleted_at = $2, updated_at = $2, updated_by = $3
+		WHERE id = $1 AND deleted_at IS NULL;
+	`, id, deletedAt, deletedBy)
+	if err != nil {
+		return fmt.Errorf("delete slot: %w", err)
+	}
+	if err := checkUpdated(result, "delete slot"); err != nil {
+		return err
+	}
+
+	if err := checkSlotBookings(tx, id, deletedAt, "delete slot"); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM theatre_slots WHERE slot_id = $1;`, id); err != nil {
+		return fmt.Errorf("delete slot: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete slot: %w", err)
+	}
+	return nil
+}
+
+// touchSlotTheatres locks the theatres the slot is assigned to the way touchTheatre does,
+// in the order of their ids so that changes of slots sharing theatres can not deadlock
+func touchSlotTheatres(tx *sql.Tx, slotId, updatedBy string, updatedAt time.Time, action string) error {
+	rows, err := tx.Query(`SELECT theatre_id FROM theatre_slots WHERE slot_id = $1 ORDER BY theatre_id;`, slotId)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	defer rows.Close()
+
+	var theatreIds []string
+	for rows.Next() {
+		var theatreId string
+		if err := rows.Scan(&theatreId); err != nil {
+			return fmt.Errorf("%s: %w", action, err)
 		}
-		slots = append(slots, slot)
+		theatreIds = append(theatreIds, theatreId)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("%s: %w", action, err)
 	}
+	rows.Close()
 
-	err = slotRows.Err()
+	for _, theatreId := range theatreIds {
+		if err := touchTheatre(tx, theatreId, updatedBy, updatedAt, action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSlotBookings returns models.ErrSlotHasBookings when the slot has active orders from the day of from onwards
+func checkSlotBookings(tx *sql.Tx, slotId string, from time.Time, action string) error {
+	var upcoming bool
+	row := tx.QueryRow(`SELECT EXISTS(
+		SELECT 1 FROM orders
+		WHERE slot_id = $1 AND order_date >= $2 AND `+activeOrdersCondition+`
+	);`, slotId, from.Format(time.DateOnly))
+	if err := row.Scan(&upcoming); err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if upcoming {
+		return models.ErrSlotHasBookings
+	}
+	return nil
+}
+
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+func querySlots(db queryer, query string, args ...any) ([]models.Slot, error) {
+	rows, err := db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
+	var slots []models.Slot
+	for rows.Next() {
+		slot, err := scanSlot(rows)
+		if err != nil {
+			return nil, err
+		}
+		slots = append(slots, *slot)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 	return slots, nil
 }
 
-func (sr *slotsRepository) AddSlot(slot models.Slot) error {
-	_, err := sr.db.Exec(`
-		INSERT INTO slots(id, start_time, end_time, created_at, updated_at, created_by, updated_by)
-			VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`, slot.ID, slot.StartTime, slot.EndTime, slot.CreatedAt, slot.UpdatedAt, slot.CreatedBy, slot.UpdatedBy)
-	// TODO: Handle custom errors
-	return err
+func scanSlot(row rowScanner) (*models.Slot, error) {
+	var slot models.Slot
+	err := row.Scan(&slot.ID, &slot.StartTime, &slot.EndTime, &slot.UpdatedAt, &slot.CreatedAt, &slot.CreatedBy, &slot.UpdatedBy, &slot.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &slot, nil
 }
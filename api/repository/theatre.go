@@ -2,7 +2,11 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/ortin779/private_theatre_api/api/models"
 )
@@ -11,8 +15,17 @@ type TheatreRepository interface {
 	GetTheatres() ([]models.Theatre, error)
 	Create(t models.Theatre, slots []string) error
 	GetTheatreDetails(id string) (*models.TheatreWithSlots, error)
+	Update(t models.Theatre) error
+	AddSlots(theatreId string, slots []string, updatedBy string, updatedAt time.Time) error
+	RemoveSlot(theatreId, slotId, updatedBy string, updatedAt time.Time) error
+	Archive(theatreId, updatedBy string, archivedAt time.Time) error
+	Restore(theatreId, updatedBy string, updatedAt time.Time) error
 }
 
+const theatreColumns = `theatres.id, theatres.name, theatres.description, theatres.price, theatres.additional_price_per_head,
+	theatres.max_capacity, theatres.min_capacity, theatres.default_capacity, theatres.updated_at, theatres.created_at,
+	theatres.created_by, theatres.updated_by, theatres.archived_at, theatres.timezone`
+
 type theatreRepository struct {
 	db *sql.DB
 }
@@ -26,7 +39,8 @@ func NewTheatreRepository(db *sql.DB) TheatreRepository {
 func (tr *theatreRepository) GetTheatres() ([]models.Theatre, error) {
 	var theatres []models.Theatre
 	rows, err := tr.db.Query(`
-		SELECT * FROM theatres;
+		SELECT ` + theatreColumns + ` FROM theatres
+			WHERE archived_at IS NULL;
 	`)
 	if err != nil {
 		return nil, fmt.Errorf("get theatres: %w", err)
@@ -34,12 +48,11 @@ func (tr *theatreRepository) GetTheatres() ([]models.Theatre, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var theatre models.Theatre
-		err := rows.Scan(&theatre.ID, &theatre.Name, &theatre.Description, &theatre.Price, &theatre.AdditionalPricePerHead, &theatre.MaxCapacity, &theatre.MinCapacity, &theatre.DefaultCapacity, &theatre.UpdatedAt, &theatre.CreatedAt, &theatre.CreatedBy, &theatre.UpdatedBy)
+		theatre, err := scanTheatre(rows)
 		if err != nil {
 			return nil, fmt.Errorf("get theatres: %w", err)
 		}
-		theatres = append(theatres, theatre)
+		theatres = append(theatres, *theatre)
 	}
 
 	if rows.Err() != nil {
@@ -57,8 +70,8 @@ func (tr *theatreRepository) Create(t models.Theatre, slots []string) error {
 	defer tx.Rollback()
 
 	_, err = tx.Exec(`
-        INSERT INTO theatres(id, name, description, price, additional_price_per_head, max_capacity, min_capacity, default_capacity, created_at, updated_at, created_by, updated_by) Values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
-    `, t.ID, t.Name, t.Description, t.Price, t.AdditionalPricePerHead, t.MaxCapacity, t.MinCapacity, t.DefaultCapacity, t.CreatedAt, t.UpdatedAt, t.CreatedBy, t.UpdatedBy)
+        INSERT INTO theatres(id, name, description, price, additional_price_per_head, max_capacity, min_capacity, default_capacity, created_at, updated_at, created_by, updated_by, timezone) Values ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13);
+    `, t.ID, t.Name, t.Description, t.Price, t.AdditionalPricePerHead, t.MaxCapacity, t.MinCapacity, t.DefaultCapacity, t.CreatedAt, t.UpdatedAt, t.CreatedBy, t.UpdatedBy, t.Timezone)
 
 	if err != nil {
 		return fmt.Errorf("create theatre: %w", err)
@@ -84,44 +97,211 @@ func (tr *theatreRepository) Create(t models.Theatre, slots []string) error {
 }
 
 func (tr *theatreRepository) GetTheatreDetails(id string) (*models.TheatreWithSlots, error) {
-	var theatreDetails models.TheatreWithSlots
 	row := tr.db.QueryRow(`
-		SELECT * FROM theatres
+		SELECT `+theatreColumns+` FROM theatres
 			WHERE id = $1;
 	`, id)
 
-	err := row.Scan(&theatreDetails.ID, &theatreDetails.Name, &theatreDetails.Description, &theatreDetails.Price, &theatreDetails.AdditionalPricePerHead, &theatreDetails.MaxCapacity, &theatreDetails.MinCapacity, &theatreDetails.DefaultCapacity, &theatreDetails.UpdatedAt, &theatreDetails.CreatedAt, &theatreDetails.CreatedBy, &theatreDetails.UpdatedBy)
+	theatre, err := scanTheatre(row)
 
 	if err != nil {
 		return nil, fmt.Errorf("get theatre details: %w", err)
 	}
+	theatreDetails := models.TheatreWithSlots{Theatre: *theatre}
 
-	var slots []models.Slot
-
-	rows, err := tr.db.Query(`
-		SELECT * FROM slots
+	slots, err := querySlots(tr.db, `
+		SELECT `+slotColumns+` FROM slots
 			WHERE id IN (
 			SELECT slot_id from theatre_slots WHERE theatre_id=$1
 			);
 	`, id)
-
 	if err != nil {
 		return nil, fmt.Errorf("get theatre details: %w", err)
 	}
+	theatreDetails.Slots = slots
 
-	for rows.Next() {
-		var slot models.Slot
-		err := rows.Scan(&slot.ID, &slot.StartTime, &slot.EndTime, &slot.UpdatedAt, &slot.CreatedAt, &slot.CreatedBy, &slot.UpdatedBy)
-		if err != nil {
-			return nil, fmt.Errorf("get theatre details: %w", err)
+	return &theatreDetails, nil
+}
+
+// Update saves the details of the theatre, the orders already made keep the price they were made with
+func (tr *theatreRepository) Update(t models.Theatre) error {
+	result, err := tr.db.Exec(`
+        UPDATE theatres SET name = $2, description = $3, price = $4, additional_price_per_head = $5, max_capacity = $6,
+            min_capacity = $7, default_capacity = $8, updated_at = $9, updated_by = $10, timezone = $11
+        WHERE id = $1;
+    `, t.ID, t.Name, t.Description, t.Price, t.AdditionalPricePerHead, t.MaxCapacity, t.MinCapacity, t.DefaultCapacity, t.UpdatedAt, t.UpdatedBy, t.Timezone)
+	if err != nil {
+		return fmt.Errorf("update theatre: %w", err)
+	}
+	return checkUpdated(result, "update theatre")
+}
+
+// AddSlots assigns the slots to the theatre, slots it already has are left as they are. It returns a
+// *models.SlotConflictError when the slots overlap the ones of the theatre, checked while the theatre is
+// locked so that slots added at the same time can not overlap either.
+func (tr *theatreRepository) AddSlots(theatreId string, slots []string, updatedBy string, updatedAt time.Time) error {
+	tx, err := tr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("add theatre slots: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchTheatre(tx, theatreId, updatedBy, updatedAt, "add theatre slots"); err != nil {
+		return err
+	}
+
+	// the slots are read for share, so times changed meanwhile are waited on and read as changed
+	existing, err := querySlots(tx, `SELECT `+slotColumns+` FROM slots
+        JOIN theatre_slots ON theatre_slots.slot_id = slots.id
+        WHERE theatre_slots.theatre_id = $1
+        FOR SHARE OF slots;
+    `, theatreId)
+	if err != nil {
+		return fmt.Errorf("add theatre slots: %w", err)
+	}
+	added, err := querySlots(tx, `SELECT `+slotColumns+` FROM slots WHERE id = ANY($1::uuid[]) FOR SHARE;`, slots)
+	if err != nil {
+		return fmt.Errorf("add theatre slots: %w", err)
+	}
+	if conflicts := models.FindOverlaps(existing, added); len(conflicts) > 0 {
+		return &models.SlotConflictError{Slots: conflicts}
+	}
+
+	_, err = tx.Exec(`
+        INSERT INTO theatre_slots(theatre_id, slot_id)
+        SELECT $1, slot_id FROM UNNEST($2::uuid[]) AS slot_id
+        ON CONFLICT DO NOTHING;
+    `, theatreId, slots)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return models.ErrUnknownSlot
 		}
-		slots = append(slots, slot)
+		return fmt.Errorf("add theatre slots: %w", err)
 	}
 
-	if rows.Err() != nil {
-		return nil, fmt.Errorf("get theatre details: %w", row.Err())
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("add theatre slots: %w", err)
 	}
-	theatreDetails.Slots = slots
+	return nil
+}
 
-	return &theatreDetails, nil
+// RemoveSlot takes the slot away from the theatre, failing with models.ErrTheatreHasBookings while the slot
+// has orders from updatedAt onwards. The past orders of the slot are kept.
+func (tr *theatreRepository) RemoveSlot(theatreId, slotId, updatedBy string, updatedAt time.Time) error {
+	tx, err := tr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("remove theatre slot: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := touchTheatre(tx, theatreId, updatedBy, updatedAt, "remove theatre slot"); err != nil {
+		return err
+	}
+
+	var upcoming bool
+	row := tx.QueryRow(`SELECT EXISTS(
+        SELECT 1 FROM orders
+        WHERE theatre_id = $1 AND slot_id = $2 AND order_date >= $3 AND `+activeOrdersCondition+`
+    );`, theatreId, slotId, updatedAt.Format(time.DateOnly))
+	if err := row.Scan(&upcoming); err != nil {
+		return fmt.Errorf("remove theatre slot: %w", err)
+	}
+	if upcoming {
+		return models.ErrTheatreHasBookings
+	}
+
+	result, err := tx.Exec(`DELETE FROM theatre_slots WHERE theatre_id = $1 AND slot_id = $2;`, theatreId, slotId)
+	if err != nil {
+		return fmt.Errorf("remove theatre slot: %w", err)
+	}
+	if err := checkUpdated(result, "remove theatre slot"); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("remove theatre slot: %w", err)
+	}
+	return nil
+}
+
+// Archive hides the theatre from the listing and stops it from being booked, failing with
+// models.ErrTheatreHasBookings while it has orders from archivedAt onwards
+func (tr *theatreRepository) Archive(theatreId, updatedBy string, archivedAt time.Time) error {
+	tx, err := tr.db.Begin()
+	if err != nil {
+		return fmt.Errorf("archive theatre: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+        UPDATE theatres SET archived_at = COALESCE(archived_at, $3), updated_at = $3, updated_by = $2
+        WHERE id = $1;
+    `, theatreId, updatedBy, archivedAt)
+	if err != nil {
+		return fmt.Errorf("archive theatre: %w", err)
+	}
+	if err := checkUpdated(result, "archive theatre"); err != nil {
+		return err
+	}
+
+	var upcoming bool
+	row := tx.QueryRow(`SELECT EXISTS(
+        SELECT 1 FROM orders
+        WHERE theatre_id = $1 AND order_date >= $2 AND `+activeOrdersCondition+`
+    );`, theatreId, archivedAt.Format(time.DateOnly))
+	if err := row.Scan(&upcoming); err != nil {
+		return fmt.Errorf("archive theatre: %w", err)
+	}
+	if upcoming {
+		return models.ErrTheatreHasBookings
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("archive theatre: %w", err)
+	}
+	return nil
+}
+
+// Restore makes an archived theatre bookable again
+func (tr *theatreRepository) Restore(theatreId, updatedBy string, updatedAt time.Time) error {
+	result, err := tr.db.Exec(`
+        UPDATE theatres SET archived_at = NULL, updated_at = $3, updated_by = $2
+        WHERE id = $1;
+    `, theatreId, updatedBy, updatedAt)
+	if err != nil {
+		return fmt.Errorf("restore theatre: %w", err)
+	}
+	return checkUpdated(result, "restore theatre")
+}
+
+// touchTheatre records who changed the theatre and locks it until the transaction ends,
+// so concurrent changes to its slots are made one after the other
+func touchTheatre(tx *sql.Tx, theatreId, updatedBy string, updatedAt time.Time, action string) error {
+	result, err := tx.Exec(`UPDATE theatres SET updated_at = $3, updated_by = $2 WHERE id = $1;`, theatreId, updatedBy, updatedAt)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	return checkUpdated(result, action)
+}
+
+// checkUpdated returns sql.ErrNoRows when the statement did not change any row
+func checkUpdated(result sql.Result, action string) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", action, sql.ErrNoRows)
+	}
+	return nil
+}
+
+func scanTheatre(row rowScanner) (*models.Theatre, error) {
+	var theatre models.Theatre
+	err := row.Scan(&theatre.ID, &theatre.Name, &theatre.Description, &theatre.Price, &theatre.AdditionalPricePerHead, &theatre.MaxCapacity, &theatre.MinCapacity, &theatre.DefaultCapacity, &theatre.UpdatedAt, &theatre.CreatedAt, &theatre.CreatedBy, &theatre.UpdatedBy, &theatre.ArchivedAt, &theatre.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	return &theatre, nil
 }
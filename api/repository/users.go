@@ -4,20 +4,28 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/ortin779/private_theatre_api/api/models"
 )
 
 var (
-	ErrNoUserWithEmail = errors.New("no user found with given email id")
-	ErrNoUserWithId    = errors.New("no user found with given user id")
+	ErrNoUserWithEmail = models.ErrNoUserWithEmail
+	ErrNoUserWithId    = models.ErrNoUserWithId
 )
 
 type UsersRepository interface {
 	Create(user models.User) error
 	GetByEmail(email string) (*models.User, error)
 	GetByUserId(id string) (*models.User, error)
+	UpdateProfile(user models.User) error
+	UpdatePassword(userId, passwordHash string) error
+	VerifyEmail(userId string, verifiedAt time.Time) (int, error)
+	List(filter models.UsersFilter) (*models.UsersPage, error)
+	Update(user models.User) error
+	Disable(userId string, disabledAt time.Time) error
 }
 
 type usersRepository struct {
@@ -30,38 +38,66 @@ func NewUsersRepository(db *sql.DB) UsersRepository {
 	}
 }
 
+const userColumns = `id, name, email, password, roles, phone_number, email_verified_at, created_at, disabled_at`
+
+// Create stores the user. An account signed up with the same email which was never verified is replaced once
+// its verification code expired, otherwise models.ErrUserAlreadyExists is returned.
 func (ur *usersRepository) Create(user models.User) error {
-	_, err := ur.db.Exec(`INSERT INTO users(id, name, email, password, roles)
-    VALUES($1,$2,$3,$4,$5);
-`, user.ID, user.Name, user.Email, user.Password, user.Roles)
+	tx, err := ur.db.Begin()
+	if err != nil {
+		return fmt.Errorf("create user: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`DELETE FROM users
+        WHERE LOWER(email) = LOWER($1) AND email_verified_at IS NULL
+            AND NOT EXISTS (SELECT 1 FROM email_verifications WHERE user_id = users.id AND expires_at >= $2);
+    `, user.Email, user.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolationCode {
+			return models.ErrUserAlreadyExists
+		}
+		return fmt.Errorf("create user: %w", err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO users(id, name, email, password, roles, phone_number, email_verified_at, created_at)
+    VALUES($1,$2,$3,$4,$5,$6,$7,$8);
+`, user.ID, user.Name, user.Email, user.Password, user.Roles, nullString(user.PhoneNumber), user.EmailVerifiedAt, user.CreatedAt)
 
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode {
+			return models.ErrUserAlreadyExists
+		}
+		return fmt.Errorf("create user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("create user: %w", err)
 	}
 	return nil
 }
 
 func (ur *usersRepository) GetByEmail(email string) (*models.User, error) {
-	row := ur.db.QueryRow(`SELECT * FROM users
-		WHERE email=$1;`, email)
+	row := ur.db.QueryRow(`SELECT `+userColumns+` FROM users
+		WHERE LOWER(email)=LOWER($1);`, email)
 
-	var user models.User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, pgtype.NewMap().SQLScanner(&user.Roles))
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoUserWithEmail
 		}
 		return nil, err
 	}
-	return &user, nil
+	return user, nil
 }
 
 func (ur *usersRepository) GetByUserId(id string) (*models.User, error) {
-	row := ur.db.QueryRow(`SELECT * FROM users
+	row := ur.db.QueryRow(`SELECT `+userColumns+` FROM users
 		WHERE id=$1;`, id)
 
-	var user models.User
-	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, &user.Roles)
+	user, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoUserWithId
@@ -69,5 +105,235 @@ func (ur *usersRepository) GetByUserId(id string) (*models.User, error) {
 		return nil, err
 	}
 
+	return user, nil
+}
+
+func (ur *usersRepository) UpdateProfile(user models.User) error {
+	result, err := ur.db.Exec(`UPDATE users SET name = $2, phone_number = $3 WHERE id = $1;`, user.ID, user.Name, nullString(user.PhoneNumber))
+	if err != nil {
+		return fmt.Errorf("update user profile: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update user profile: %w", err)
+	}
+	if affected == 0 {
+		return ErrNoUserWithId
+	}
+	return nil
+}
+
+func (ur *usersRepository) UpdatePassword(userId, passwordHash string) error {
+	result, err := ur.db.Exec(`UPDATE users SET password = $2 WHERE id = $1;`, userId, passwordHash)
+	if err != nil {
+		return fmt.Errorf("update user password: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update user password: %w", err)
+	}
+	if affected == 0 {
+		return ErrNoUserWithId
+	}
+	return nil
+}
+
+// VerifyEmail marks the email of the user verified, discarding its verification code, and attaches the guest
+// orders made with the email to the user. It returns the number of orders attached.
+func (ur *usersRepository) VerifyEmail(userId string, verifiedAt time.Time) (int, error) {
+	tx, err := ur.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("verify email: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`UPDATE users SET email_verified_at = $2 WHERE id = $1;`, userId, verifiedAt)
+	if err != nil {
+		return 0, fmt.Errorf("verify email: %w", err)
+	}
+
+	_, err = tx.Exec(`DELETE FROM email_verifications WHERE user_id = $1;`, userId)
+	if err != nil {
+		return 0, fmt.Errorf("verify email: %w", err)
+	}
+
+	result, err := tx.Exec(`UPDATE orders SET user_id = $1
+        WHERE user_id IS NULL AND LOWER(customer_email) = (SELECT LOWER(email) FROM users WHERE id = $1);
+    `, userId)
+	if err != nil {
+		return 0, fmt.Errorf("verify email: %w", err)
+	}
+
+	attached, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("verify email: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("verify email: %w", err)
+	}
+	return int(attached), nil
+}
+
+func (ur *usersRepository) List(filter models.UsersFilter) (*models.UsersPage, error) {
+	filterQuery := &queryBuilder{}
+	filterQuery.whereIf(filter.Role != "", "? = ANY(roles)", filter.Role)
+
+	page := models.UsersPage{
+		Users: make([]models.User, 0, filter.Limit),
+	}
+	row := ur.db.QueryRow(`SELECT COUNT(*) FROM users`+filterQuery.whereClause()+`;`, filterQuery.args...)
+	if err := row.Scan(&page.TotalCount); err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+
+	pageQuery := filterQuery.clone()
+	if filter.Cursor != nil {
+		pageQuery.where("(created_at, id) < (?, ?::uuid)", filter.Cursor.CreatedAt, filter.Cursor.ID)
+	}
+	// one more user than needed tells whether there is a next page
+	limit := pageQuery.placeholder(filter.Limit + 1)
+
+	rows, err := ur.db.Query(`SELECT `+userColumns+` FROM users`+pageQuery.whereClause()+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT `+limit+`;`, pageQuery.args...)
+	if err != nil {
+		return nil, fmt.Errorf("list users: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list users: %w", err)
+		}
+		page.Users = append(page.Users, *user)
+	}
+	if rows.Err() != nil {
+		return nil, fmt.Errorf("list users: %w", rows.Err())
+	}
+
+	if len(page.Users) > filter.Limit {
+		page.Users = page.Users[:filter.Limit]
+		last := page.Users[len(page.Users)-1]
+		page.NextCursor = models.UsersCursor{CreatedAt: last.CreatedAt, ID: last.ID}.Encode()
+	}
+	return &page, nil
+}
+
+// Update changes the name and roles of the user, failing with models.ErrLastAdmin when it would leave no active admin
+func (ur *usersRepository) Update(user models.User) error {
+	tx, err := ur.db.Begin()
+	if err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+	defer tx.Rollback()
+
+	if !user.IsAdmin() {
+		if err := guardLastAdmin(tx, user.ID); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.Exec(`UPDATE users SET name = $2, roles = $3 WHERE id = $1;`, user.ID, user.Name, user.Roles)
+	if err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+	if affected == 0 {
+		return ErrNoUserWithId
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("update user: %w", err)
+	}
+	return nil
+}
+
+// Disable keeps the user from logging in, failing with models.ErrLastAdmin for the last active admin.
+// Disabling a disabled user keeps the time it was first disabled at.
+func (ur *usersRepository) Disable(userId string, disabledAt time.Time) error {
+	tx, err := ur.db.Begin()
+	if err != nil {
+		return fmt.Errorf("disable user: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := guardLastAdmin(tx, userId); err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(`UPDATE users SET disabled_at = COALESCE(disabled_at, $2) WHERE id = $1;`, userId, disabledAt)
+	if err != nil {
+		return fmt.Errorf("disable user: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("disable user: %w", err)
+	}
+	if affected == 0 {
+		return ErrNoUserWithId
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("disable user: %w", err)
+	}
+	return nil
+}
+
+// guardLastAdmin returns models.ErrLastAdmin when the user is the only active admin. The admins stay locked
+// until the transaction ends, so two admins can not take the admin role away from each other at the same time.
+func guardLastAdmin(tx *sql.Tx, userId string) error {
+	rows, err := tx.Query(`SELECT id FROM users
+        WHERE $1 = ANY(roles) AND disabled_at IS NULL
+        FOR UPDATE;
+    `, models.AdminRole)
+	if err != nil {
+		return fmt.Errorf("check last admin: %w", err)
+	}
+	defer rows.Close()
+
+	var admins []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return fmt.Errorf("check last admin: %w", err)
+		}
+		admins = append(admins, id)
+	}
+	if rows.Err() != nil {
+		return fmt.Errorf("check last admin: %w", rows.Err())
+	}
+
+	if len(admins) == 1 && admins[0] == userId {
+		return models.ErrLastAdmin
+	}
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var phoneNumber sql.NullString
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Password, pgtype.NewMap().SQLScanner(&user.Roles), &phoneNumber, &user.EmailVerifiedAt, &user.CreatedAt, &user.DisabledAt)
+	if err != nil {
+		return nil, err
+	}
+	user.PhoneNumber = phoneNumber.String
 	return &user, nil
 }
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
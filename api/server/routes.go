@@ -1,12 +1,15 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ortin779/private_theatre_api/api/auth"
 	"github.com/ortin779/private_theatre_api/api/handlers"
 	"github.com/ortin779/private_theatre_api/api/middleware"
+	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/repository"
 	"github.com/ortin779/private_theatre_api/api/service"
 	"github.com/ortin779/private_theatre_api/config"
@@ -14,10 +17,12 @@ import (
 )
 
 func addRoutes(
+	ctx context.Context,
 	c *chi.Mux,
 	logger *zap.Logger,
 	db *sql.DB,
 	cfg *config.Config,
+	tokenIssuer *auth.TokenIssuer,
 ) {
 
 	// Repository initialization
@@ -27,23 +32,57 @@ func addRoutes(
 	ordersRepo := repository.NewOrderRepository(db)
 	usersRepo := repository.NewUsersRepository(db)
 	paymentsRepo := repository.NewPaymentsRepository(db)
+	holdsRepo := repository.NewHoldsRepository(db)
+	emailVerificationsRepo := repository.NewEmailVerificationsRepository(db)
+	refreshTokensRepo := repository.NewRefreshTokensRepository(db)
+	passwordResetsRepo := repository.NewPasswordResetsRepository(db)
+	loginThrottlesRepo := repository.NewLoginThrottlesRepository(db)
+	rolesRepo := repository.NewRolesRepository(db)
+	schedulesRepo := repository.NewSchedulesRepository(db)
+	pricingRulesRepo := repository.NewPricingRulesRepository(db)
+	couponsRepo := repository.NewCouponsRepository(db)
 
 	// Service Initialization
 	addonsService := service.NewAddonService(addonRepo)
 	ordersService := service.NewOrdersService(ordersRepo)
 	slotsService := service.NewSlotsService(slotsRepository)
-	theatreService := service.NewTheatreService(theatreRepository)
-	paymentService := service.NewRazorpayService(paymentsRepo, cfg.Razorpay)
-	usersService := service.NewUsersService(usersRepo)
+	theatreService := service.NewTheatreService(theatreRepository, slotsRepository)
+	paymentGateway := service.NewPaymentGateway(cfg.Payments, cfg.Razorpay)
+	paymentService := service.NewPaymentsService(paymentsRepo, paymentGateway)
+	loginService := service.NewLoginService(usersRepo, loginThrottlesRepo, cfg.LoginThrottle)
+	sessionsService := service.NewSessionsService(refreshTokensRepo, usersRepo, rolesRepo, tokenIssuer, cfg.Sessions)
+	rolesService := service.NewRolesService(rolesRepo)
+	usersService := service.NewUsersService(usersRepo, rolesService, sessionsService)
+	notifier := service.NewLogNotifier(logger)
+	accountsService := service.NewAccountsService(usersRepo, emailVerificationsRepo, passwordResetsRepo, sessionsService, notifier, cfg.Accounts)
+	availabilityService := service.NewAvailabilityService(theatreRepository, ordersRepo, holdsRepo, schedulesRepo)
+	holdsService := service.NewHoldsService(holdsRepo, ordersService, paymentService, cfg.Holds)
+	webhooksService := service.NewWebhooksService(paymentsRepo, holdsService, ordersService, cfg.Razorpay)
+	cancellationService := service.NewCancellationService(ordersService, paymentService, cfg.Cancellation)
+	pricingService := service.NewPricingService(theatreRepository, addonRepo, schedulesRepo, pricingRulesRepo, couponsRepo, cfg.Pricing)
+	pricingRulesService := service.NewPricingRulesService(pricingRulesRepo)
+	couponsService := service.NewCouponsService(couponsRepo)
+	schedulesService := service.NewSchedulesService(schedulesRepo, theatreRepository)
+	rescheduleService := service.NewRescheduleService(ordersService, pricingService, paymentService, holdsService)
 
 	// Handlers Initialization
 	addonsHandler := handlers.NewAddonsHandler(logger, addonsService)
-	authHandler := handlers.NewAuthHandler(logger, usersService)
+	authHandler := handlers.NewAuthHandler(logger, loginService, sessionsService, tokenIssuer, cfg.LoginThrottle.ClientIPHeader)
 	slotsHandler := handlers.NewSlotsHandler(logger, slotsService)
-	ordersHandler := handlers.NewOrdersHandler(logger, ordersService, paymentService)
-	paymentsHandler := handlers.NewPaymentHandler(logger, paymentService)
+	ordersHandler := handlers.NewOrdersHandler(logger, ordersService, paymentService, holdsService, pricingService, cancellationService, rescheduleService, tokenIssuer)
+	paymentsHandler := handlers.NewPaymentHandler(logger, paymentService, holdsService)
 	theatreHandler := handlers.NewTheatreHandler(logger, theatreService)
-	usersHandler := handlers.NewUsersHandler(logger, usersService)
+	schedulesHandler := handlers.NewSchedulesHandler(logger, schedulesService)
+	pricingRulesHandler := handlers.NewPricingRulesHandler(logger, pricingRulesService)
+	couponsHandler := handlers.NewCouponsHandler(logger, couponsService)
+	usersHandler := handlers.NewUsersHandler(logger, usersService, loginService)
+	rolesHandler := handlers.NewRolesHandler(logger, rolesService)
+	accountsHandler := handlers.NewAccountsHandler(logger, accountsService)
+	availabilityHandler := handlers.NewAvailabilityHandler(logger, availabilityService)
+	webhooksHandler := handlers.NewWebhooksHandler(logger, webhooksService)
+
+	// Background jobs
+	holdsService.StartSweeper(ctx, logger)
 
 	//add middlewares
 	c.Use(middleware.RequestIdMiddleware)
@@ -51,28 +90,102 @@ func addRoutes(
 	c.Use(loggerMiddleware)
 
 	c.Get("/healthz", healthHandler)
+	c.Get("/.well-known/jwks.json", authHandler.JWKS())
 
-	c.Post("/slots", middleware.AdminAuthorization(slotsHandler.HandleCreateSlot()))
+	authorizer := middleware.NewAuthorizer(tokenIssuer)
+
+	can := authorizer.RequirePermission
+	loggedIn := authorizer.RequireLogin
+	orderAccess := func(permission string) func(http.HandlerFunc) http.HandlerFunc {
+		return authorizer.OrderAccessAuthorization(ordersService.IsOwnedBy, permission)
+	}
+
+	c.Post("/slots", can(models.TheatresWritePermission)(slotsHandler.HandleCreateSlot()))
 	c.Get("/slots", slotsHandler.HandleSlotsGet())
+	c.Patch("/slots/{id}", can(models.TheatresWritePermission)(slotsHandler.HandleUpdateSlot()))
+	c.Delete("/slots/{id}", can(models.TheatresWritePermission)(slotsHandler.HandleDeleteSlot()))
 
-	c.Post("/theatres", middleware.AdminAuthorization(theatreHandler.HandleCreateTheatre()))
+	c.Post("/theatres", can(models.TheatresWritePermission)(theatreHandler.HandleCreateTheatre()))
 	c.Get("/theatres", theatreHandler.HandleGetTheatres())
+	c.Patch("/theatres/{id}", can(models.TheatresWritePermission)(theatreHandler.HandleUpdateTheatre()))
+	c.Delete("/theatres/{id}", can(models.TheatresWritePermission)(theatreHandler.HandleArchiveTheatre()))
+	c.Post("/theatres/{id}/restore", can(models.TheatresWritePermission)(theatreHandler.HandleRestoreTheatre()))
+	c.Post("/theatres/{id}/slots", can(models.TheatresWritePermission)(theatreHandler.HandleAddTheatreSlots()))
+	c.Delete("/theatres/{id}/slots/{slotId}", can(models.TheatresWritePermission)(theatreHandler.HandleRemoveTheatreSlot()))
 	c.Get("/theatres/{id}", theatreHandler.HandleGetTheatreDetails())
-
-	c.Post("/addons", middleware.AdminAuthorization(addonsHandler.HandleCreateAddon()))
+	c.Get("/theatres/{id}/availability", availabilityHandler.HandleGetTheatreAvailability())
+	c.Get("/theatres/{id}/schedule", schedulesHandler.HandleGetSchedule())
+	c.Put("/theatres/{id}/slots/{slotId}/schedule", can(models.TheatresWritePermission)(schedulesHandler.HandleSetSlotSchedule()))
+	c.Post("/theatres/{id}/blackouts", can(models.TheatresWritePermission)(schedulesHandler.HandleAddBlackout()))
+	c.Delete("/theatres/{id}/blackouts/{blackoutId}", can(models.TheatresWritePermission)(schedulesHandler.HandleDeleteBlackout()))
+
+	c.Get("/pricing-rules", can(models.TheatresWritePermission)(pricingRulesHandler.HandleGetPricingRules()))
+	c.Post("/pricing-rules", can(models.TheatresWritePermission)(pricingRulesHandler.HandleCreatePricingRule()))
+	c.Get("/pricing-rules/{id}", can(models.TheatresWritePermission)(pricingRulesHandler.HandleGetPricingRule()))
+	c.Put("/pricing-rules/{id}", can(models.TheatresWritePermission)(pricingRulesHandler.HandleUpdatePricingRule()))
+	c.Delete("/pricing-rules/{id}", can(models.TheatresWritePermission)(pricingRulesHandler.HandleDeletePricingRule()))
+
+	c.Get("/coupons", can(models.CouponsWritePermission)(couponsHandler.HandleGetCoupons()))
+	c.Post("/coupons", can(models.CouponsWritePermission)(couponsHandler.HandleCreateCoupon()))
+	c.Get("/coupons/{id}", can(models.CouponsWritePermission)(couponsHandler.HandleGetCoupon()))
+	c.Put("/coupons/{id}", can(models.CouponsWritePermission)(couponsHandler.HandleUpdateCoupon()))
+	c.Post("/coupons/{id}/archive", can(models.CouponsWritePermission)(couponsHandler.HandleArchiveCoupon()))
+	c.Get("/coupons/{id}/redemptions", can(models.CouponsWritePermission)(couponsHandler.HandleGetRedemptions()))
+
+	c.Post("/addons", can(models.AddonsWritePermission)(addonsHandler.HandleCreateAddon()))
 	c.Get("/addons", addonsHandler.HandleGetAddons())
 	c.Get("/addons/categories", addonsHandler.HandleGetAddonCategories())
-
-	c.Post("/orders", ordersHandler.HandleCreateOrder())
-	c.Get("/orders", ordersHandler.HandleGetAllOrders())
-	c.Get("/orders/{orderId}", ordersHandler.HandleGetOrderById())
-
-	c.Post("/users", middleware.AdminAuthorization(usersHandler.HandleCreateUser()))
+	c.Get("/addons/{id}", addonsHandler.HandleGetAddon())
+	c.Patch("/addons/{id}", can(models.AddonsWritePermission)(addonsHandler.HandleUpdateAddon()))
+	c.Get("/addons/{id}/prices", can(models.AddonsWritePermission)(addonsHandler.HandleGetAddonPrices()))
+	c.Post("/addons/{id}/archive", can(models.AddonsWritePermission)(addonsHandler.HandleArchiveAddon()))
+	c.Post("/addons/{id}/unarchive", can(models.AddonsWritePermission)(addonsHandler.HandleUnarchiveAddon()))
+
+	c.Post("/orders", authorizer.Authenticate(ordersHandler.HandleCreateOrder()))
+	c.Post("/orders/quote", ordersHandler.HandleQuoteOrder())
+	c.Get("/orders", loggedIn(ordersHandler.HandleGetAllOrders()))
+	c.Get("/orders/{orderId}", orderAccess(models.OrdersReadPermission)(ordersHandler.HandleGetOrderById()))
+	c.Post("/orders/{orderId}/cancel", orderAccess(models.OrdersRefundPermission)(ordersHandler.HandleCancelOrder()))
+	c.Post("/orders/{orderId}/reschedule", orderAccess(models.OrdersWritePermission)(ordersHandler.HandleRescheduleOrder()))
+	c.Post("/orders/{orderId}/reschedules/{rescheduleId}/refund", orderAccess(models.OrdersRefundPermission)(ordersHandler.HandleRetryRescheduleRefund()))
+	c.Post("/orders/{orderId}/check-in", can(models.OrdersCheckInPermission)(ordersHandler.HandleTransitionOrder(models.OrderCheckedIn)))
+	c.Post("/orders/{orderId}/complete", can(models.OrdersCheckInPermission)(ordersHandler.HandleTransitionOrder(models.OrderCompleted)))
+	c.Get("/orders/{orderId}/history", can(models.OrdersReadPermission)(ordersHandler.HandleGetOrderHistory()))
+
+	c.Post("/users", can(models.UsersWritePermission)(usersHandler.HandleCreateUser()))
+	c.Get("/users", can(models.UsersReadPermission)(usersHandler.HandleGetAllUsers()))
+	c.Get("/users/{id}", can(models.UsersReadPermission)(usersHandler.HandleGetUser()))
+	c.Patch("/users/{id}", can(models.UsersWritePermission)(usersHandler.HandleUpdateUser()))
+	c.Post("/users/{id}/disable", can(models.UsersWritePermission)(usersHandler.HandleDisableUser()))
+	c.Post("/users/{id}/unlock", can(models.UsersWritePermission)(usersHandler.HandleUnlockUser()))
+
+	c.Get("/roles", can(models.UsersReadPermission)(rolesHandler.HandleGetRoles()))
+	c.Put("/roles/{name}", can(models.RolesWritePermission)(rolesHandler.HandleSaveRole()))
+
+	c.Post("/signup", accountsHandler.HandleSignup())
+	c.Post("/signup/verify", accountsHandler.HandleVerifyEmail())
+	c.Post("/signup/resend-code", accountsHandler.HandleResendVerification())
+
+	c.Get("/me", loggedIn(accountsHandler.HandleGetProfile()))
+	c.Patch("/me", loggedIn(accountsHandler.HandleUpdateProfile()))
+	c.Get("/me/orders", loggedIn(ordersHandler.HandleGetMyOrders()))
+	c.Post("/me/password", loggedIn(accountsHandler.HandleChangePassword()))
+
+	c.Post("/forgot-password", accountsHandler.HandleForgotPassword())
+	c.Post("/reset-password", accountsHandler.HandleResetPassword())
 
 	c.Post("/login", authHandler.Login())
 	c.Post("/refresh-token", authHandler.RefreshToken())
+	c.Post("/logout", authHandler.Logout())
+	c.Post("/logout-all", loggedIn(authHandler.LogoutAll()))
 
 	c.Post("/verify-payment", paymentsHandler.VerifyPayment())
+	c.Post("/webhooks/razorpay", webhooksHandler.HandleRazorpayWebhook())
+
+	if fakeGateway, ok := paymentGateway.(*service.FakeGateway); ok {
+		logger.Warn("payments are processed by the fake gateway")
+		c.Post("/fake-gateway/orders/{orderId}/pay", paymentsHandler.HandleFakePayment(fakeGateway))
+	}
 
 }
 
@@ -1,22 +1,26 @@
 package server
 
 import (
+	"context"
 	"database/sql"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ortin779/private_theatre_api/api/auth"
 	"github.com/ortin779/private_theatre_api/config"
 	"go.uber.org/zap"
 )
 
 func NewServer(
+	ctx context.Context,
 	logger *zap.Logger,
 	db *sql.DB,
 	cfg *config.Config,
+	tokenIssuer *auth.TokenIssuer,
 ) http.Handler {
 	router := chi.NewRouter()
 
-	addRoutes(router, logger, db, cfg)
+	addRoutes(ctx, router, logger, db, cfg, tokenIssuer)
 
 	return router
 }
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/auth"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+// verificationCodeDigits is the length of the codes emailed to verify the customer accounts
+const verificationCodeDigits = 6
+
+type AccountsService struct {
+	usersRepo         repository.UsersRepository
+	verificationsRepo repository.EmailVerificationsRepository
+	resetsRepo        repository.PasswordResetsRepository
+	sessionsService   SessionsService
+	notifier          Notifier
+	config            models.AccountsConfig
+}
+
+func NewAccountsService(
+	usersRepo repository.UsersRepository,
+	verificationsRepo repository.EmailVerificationsRepository,
+	resetsRepo repository.PasswordResetsRepository,
+	sessionsService SessionsService,
+	notifier Notifier,
+	accountsConfig models.AccountsConfig,
+) AccountsService {
+	return AccountsService{
+		usersRepo:         usersRepo,
+		verificationsRepo: verificationsRepo,
+		resetsRepo:        resetsRepo,
+		sessionsService:   sessionsService,
+		notifier:          notifier,
+		config:            accountsConfig,
+	}
+}
+
+// SignUp creates a customer account and emails it a code to verify the email with
+func (as *AccountsService) SignUp(params models.SignupParams) (*models.User, error) {
+	hashedPassword, err := auth.HashPassword(params.Password)
+	if err != nil {
+		return nil, fmt.Errorf("sign up: %w", err)
+	}
+
+	user := models.User{
+		ID:          uuid.NewString(),
+		Name:        params.Name,
+		Email:       strings.ToLower(params.Email),
+		Password:    hashedPassword,
+		Roles:       []string{models.CustomerRole},
+		PhoneNumber: params.PhoneNumber,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := as.usersRepo.Create(user); err != nil {
+		return nil, err
+	}
+
+	if err := as.sendVerificationCode(user); err != nil {
+		return nil, fmt.Errorf("sign up: %w", err)
+	}
+	return &user, nil
+}
+
+// ResendVerification sends a new verification code to the user, replacing the earlier one. It returns
+// models.ErrVerificationResendTooSoon when the earlier code was sent less than the resend interval ago.
+func (as *AccountsService) ResendVerification(email string) error {
+	user, err := as.usersRepo.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return models.ErrEmailAlreadyVerified
+	}
+	return as.sendVerificationCode(*user)
+}
+
+// VerifyEmail checks the verification code of the user and attaches the guest orders made with the email to the account
+func (as *AccountsService) VerifyEmail(params models.VerifyEmailParams) (*models.EmailVerificationResult, error) {
+	user, err := as.usersRepo.GetByEmail(params.Email)
+	if err != nil {
+		return nil, err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil, models.ErrEmailAlreadyVerified
+	}
+
+	// the attempt is counted before the code is checked, so that parallel guesses can not exceed the limit
+	verification, err := as.verificationsRepo.UseAttempt(user.ID, as.config.MaxVerificationAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrInvalidVerificationCode
+	}
+	if errors.Is(err, models.ErrTooManyVerificationAttempts) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("verify email: %w", err)
+	}
+
+	now := time.Now()
+	if now.After(verification.ExpiresAt) {
+		return nil, models.ErrVerificationCodeExpired
+	}
+	if subtle.ConstantTimeCompare([]byte(hashToken(params.Code)), []byte(verification.CodeHash)) != 1 {
+		return nil, models.ErrInvalidVerificationCode
+	}
+
+	attached, err := as.usersRepo.VerifyEmail(user.ID, now)
+	if err != nil {
+		return nil, err
+	}
+	user.EmailVerifiedAt = &now
+
+	return &models.EmailVerificationResult{
+		User:           user,
+		AttachedOrders: attached,
+	}, nil
+}
+
+func (as *AccountsService) GetProfile(userId string) (*models.User, error) {
+	return as.usersRepo.GetByUserId(userId)
+}
+
+func (as *AccountsService) UpdateProfile(userId string, params models.ProfileParams) (*models.User, error) {
+	user, err := as.usersRepo.GetByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Name != nil {
+		user.Name = *params.Name
+	}
+	if params.PhoneNumber != nil {
+		user.PhoneNumber = *params.PhoneNumber
+	}
+
+	if err := as.usersRepo.UpdateProfile(*user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// ChangePassword sets a new password for the user after checking the current one
+func (as *AccountsService) ChangePassword(userId string, params models.ChangePasswordParams) error {
+	user, err := as.usersRepo.GetByUserId(userId)
+	if err != nil {
+		return err
+	}
+
+	if !auth.ComparePasswordToHash(user.Password, params.CurrentPassword) {
+		return models.ErrWrongPassword
+	}
+	if params.NewPassword == params.CurrentPassword {
+		return models.ErrPasswordNotChanged
+	}
+
+	hashedPassword, err := auth.HashPassword(params.NewPassword)
+	if err != nil {
+		return fmt.Errorf("change password: %w", err)
+	}
+	return as.usersRepo.UpdatePassword(user.ID, hashedPassword)
+}
+
+// RequestPasswordReset sends a single use reset token to the user with the email, unknown emails are ignored
+func (as *AccountsService) RequestPasswordReset(email string) error {
+	user, err := as.usersRepo.GetByEmail(email)
+	if errors.Is(err, models.ErrNoUserWithEmail) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("request password reset: %w", err)
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("request password reset: %w", err)
+	}
+
+	now := time.Now()
+	err = as.resetsRepo.Create(models.PasswordReset{
+		ID:        uuid.NewString(),
+		UserId:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(as.config.PasswordResetTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return fmt.Errorf("request password reset: %w", err)
+	}
+
+	return as.notifier.Send(models.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body:    fmt.Sprintf("Use the token %s to set a new password, it is valid for %d minutes.", token, int(as.config.PasswordResetTTL.Minutes())),
+	})
+}
+
+// ResetPassword sets the new password with the reset token and logs out every session of the user
+func (as *AccountsService) ResetPassword(params models.ResetPasswordParams) error {
+	reset, err := as.resetsRepo.GetByHash(hashToken(params.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ErrInvalidResetToken
+	}
+	if err != nil {
+		return fmt.Errorf("reset password: %w", err)
+	}
+
+	now := time.Now()
+	if reset.UsedAt != nil || now.After(reset.ExpiresAt) {
+		return models.ErrInvalidResetToken
+	}
+
+	hashedPassword, err := auth.HashPassword(params.NewPassword)
+	if err != nil {
+		return fmt.Errorf("reset password: %w", err)
+	}
+
+	redeemed, err := as.resetsRepo.Redeem(*reset, hashedPassword, now)
+	if err != nil {
+		return fmt.Errorf("reset password: %w", err)
+	}
+	if !redeemed {
+		return models.ErrInvalidResetToken
+	}
+
+	if err := as.sessionsService.EndAll(reset.UserId); err != nil {
+		return fmt.Errorf("reset password: %w", err)
+	}
+	return nil
+}
+
+func (as *AccountsService) sendVerificationCode(user models.User) error {
+	code, err := generateVerificationCode()
+	if err != nil {
+		return fmt.Errorf("send verification code: %w", err)
+	}
+
+	now := time.Now()
+	saved, err := as.verificationsRepo.Save(models.EmailVerification{
+		UserId:    user.ID,
+		CodeHash:  hashToken(code),
+		ExpiresAt: now.Add(as.config.VerificationCodeTTL),
+		CreatedAt: now,
+	}, now.Add(-as.config.VerificationResendInterval))
+	if err != nil {
+		return fmt.Errorf("send verification code: %w", err)
+	}
+	if !saved {
+		return models.ErrVerificationResendTooSoon
+	}
+
+	return as.notifier.Send(models.Message{
+		To:      user.Email,
+		Subject: "Verify your email",
+		Body:    fmt.Sprintf("Your verification code is %s, it is valid for %d minutes.", code, int(as.config.VerificationCodeTTL.Minutes())),
+	})
+}
+
+func generateVerificationCode() (string, error) {
+	max := big.NewInt(1)
+	for range verificationCodeDigits {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", verificationCodeDigits, n), nil
+}
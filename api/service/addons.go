@@ -1,6 +1,8 @@
 package service
 
 import (
+	"time"
+
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/repository"
 )
@@ -26,3 +28,44 @@ func (as *AddonsService) GetCategories() []string {
 func (as *AddonsService) GetAllAddons() ([]models.Addon, error) {
 	return as.addonsRepo.GetAllAddons()
 }
+
+func (as *AddonsService) GetById(id string) (*models.Addon, error) {
+	return as.addonsRepo.GetById(id)
+}
+
+func (as *AddonsService) Update(id string, params models.UpdateAddonParams, userId string) (*models.Addon, error) {
+	addon, err := as.addonsRepo.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := params.Apply(*addon)
+	updated.UpdatedBy = userId
+	updated.UpdatedAt = time.Now()
+
+	if err := as.addonsRepo.Update(updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (as *AddonsService) GetPrices(id string) ([]models.AddonPrice, error) {
+	if _, err := as.addonsRepo.GetById(id); err != nil {
+		return nil, err
+	}
+	return as.addonsRepo.GetPrices(id)
+}
+
+func (as *AddonsService) Archive(id string, userId string) (*models.Addon, error) {
+	if err := as.addonsRepo.Archive(id, userId, time.Now()); err != nil {
+		return nil, err
+	}
+	return as.addonsRepo.GetById(id)
+}
+
+func (as *AddonsService) Unarchive(id string, userId string) (*models.Addon, error) {
+	if err := as.addonsRepo.Unarchive(id, userId, time.Now()); err != nil {
+		return nil, err
+	}
+	return as.addonsRepo.GetById(id)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+type AvailabilityService struct {
+	theatresRepo  repository.TheatreRepository
+	ordersRepo    repository.OrdersRepository
+	holdsRepo     repository.HoldsRepository
+	schedulesRepo repository.SchedulesRepository
+}
+
+func NewAvailabilityService(theatresRepo repository.TheatreRepository, ordersRepo repository.OrdersRepository, holdsRepo repository.HoldsRepository, schedulesRepo repository.SchedulesRepository) AvailabilityService {
+	return AvailabilityService{
+		theatresRepo:  theatresRepo,
+		ordersRepo:    ordersRepo,
+		holdsRepo:     holdsRepo,
+		schedulesRepo: schedulesRepo,
+	}
+}
+
+// GetTheatreAvailability returns the slots of the theatre running on each day in the given range,
+// marking the slots which already have an order on that day as booked and the ones
+// which are being paid for as held. Slots closed by the schedule are left out of the day.
+func (as *AvailabilityService) GetTheatreAvailability(theatreId string, params models.AvailabilityParams) (*models.TheatreAvailability, error) {
+	theatre, err := as.theatresRepo.GetTheatreDetails(theatreId)
+	if err != nil {
+		return nil, fmt.Errorf("get theatre availability: %w", err)
+	}
+
+	bookedSlots, err := as.ordersRepo.GetBookedSlots(theatreId, params.From, params.To)
+	if err != nil {
+		return nil, fmt.Errorf("get theatre availability: %w", err)
+	}
+
+	booked := make(map[string]bool, len(bookedSlots))
+	for _, bookedSlot := range bookedSlots {
+		booked[bookedSlotKey(bookedSlot.SlotId, bookedSlot.OrderDate)] = true
+	}
+
+	activeHolds, err := as.holdsRepo.GetActiveHolds(theatreId, params.From, params.To, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("get theatre availability: %w", err)
+	}
+
+	held := make(map[string]bool, len(activeHolds))
+	for _, hold := range activeHolds {
+		held[bookedSlotKey(hold.SlotId, hold.OrderDate)] = true
+	}
+
+	schedule, err := getSchedule(as.schedulesRepo, theatreId, params.From, &params.To)
+	if err != nil {
+		return nil, fmt.Errorf("get theatre availability: %w", err)
+	}
+
+	slots := slices.Clone(theatre.Slots)
+	slices.SortFunc(slots, func(a, b models.Slot) int {
+		return int(a.StartTime - b.StartTime)
+	})
+
+	availability := models.TheatreAvailability{
+		TheatreId: theatre.ID,
+		From:      params.From.Format(time.DateOnly),
+		To:        params.To.Format(time.DateOnly),
+		Days:      make([]models.DayAvailability, 0),
+	}
+
+	for day := params.From; !day.After(params.To); day = day.AddDate(0, 0, 1) {
+		dayAvailability := models.DayAvailability{
+			Date:  day.Format(time.DateOnly),
+			Slots: make([]models.SlotAvailability, 0, len(slots)),
+		}
+		for _, slot := range slots {
+			if !schedule.Runs(slot.ID, day) {
+				continue
+			}
+			// a held slot already has its unpaid order, it is only booked once the hold is confirmed
+			key := bookedSlotKey(slot.ID, day)
+			dayAvailability.Slots = append(dayAvailability.Slots, models.SlotAvailability{
+				Slot:   slot,
+				Booked: booked[key] && !held[key],
+				Held:   held[key],
+			})
+		}
+		availability.Days = append(availability.Days, dayAvailability)
+	}
+
+	return &availability, nil
+}
+
+func bookedSlotKey(slotId string, date time.Time) string {
+	return slotId + "|" + date.Format(time.DateOnly)
+}
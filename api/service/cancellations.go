@@ -0,0 +1,147 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type CancellationService struct {
+	ordersService   OrdersService
+	paymentsService PaymentsService
+	policy          models.CancellationPolicy
+}
+
+func NewCancellationService(ordersService OrdersService, paymentsService PaymentsService, policy models.CancellationPolicy) CancellationService {
+	return CancellationService{
+		ordersService:   ordersService,
+		paymentsService: paymentsService,
+		policy:          policy,
+	}
+}
+
+// Cancel cancels the order and refunds its payment as per the cancellation policy,
+// the theatre slot becomes bookable again once the order is cancelled.
+// Cancelling an order whose refund failed retries what is left of the refund.
+func (cs *CancellationService) Cancel(orderId, actor string) (*models.Cancellation, error) {
+	order, err := cs.ordersService.GetById(orderId)
+	if err != nil {
+		return nil, fmt.Errorf("cancel order: %w", err)
+	}
+	if order.Status == models.OrderCancelled || order.Status == models.OrderRefunded {
+		return cs.retryRefund(order)
+	}
+	if !order.Status.CanTransitionTo(models.OrderCancelled) {
+		return nil, fmt.Errorf("%w: %s to %s", models.ErrInvalidOrderTransition, order.Status, models.OrderCancelled)
+	}
+
+	now := time.Now()
+	beforeSlot := order.SlotStartsAt().Sub(now)
+	if beforeSlot <= 0 {
+		return nil, models.ErrOrderAlreadyStarted
+	}
+
+	cancellation := models.Cancellation{
+		OrderId:     order.ID,
+		CancelledAt: now,
+	}
+
+	// nothing was paid for orders still waiting on their payment
+	if order.PaymentDetails.Status == models.Success {
+		cancellation.RefundPercent = cs.policy.RefundPercent(beforeSlot)
+	}
+
+	var refund *models.RefundRequest
+	if amount := order.AmountPaid() * cancellation.RefundPercent / 100; amount > 0 {
+		refund = &models.RefundRequest{
+			ID:        cancellationRefundId(order.ID),
+			OrderId:   order.ID,
+			Amount:    amount,
+			CreatedAt: now,
+		}
+	}
+
+	// the order is cancelled along with the refund it owes before refunding it, only the request which gets
+	// to cancel it from the status it was priced in issues the refund
+	details := models.MetaData{"refund_percent": cancellation.RefundPercent}
+	err = cs.ordersService.Cancel(order.ID, order.Status, actor, fmt.Sprintf("%d%% refunded", cancellation.RefundPercent), details, refund)
+	if err != nil {
+		return nil, fmt.Errorf("cancel order: %w", err)
+	}
+
+	if refund == nil {
+		return &cancellation, nil
+	}
+
+	cancellation.Refunds, err = cs.refund(*order, *refund)
+	if err != nil {
+		return nil, err
+	}
+	return &cancellation, nil
+}
+
+// retryRefund refunds what is left of the refund the cancelled order owes,
+// models.ErrOrderAlreadyCancelled is returned when nothing is left
+func (cs *CancellationService) retryRefund(order *models.OrderDetails) (*models.Cancellation, error) {
+	refund, err := cs.paymentsService.GetRefundRequest(cancellationRefundId(order.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrOrderAlreadyCancelled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cancel order: %w", err)
+	}
+	if refund.Outstanding() == 0 {
+		return nil, models.ErrOrderAlreadyCancelled
+	}
+
+	cancellation := models.Cancellation{
+		OrderId: order.ID,
+	}
+	if order.CancelledAt != nil {
+		cancellation.CancelledAt = *order.CancelledAt
+	}
+
+	history, err := cs.ordersService.GetHistory(order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("cancel order: %w", err)
+	}
+	for _, transition := range history {
+		if percent, ok := transition.Details["refund_percent"].(float64); ok && transition.ToStatus == models.OrderCancelled {
+			cancellation.RefundPercent = int(percent)
+		}
+	}
+
+	cancellation.Refunds, err = cs.refund(*order, *refund)
+	if err != nil {
+		return nil, err
+	}
+	return &cancellation, nil
+}
+
+// refund refunds the request of the cancelled order, moving the order to refunded once nothing of it is pending
+func (cs *CancellationService) refund(order models.OrderDetails, refund models.RefundRequest) ([]models.Refund, error) {
+	refunds, err := cs.paymentsService.RefundPayments(order, refund, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("cancelled order %s refund: %w", order.ID, err)
+	}
+
+	pending, err := cs.paymentsService.HasPendingRefunds(order.ID)
+	if err != nil {
+		return nil, fmt.Errorf("cancel order: %w", err)
+	}
+	if !pending {
+		err = cs.ordersService.Transition(order.ID, models.OrderRefunded, models.SystemActor, "refund processed")
+		if err != nil {
+			return nil, fmt.Errorf("cancel order: %w", err)
+		}
+	}
+	return refunds, nil
+}
+
+// cancellationRefundId is the id of the refund request a cancelled order owes
+func cancellationRefundId(orderId string) string {
+	return orderId + "-cancel"
+}
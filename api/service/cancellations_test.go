@@ -0,0 +1,168 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+const (
+	testTopUpRazorpayOrderId = "order_DESmTopUpQ2bYJ1"
+	testTopUpPaymentId       = "pay_DESmTopUpR5cXk8"
+	testTopUpAmount          = 50000
+)
+
+func (fr *fakeOrdersRepository) GetById(id string) (*models.OrderDetails, error) {
+	order, ok := fr.orders[id]
+	if !ok {
+		return nil, fmt.Errorf("get orders: %w", sql.ErrNoRows)
+	}
+	order.Status = fr.statuses[id]
+	order.PaymentDetails.Status = fr.payments.statuses[order.PaymentDetails.RazorpayOrderId]
+
+	// the captured payments of the order, the first one and its top up
+	order.Payments = nil
+	for _, razorpayOrderId := range []string{order.PaymentDetails.RazorpayOrderId, testTopUpRazorpayOrderId} {
+		payment, err := fr.payments.GetPayment(razorpayOrderId)
+		if err != nil {
+			continue
+		}
+		if status := fr.payments.statuses[razorpayOrderId]; status == models.Success || status == models.Refunded {
+			order.Payments = append(order.Payments, *payment)
+		}
+	}
+	return &order, nil
+}
+
+func (fr *fakeOrdersRepository) GetHistory(orderId string) ([]models.OrderTransition, error) {
+	return fr.history[orderId], nil
+}
+
+func (fr *fakeOrdersRepository) Cancel(transition models.OrderTransition, refund *models.RefundRequest) error {
+	if err := fr.Transition(transition); err != nil {
+		return err
+	}
+
+	// the details come back from the database as json does
+	value, err := transition.Details.Value()
+	if err != nil {
+		return err
+	}
+	transition.Details = nil
+	if err := transition.Details.Scan(value); err != nil {
+		return err
+	}
+	fr.history[transition.OrderId] = append(fr.history[transition.OrderId], transition)
+
+	if refund != nil {
+		fr.payments.requests[refund.ID] = *refund
+	}
+	return nil
+}
+
+// flakyGateway fails the refund made in the given call, the way a gateway outage would
+type flakyGateway struct {
+	*FakeGateway
+	calls  int
+	failOn int
+}
+
+func (fg *flakyGateway) Refund(paymentId string, amount int, idempotencyKey string) (*models.GatewayRefund, error) {
+	fg.calls++
+	if fg.calls == fg.failOn {
+		return nil, errors.New("gateway unavailable")
+	}
+	return fg.FakeGateway.Refund(paymentId, amount, idempotencyKey)
+}
+
+// newCancellationTest sets up a confirmed order paid with the razorpay order of the recorded payloads
+// and topped up for a reschedule, a week ahead of its slot
+func newCancellationTest(t *testing.T, gateway PaymentGateway) (CancellationService, *fakeOrdersRepository, *fakePaymentsRepository) {
+	t.Helper()
+
+	paymentsRepo := newFakePaymentsRepository()
+	paymentsRepo.Create(testRazorpayOrderId, string(models.Pending), testOrderAmount)
+	paymentsRepo.MarkCaptured(testRazorpayOrderId, testPaymentId)
+	paymentsRepo.Create(testTopUpRazorpayOrderId, string(models.Pending), testTopUpAmount)
+	paymentsRepo.MarkCaptured(testTopUpRazorpayOrderId, testTopUpPaymentId)
+
+	ordersRepo := &fakeOrdersRepository{
+		statuses: map[string]models.OrderStatus{testOrderId: models.OrderConfirmed},
+		orders: map[string]models.OrderDetails{
+			testOrderId: {
+				ID:        testOrderId,
+				OrderDate: time.Now().AddDate(0, 0, 7),
+				Slot:      models.Slot{StartTime: 18 * 60, EndTime: 21 * 60},
+				PaymentDetails: models.OrderPayment{
+					PaymentVerificationBody: models.PaymentVerificationBody{
+						RazorpayOrderId:   testRazorpayOrderId,
+						RazorpayPaymentId: testPaymentId,
+					},
+				},
+			},
+		},
+		history:  make(map[string][]models.OrderTransition),
+		payments: paymentsRepo,
+	}
+
+	policy := models.CancellationPolicy{FullRefundHours: 48, NoRefundHours: 4, PartialRefundPercent: 50}
+	service := NewCancellationService(NewOrdersService(ordersRepo), NewPaymentsService(paymentsRepo, gateway), policy)
+	return service, ordersRepo, paymentsRepo
+}
+
+func addFakePayments(gateway *FakeGateway) {
+	for paymentId, razorpayOrderId := range map[string]string{testPaymentId: testRazorpayOrderId, testTopUpPaymentId: testTopUpRazorpayOrderId} {
+		gateway.payments[paymentId] = &models.GatewayPayment{ID: paymentId, OrderId: razorpayOrderId, Status: "captured"}
+	}
+}
+
+func TestCancelRetriesFailedRefund(t *testing.T) {
+	fakeGateway := NewFakeGateway("secret")
+	addFakePayments(fakeGateway)
+	// the top up is refunded first, the gateway fails on the refund of the first payment
+	gateway := &flakyGateway{FakeGateway: fakeGateway, failOn: 2}
+	cancellations, orders, payments := newCancellationTest(t, gateway)
+
+	if _, err := cancellations.Cancel(testOrderId, "admin"); err == nil {
+		t.Fatal("expected the refund to fail")
+	}
+	if status := orders.statuses[testOrderId]; status != models.OrderCancelled {
+		t.Fatalf("expected the order to be cancelled, got %s", status)
+	}
+	if len(payments.refunds) != 1 {
+		t.Fatalf("expected the top up alone to be refunded, got %d refunds", len(payments.refunds))
+	}
+
+	cancellation, err := cancellations.Cancel(testOrderId, "admin")
+	if err != nil {
+		t.Fatalf("expected the retry to refund the order, got %v", err)
+	}
+	if cancellation.RefundPercent != 100 {
+		t.Errorf("expected a full refund, got %d%%", cancellation.RefundPercent)
+	}
+
+	var refunded int
+	for _, refund := range cancellation.Refunds {
+		refunded += refund.Amount
+	}
+	if refunded != testOrderAmount+testTopUpAmount {
+		t.Errorf("expected %d to be refunded, got %d", testOrderAmount+testTopUpAmount, refunded)
+	}
+	if len(payments.refunds) != 2 {
+		t.Errorf("expected a refund for each payment, got %d", len(payments.refunds))
+	}
+	if status := orders.statuses[testOrderId]; status != models.OrderRefunded {
+		t.Errorf("expected the order to be refunded, got %s", status)
+	}
+
+	if _, err := cancellations.Cancel(testOrderId, "admin"); !errors.Is(err, models.ErrOrderAlreadyCancelled) {
+		t.Errorf("expected the refunded order to be already cancelled, got %v", err)
+	}
+	if len(payments.refunds) != 2 {
+		t.Errorf("expected nothing more to be refunded, got %d refunds", len(payments.refunds))
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+type CouponsService struct {
+	couponsRepo repository.CouponsRepository
+}
+
+func NewCouponsService(couponsRepo repository.CouponsRepository) CouponsService {
+	return CouponsService{
+		couponsRepo: couponsRepo,
+	}
+}
+
+func (cs *CouponsService) GetAll() ([]models.Coupon, error) {
+	return cs.couponsRepo.GetAll()
+}
+
+func (cs *CouponsService) Get(id string) (*models.Coupon, error) {
+	return cs.couponsRepo.GetById(id)
+}
+
+func (cs *CouponsService) Create(params models.CouponParams, userId string) (*models.Coupon, error) {
+	now := time.Now()
+	coupon := params.Coupon(models.Coupon{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		CreatedBy: userId,
+		UpdatedBy: userId,
+	})
+
+	if err := cs.couponsRepo.Create(coupon); err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// Update replaces the discount and the limits of the coupon, orders already made keep their discount
+func (cs *CouponsService) Update(id string, params models.CouponParams, userId string) (*models.Coupon, error) {
+	coupon, err := cs.couponsRepo.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := params.Coupon(*coupon)
+	updated.UpdatedAt = time.Now()
+	updated.UpdatedBy = userId
+
+	if err := cs.couponsRepo.Update(updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (cs *CouponsService) Archive(id string, userId string) (*models.Coupon, error) {
+	if err := cs.couponsRepo.Archive(id, userId, time.Now()); err != nil {
+		return nil, err
+	}
+	return cs.couponsRepo.GetById(id)
+}
+
+// GetRedemptions returns the orders which used the coupon, the latest first
+func (cs *CouponsService) GetRedemptions(id string) ([]models.CouponRedemption, error) {
+	if _, err := cs.couponsRepo.GetById(id); err != nil {
+		return nil, err
+	}
+	return cs.couponsRepo.GetRedemptions(id)
+}
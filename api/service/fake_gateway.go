@@ -0,0 +1,105 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+// FakeGateway is an in-process payment gateway for local development and integration tests.
+// It keeps everything in memory and signs payments the same way razorpay does, so the
+// verification flow works unchanged.
+type FakeGateway struct {
+	secret   string
+	mu       sync.Mutex
+	orders   map[string]int
+	payments map[string]*models.GatewayPayment
+	refunds  map[string]*models.GatewayRefund
+}
+
+func NewFakeGateway(secret string) *FakeGateway {
+	return &FakeGateway{
+		secret:   secret,
+		orders:   make(map[string]int),
+		payments: make(map[string]*models.GatewayPayment),
+		refunds:  make(map[string]*models.GatewayRefund),
+	}
+}
+
+func (fg *FakeGateway) CreateOrder(amount int) (string, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+
+	orderId := "order_fake_" + uuid.NewString()
+	fg.orders[orderId] = amount
+	return orderId, nil
+}
+
+func (fg *FakeGateway) VerifySignature(orderId, paymentId, signature string) bool {
+	return verifySignature(orderId, paymentId, signature, fg.secret)
+}
+
+func (fg *FakeGateway) FetchPayment(paymentId string) (*models.GatewayPayment, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+
+	payment, ok := fg.payments[paymentId]
+	if !ok {
+		return nil, ErrGatewayPaymentNotFound
+	}
+	fetched := *payment
+	return &fetched, nil
+}
+
+func (fg *FakeGateway) Refund(paymentId string, amount int, idempotencyKey string) (*models.GatewayRefund, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+
+	if refund, ok := fg.refunds[idempotencyKey]; ok {
+		refunded := *refund
+		return &refunded, nil
+	}
+
+	payment, ok := fg.payments[paymentId]
+	if !ok {
+		return nil, ErrGatewayPaymentNotFound
+	}
+	payment.Status = "refunded"
+
+	refund := &models.GatewayRefund{
+		ID:        "rfnd_fake_" + uuid.NewString(),
+		PaymentId: paymentId,
+		Amount:    amount,
+		Status:    "processed",
+	}
+	fg.refunds[idempotencyKey] = refund
+
+	refunded := *refund
+	return &refunded, nil
+}
+
+// Pay captures the full amount of the order, returning what the checkout would post to verify the payment
+func (fg *FakeGateway) Pay(orderId string) (*models.PaymentVerificationBody, error) {
+	fg.mu.Lock()
+	defer fg.mu.Unlock()
+
+	amount, ok := fg.orders[orderId]
+	if !ok {
+		return nil, ErrGatewayOrderNotFound
+	}
+
+	paymentId := "pay_fake_" + uuid.NewString()
+	fg.payments[paymentId] = &models.GatewayPayment{
+		ID:      paymentId,
+		OrderId: orderId,
+		Amount:  amount,
+		Status:  "captured",
+	}
+
+	return &models.PaymentVerificationBody{
+		RazorpayOrderId:   orderId,
+		RazorpayPaymentId: paymentId,
+		RazorpaySignature: hmacSignature([]byte(orderId+"|"+paymentId), fg.secret),
+	}, nil
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+// PaymentGateway is the payment provider orders are paid through, all the amounts are in paise.
+// Refunds made again with the same idempotency key return the first refund instead of refunding twice.
+type PaymentGateway interface {
+	CreateOrder(amount int) (string, error)
+	VerifySignature(orderId, paymentId, signature string) bool
+	FetchPayment(paymentId string) (*models.GatewayPayment, error)
+	Refund(paymentId string, amount int, idempotencyKey string) (*models.GatewayRefund, error)
+}
+
+var (
+	ErrGatewayOrderNotFound   = errors.New("no payment gateway order found with given id")
+	ErrGatewayPaymentNotFound = errors.New("no payment gateway payment found with given id")
+)
+
+// NewPaymentGateway builds the gateway selected in the config, razorpay unless the fake one is asked for
+func NewPaymentGateway(paymentsConfig models.PaymentsConfig, razorpayConfig models.RazorpayConfig) PaymentGateway {
+	if paymentsConfig.Gateway == models.FakeGateway {
+		return NewFakeGateway(razorpayConfig.Secret)
+	}
+	return NewRazorpayGateway(razorpayConfig)
+}
@@ -0,0 +1,207 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+	"go.uber.org/zap"
+)
+
+type HoldsService struct {
+	holdsRepo       repository.HoldsRepository
+	ordersService   OrdersService
+	paymentsService PaymentsService
+	config          models.HoldConfig
+}
+
+func NewHoldsService(holdsRepo repository.HoldsRepository, ordersService OrdersService, paymentsService PaymentsService, holdConfig models.HoldConfig) HoldsService {
+	return HoldsService{
+		holdsRepo:       holdsRepo,
+		ordersService:   ordersService,
+		paymentsService: paymentsService,
+		config:          holdConfig,
+	}
+}
+
+// Acquire locks the theatre slot of the order on its date for the configured TTL
+func (hs *HoldsService) Acquire(order models.Order) (*models.Hold, error) {
+	now := time.Now()
+
+	// an expired hold on the slot still keeps its unpaid order around, which would block the slot until the next sweep
+	expiredHolds, err := hs.holdsRepo.GetExpiredOnSlot(order.TheatreId, order.SlotId, order.OrderDate, now)
+	if err != nil {
+		return nil, fmt.Errorf("acquire hold: %w", err)
+	}
+	if _, err := hs.expireAll(expiredHolds); err != nil {
+		return nil, fmt.Errorf("acquire hold: %w", err)
+	}
+
+	hold := models.Hold{
+		ID:        uuid.NewString(),
+		TheatreId: order.TheatreId,
+		SlotId:    order.SlotId,
+		OrderDate: order.OrderDate,
+		OrderId:   order.ID,
+		ExpiresAt: now.Add(hs.config.TTL),
+		CreatedAt: now,
+	}
+
+	if err := hs.holdsRepo.Create(hold); err != nil {
+		return nil, err
+	}
+	return &hold, nil
+}
+
+func (hs *HoldsService) Release(holdId string) error {
+	return hs.holdsRepo.Delete(holdId)
+}
+
+// Confirm converts the hold of the order paid with the given razorpay order into a confirmed order, or moves
+// the order to its reschedule when the razorpay order is the top up of one.
+// Payments the order can no longer use are refunded in full and models.ErrPaymentRefunded is returned along with
+// the reason, models.ErrHoldExpired when the order expired before the payment got verified and
+// models.ErrRescheduleFailed when the order could not be moved.
+func (hs *HoldsService) Confirm(razorpayOrderId, paymentId string) error {
+	orderId, err := hs.ordersService.GetIdByRazorpayOrderId(razorpayOrderId)
+	if err != nil {
+		return fmt.Errorf("confirm hold: %w", err)
+	}
+
+	err = hs.ordersService.ApplyReschedule(razorpayOrderId)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, models.ErrRescheduleFailed):
+		return hs.refundUnused(orderId, razorpayOrderId, paymentId, err)
+	case !errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("confirm hold: %w", err)
+	}
+
+	status, err := hs.ordersService.GetStatus(orderId)
+	if err != nil {
+		return fmt.Errorf("confirm hold: %w", err)
+	}
+
+	switch status {
+	case models.OrderPendingPayment:
+		return hs.ordersService.Transition(orderId, models.OrderConfirmed, models.SystemActor, "payment verified")
+	case models.OrderExpired:
+		return hs.refundUnused(orderId, razorpayOrderId, paymentId, models.ErrHoldExpired)
+	case models.OrderCancelled, models.OrderRefunded:
+		return hs.refundUnused(orderId, razorpayOrderId, paymentId, fmt.Errorf("%w: order is %s", models.ErrInvalidOrderTransition, status))
+	}
+
+	// the payment may be verified more than once, the order is already confirmed
+	return nil
+}
+
+// refundUnused refunds the payment which came in for an order or a reschedule that can no longer use it,
+// the refund is recorded against the order
+func (hs *HoldsService) refundUnused(orderId, razorpayOrderId, paymentId string, cause error) error {
+	refund, err := hs.paymentsService.RefundCapture(orderId, razorpayOrderId, paymentId, time.Now())
+	if err != nil {
+		return fmt.Errorf("confirm hold: refund payment after %v: %w", cause, err)
+	}
+	if refund == nil {
+		return fmt.Errorf("%w: %w", cause, models.ErrPaymentRefunded)
+	}
+	return fmt.Errorf("%w: %w with %s", cause, models.ErrPaymentRefunded, refund.ID)
+}
+
+func (hs *HoldsService) GetActiveHolds(theatreId string, from, to time.Time) ([]models.Hold, error) {
+	return hs.holdsRepo.GetActiveHolds(theatreId, from, to, time.Now())
+}
+
+// SweepExpired releases the expired holds, expiring their unpaid orders, and returns the ids of the expired orders
+func (hs *HoldsService) SweepExpired() ([]string, error) {
+	holds, err := hs.holdsRepo.GetExpired(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("sweep expired holds: %w", err)
+	}
+
+	expiredOrderIds, err := hs.expireAll(holds)
+	if err != nil {
+		return expiredOrderIds, fmt.Errorf("sweep expired holds: %w", err)
+	}
+	return expiredOrderIds, nil
+}
+
+func (hs *HoldsService) expireAll(holds []models.Hold) ([]string, error) {
+	expiredOrderIds := make([]string, 0, len(holds))
+	for _, hold := range holds {
+		expired, err := hs.expire(hold)
+		if err != nil {
+			return expiredOrderIds, err
+		}
+		if expired {
+			expiredOrderIds = append(expiredOrderIds, hold.OrderId)
+		}
+	}
+	return expiredOrderIds, nil
+}
+
+func (hs *HoldsService) expire(hold models.Hold) (bool, error) {
+	order, err := hs.ordersService.GetById(hold.OrderId)
+	if err != nil {
+		// the order was never created when the checkout failed half way
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, hs.holdsRepo.Delete(hold.ID)
+		}
+		return false, err
+	}
+
+	if order.Status != models.OrderPendingPayment {
+		return false, hs.holdsRepo.Delete(hold.ID)
+	}
+
+	// the payment went through but its verification never reached us
+	if order.PaymentDetails.Status == models.Success {
+		err = hs.ordersService.Transition(order.ID, models.OrderConfirmed, models.SystemActor, "payment verified")
+		return false, ignoreInvalidTransition(err)
+	}
+
+	err = hs.ordersService.Transition(order.ID, models.OrderExpired, models.SystemActor, "slot hold expired")
+	if err != nil {
+		return false, ignoreInvalidTransition(err)
+	}
+	return true, nil
+}
+
+// StartSweeper periodically releases the expired holds until the context is cancelled
+func (hs *HoldsService) StartSweeper(ctx context.Context, logger *zap.Logger) {
+	ticker := time.NewTicker(hs.config.SweepInterval)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				orderIds, err := hs.SweepExpired()
+				if err != nil {
+					logger.Error("hold sweeper", zap.String("error", err.Error()))
+					continue
+				}
+				if len(orderIds) > 0 {
+					logger.Info("hold sweeper", zap.Strings("expired_orders", orderIds))
+				}
+			}
+		}
+	}()
+}
+
+// ignoreInvalidTransition treats an order which already moved on as done, the background jobs
+// race with the customer facing flows and either of them getting there first is fine
+func ignoreInvalidTransition(err error) error {
+	if errors.Is(err, models.ErrInvalidOrderTransition) {
+		return nil
+	}
+	return err
+}
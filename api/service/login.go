@@ -0,0 +1,137 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/auth"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+// loginBaseDelay is how long the first slowed down login attempt waits
+const loginBaseDelay = time.Second
+
+// dummyPasswordHash is compared against when the email has no user, so both cases take as long
+var dummyPasswordHash, _ = auth.HashPassword("not the password of any user")
+
+type LoginService struct {
+	usersRepo     repository.UsersRepository
+	throttlesRepo repository.LoginThrottlesRepository
+	config        models.LoginThrottleConfig
+}
+
+func NewLoginService(usersRepo repository.UsersRepository, throttlesRepo repository.LoginThrottlesRepository, throttleConfig models.LoginThrottleConfig) LoginService {
+	return LoginService{
+		usersRepo:     usersRepo,
+		throttlesRepo: throttlesRepo,
+		config:        throttleConfig,
+	}
+}
+
+// Login checks the credentials, returning models.ErrInvalidCredentials whether the email or the password is wrong,
+// the wrapped error tells which one for the logs.
+// Failed logins slow down the next attempts for the email and the IP address, a *models.LoginThrottledError
+// is returned while they have to wait.
+func (ls *LoginService) Login(params models.LoginParams, ip string) (*models.User, error) {
+	now := time.Now()
+	emailKey, ipKey := loginEmailKey(params.Email), loginIPKey(ip)
+	emailLimits, ipLimits := ls.limits()
+
+	// the attempt is counted as a failure before the password is checked, and taken back when it turns out right
+	counted, err := ls.throttlesRepo.Attempt(emailKey, emailLimits, now)
+	if err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	if !counted {
+		return nil, ls.throttled(emailKey, ipKey, now)
+	}
+
+	counted, err = ls.throttlesRepo.Attempt(ipKey, ipLimits, now)
+	if err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	if !counted {
+		if err := ls.throttlesRepo.Forgive(emailKey, emailLimits, now); err != nil {
+			return nil, fmt.Errorf("login: %w", err)
+		}
+		return nil, ls.throttled(emailKey, ipKey, now)
+	}
+
+	user, err := ls.usersRepo.GetByEmail(params.Email)
+	if err != nil && !errors.Is(err, models.ErrNoUserWithEmail) {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+
+	if user == nil {
+		auth.ComparePasswordToHash(dummyPasswordHash, params.Password)
+		return nil, fmt.Errorf("%w: unknown email", models.ErrInvalidCredentials)
+	}
+	if !auth.ComparePasswordToHash(user.Password, params.Password) {
+		return nil, fmt.Errorf("%w: wrong password", models.ErrInvalidCredentials)
+	}
+
+	if err := ls.throttlesRepo.Clear(emailKey); err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+	if err := ls.throttlesRepo.Forgive(ipKey, ipLimits, now); err != nil {
+		return nil, fmt.Errorf("login: %w", err)
+	}
+
+	if user.DisabledAt != nil {
+		return nil, models.ErrUserDisabled
+	}
+	if user.EmailVerifiedAt == nil {
+		return nil, models.ErrEmailNotVerified
+	}
+	return user, nil
+}
+
+// Unlock forgets the failed logins of the user's email
+func (ls *LoginService) Unlock(userId string) error {
+	user, err := ls.usersRepo.GetByUserId(userId)
+	if err != nil {
+		return err
+	}
+	return ls.throttlesRepo.Clear(loginEmailKey(user.Email))
+}
+
+// limits are the limits of the failed logins of an email and of an IP address
+func (ls *LoginService) limits() (models.LoginLimits, models.LoginLimits) {
+	factor := ls.config.IPAttemptsFactor
+	emailLimits := models.LoginLimits{
+		FreeAttempts:    ls.config.FreeAttempts,
+		LockoutAttempts: ls.config.LockoutAttempts,
+		BaseDelay:       loginBaseDelay,
+		LockoutDuration: ls.config.LockoutDuration,
+	}
+	ipLimits := emailLimits
+	ipLimits.FreeAttempts *= factor
+	ipLimits.LockoutAttempts *= factor
+	return emailLimits, ipLimits
+}
+
+// throttled tells how long the login has to wait for the lockout of the email or the IP address to end
+func (ls *LoginService) throttled(emailKey, ipKey string, now time.Time) error {
+	lockedUntil, err := ls.throttlesRepo.GetLockedUntil([]string{emailKey, ipKey}, now)
+	if err != nil {
+		return fmt.Errorf("login: %w", err)
+	}
+
+	// the lockout may have just ended, the next attempt is let through
+	var retryAfter time.Duration
+	if lockedUntil != nil {
+		retryAfter = lockedUntil.Sub(now)
+	}
+	return &models.LoginThrottledError{RetryAfter: retryAfter}
+}
+
+func loginEmailKey(email string) string {
+	return "email:" + strings.ToLower(email)
+}
+
+func loginIPKey(ip string) string {
+	return "ip:" + ip
+}
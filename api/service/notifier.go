@@ -0,0 +1,31 @@
+package service
+
+import (
+	"github.com/ortin779/private_theatre_api/api/models"
+	"go.uber.org/zap"
+)
+
+// Notifier delivers messages to the users, like their email verification codes
+type Notifier interface {
+	Send(message models.Message) error
+}
+
+// LogNotifier writes the messages to the log instead of delivering them, which is enough for local development
+type LogNotifier struct {
+	logger *zap.Logger
+}
+
+func NewLogNotifier(logger *zap.Logger) *LogNotifier {
+	return &LogNotifier{
+		logger: logger,
+	}
+}
+
+func (ln *LogNotifier) Send(message models.Message) error {
+	ln.logger.Info("notification",
+		zap.String("to", message.To),
+		zap.String("subject", message.Subject),
+		zap.String("body", message.Body),
+	)
+	return nil
+}
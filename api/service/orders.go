@@ -3,7 +3,10 @@ package service
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/repository"
 )
@@ -22,20 +25,94 @@ var ErrDuplicateOrder = errors.New("order already exists for the given theatre a
 
 func (o *OrdersService) Create(order models.Order) error {
 	_, err := o.ordersRepo.GetOrderByTheatreIdAndSlotIdAndOrderDate(order.SlotId, order.TheatreId, order.OrderDate)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return ErrDuplicateOrder
-		}
+	if err == nil {
+		return ErrDuplicateOrder
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
 		return err
 	}
 
+	order.Status = models.OrderPendingPayment
 	return o.ordersRepo.Create(order)
 }
 
-func (o *OrdersService) GetAll() ([]models.OrderDetails, error) {
-	return o.ordersRepo.GetAll()
+func (o *OrdersService) List(filter models.OrdersFilter) (*models.OrdersPage, error) {
+	return o.ordersRepo.List(filter)
 }
 
 func (o *OrdersService) GetById(id string) (*models.OrderDetails, error) {
 	return o.ordersRepo.GetById(id)
 }
+
+func (o *OrdersService) GetIdByRazorpayOrderId(razorpayOrderId string) (string, error) {
+	return o.ordersRepo.GetIdByRazorpayOrderId(razorpayOrderId)
+}
+
+func (o *OrdersService) IsOwnedBy(orderId, userId string) (bool, error) {
+	return o.ordersRepo.IsOwnedBy(orderId, userId)
+}
+
+func (o *OrdersService) GetStatus(orderId string) (models.OrderStatus, error) {
+	return o.ordersRepo.GetStatus(orderId)
+}
+
+func (o *OrdersService) GetHistory(orderId string) ([]models.OrderTransition, error) {
+	return o.ordersRepo.GetHistory(orderId)
+}
+
+// Transition moves the order to the given status if the order lifecycle allows it, recording who made the change.
+// It returns models.ErrInvalidOrderTransition when the order can not move to the status.
+func (o *OrdersService) Transition(orderId string, to models.OrderStatus, actor, note string) error {
+	from, err := o.ordersRepo.GetStatus(orderId)
+	if err != nil {
+		return fmt.Errorf("transition order: %w", err)
+	}
+	return o.TransitionFrom(orderId, from, to, actor, note)
+}
+
+// TransitionFrom moves the order to the given status only if it is still in the from status, so that of the
+// requests racing to move an order only one gets to.
+// It returns models.ErrInvalidOrderTransition when the order is no longer in the from status or can not move to the status.
+func (o *OrdersService) TransitionFrom(orderId string, from, to models.OrderStatus, actor, note string) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s to %s", models.ErrInvalidOrderTransition, from, to)
+	}
+
+	return o.ordersRepo.Transition(models.OrderTransition{
+		ID:         uuid.NewString(),
+		OrderId:    orderId,
+		FromStatus: from,
+		ToStatus:   to,
+		Actor:      actor,
+		Note:       note,
+		CreatedAt:  time.Now(),
+	})
+}
+
+// Cancel moves the order to cancelled if it is still in the from status, recording the refund it owes along with it
+func (o *OrdersService) Cancel(orderId string, from models.OrderStatus, actor, note string, details models.MetaData, refund *models.RefundRequest) error {
+	if !from.CanTransitionTo(models.OrderCancelled) {
+		return fmt.Errorf("%w: %s to %s", models.ErrInvalidOrderTransition, from, models.OrderCancelled)
+	}
+
+	return o.ordersRepo.Cancel(models.OrderTransition{
+		ID:         uuid.NewString(),
+		OrderId:    orderId,
+		FromStatus: from,
+		ToStatus:   models.OrderCancelled,
+		Actor:      actor,
+		Note:       note,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	}, refund)
+}
+
+func (o *OrdersService) Reschedule(reschedule models.Reschedule) error {
+	return o.ordersRepo.Reschedule(reschedule)
+}
+
+// ApplyReschedule moves the order to the reschedule paid with the razorpay top up order,
+// sql.ErrNoRows is returned when the razorpay order is not a top up
+func (o *OrdersService) ApplyReschedule(razorpayOrderId string) error {
+	return o.ordersRepo.ApplyReschedule(razorpayOrderId, time.Now())
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+// fakeHoldsRepository keeps the holds in memory
+type fakeHoldsRepository struct {
+	repository.HoldsRepository
+	holds map[string]models.Hold
+}
+
+func (fr *fakeHoldsRepository) Create(hold models.Hold) error {
+	fr.holds[hold.ID] = hold
+	return nil
+}
+
+func (fr *fakeHoldsRepository) Delete(id string) error {
+	delete(fr.holds, id)
+	return nil
+}
+
+func (fr *fakeHoldsRepository) GetExpiredOnSlot(theatreId, slotId string, orderDate, now time.Time) ([]models.Hold, error) {
+	return nil, nil
+}
+
+func (fr *fakeOrdersRepository) GetOrderByTheatreIdAndSlotIdAndOrderDate(slotId, theatreId string, orderDate time.Time) (*models.OrderDetails, error) {
+	return nil, fmt.Errorf("get order: %w", sql.ErrNoRows)
+}
+
+func (fr *fakeOrdersRepository) Create(order models.Order) error {
+	fr.razorpayOrders[order.RazorpayOrderId] = order.ID
+	fr.statuses[order.ID] = order.Status
+	fr.orders[order.ID] = models.OrderDetails{
+		ID:             order.ID,
+		CustomerName:   order.CustomerName,
+		TotalPrice:     order.TotalPrice,
+		OrderDate:      order.OrderDate,
+		OrderedAt:      order.OrderedAt,
+		PriceBreakdown: order.PriceBreakdown,
+		PaymentDetails: models.OrderPayment{
+			PaymentVerificationBody: models.PaymentVerificationBody{RazorpayOrderId: order.RazorpayOrderId},
+		},
+	}
+	return nil
+}
+
+// TestOrderFlow books, pays and cancels an order the way the handlers do, with the fake gateway standing in for razorpay
+func TestOrderFlow(t *testing.T) {
+	gateway := NewFakeGateway("secret")
+	paymentsRepo := newFakePaymentsRepository()
+	ordersRepo := &fakeOrdersRepository{
+		razorpayOrders: make(map[string]string),
+		statuses:       make(map[string]models.OrderStatus),
+		orders:         make(map[string]models.OrderDetails),
+		history:        make(map[string][]models.OrderTransition),
+		payments:       paymentsRepo,
+	}
+	holdsRepo := &fakeHoldsRepository{holds: make(map[string]models.Hold)}
+
+	ordersService := NewOrdersService(ordersRepo)
+	paymentsService := NewPaymentsService(paymentsRepo, gateway)
+	holdsService := NewHoldsService(holdsRepo, ordersService, paymentsService, models.HoldConfig{TTL: time.Minute})
+	cancellations := NewCancellationService(ordersService, paymentsService, models.CancellationPolicy{FullRefundHours: 48, NoRefundHours: 4, PartialRefundPercent: 50})
+
+	order := models.Order{
+		ID:           uuid.NewString(),
+		CustomerName: "Asha",
+		TheatreId:    uuid.NewString(),
+		SlotId:       uuid.NewString(),
+		NoOfPersons:  4,
+		TotalPrice:   testOrderAmount / 100,
+		OrderDate:    time.Now().AddDate(0, 0, 7),
+		OrderedAt:    time.Now(),
+	}
+
+	hold, err := holdsService.Acquire(order)
+	if err != nil {
+		t.Fatalf("acquire hold: %v", err)
+	}
+	order.RazorpayOrderId, err = paymentsService.CreateOrder(testOrderAmount)
+	if err != nil {
+		t.Fatalf("create payment order: %v", err)
+	}
+	order.HoldExpiresAt = &hold.ExpiresAt
+	if err := ordersService.Create(order); err != nil {
+		t.Fatalf("create order: %v", err)
+	}
+
+	verification, err := gateway.Pay(order.RazorpayOrderId)
+	if err != nil {
+		t.Fatalf("pay order: %v", err)
+	}
+	if err := paymentsService.VerifyPayment(*verification); err != nil {
+		t.Fatalf("verify payment: %v", err)
+	}
+	if err := holdsService.Confirm(verification.RazorpayOrderId, verification.RazorpayPaymentId); err != nil {
+		t.Fatalf("confirm order: %v", err)
+	}
+	if status := ordersRepo.statuses[order.ID]; status != models.OrderConfirmed {
+		t.Fatalf("expected the order to be confirmed, got %s", status)
+	}
+
+	cancellation, err := cancellations.Cancel(order.ID, "customer")
+	if err != nil {
+		t.Fatalf("cancel order: %v", err)
+	}
+	if cancellation.RefundPercent != 100 {
+		t.Errorf("expected a full refund a week ahead of the slot, got %d%%", cancellation.RefundPercent)
+	}
+	if len(cancellation.Refunds) != 1 {
+		t.Fatalf("expected a single refund, got %d", len(cancellation.Refunds))
+	}
+
+	refund := cancellation.Refunds[0]
+	if refund.Amount != testOrderAmount || refund.RazorpayPaymentId != verification.RazorpayPaymentId {
+		t.Errorf("expected %d to be refunded from %s, got %d from %s", testOrderAmount, verification.RazorpayPaymentId, refund.Amount, refund.RazorpayPaymentId)
+	}
+	if recorded, ok := paymentsRepo.refunds[refund.ID]; !ok || recorded.Status != models.RefundProcessed {
+		t.Errorf("expected the refund to be recorded as processed, got %+v", recorded)
+	}
+	if payment, _ := gateway.FetchPayment(verification.RazorpayPaymentId); payment.Status != "refunded" {
+		t.Errorf("expected the gateway payment to be refunded, got %s", payment.Status)
+	}
+	if status := ordersRepo.statuses[order.ID]; status != models.OrderRefunded {
+		t.Errorf("expected the order to be refunded, got %s", status)
+	}
+}
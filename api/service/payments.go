@@ -7,45 +7,35 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/repository"
-	"github.com/razorpay/razorpay-go"
 )
 
-type RazorpayService struct {
+type PaymentsService struct {
 	paymentRepo repository.PaymentsRepository
-	config      models.RazorpayConfig
-	client      *razorpay.Client
+	gateway     PaymentGateway
 }
 
 var (
 	ErrPaymentSignatureFailure = errors.New("invalid payment signature")
 )
 
-func NewRazorpayService(paymentRepo repository.PaymentsRepository, paymentConfig models.RazorpayConfig) RazorpayService {
-	return RazorpayService{
+func NewPaymentsService(paymentRepo repository.PaymentsRepository, gateway PaymentGateway) PaymentsService {
+	return PaymentsService{
 		paymentRepo: paymentRepo,
-		config:      paymentConfig,
-		client:      razorpay.NewClient(paymentConfig.Key, paymentConfig.Secret),
+		gateway:     gateway,
 	}
 }
 
-func (paymentService *RazorpayService) CreateOrder(amount int) (string, error) {
-	razorpayData := map[string]any{
-		"amount":          amount,
-		"currency":        "INR",
-		"partial_payment": false,
-	}
-
-	razorpayOrder, err := paymentService.client.Order.Create(razorpayData, nil)
+func (paymentService *PaymentsService) CreateOrder(amount int) (string, error) {
+	paymentOrderId, err := paymentService.gateway.CreateOrder(amount)
 	if err != nil {
 		return "", fmt.Errorf("create payment order: %w", err)
 	}
 
-	paymentOrderId := (razorpayOrder["id"]).(string)
-
-	err = paymentService.paymentRepo.Create(paymentOrderId, string(models.Pending))
+	err = paymentService.paymentRepo.Create(paymentOrderId, string(models.Pending), amount)
 
 	if err != nil {
 		return "", fmt.Errorf("create payment order: %w", err)
@@ -53,8 +43,8 @@ func (paymentService *RazorpayService) CreateOrder(amount int) (string, error) {
 	return paymentOrderId, nil
 }
 
-func (paymentService *RazorpayService) VerifyPayment(verificationBody models.PaymentVerificationBody) error {
-	isValidSignature := verifySignature(verificationBody.RazorpayOrderId, verificationBody.RazorpayPaymentId, verificationBody.RazorpaySignature, paymentService.config.Secret)
+func (paymentService *PaymentsService) VerifyPayment(verificationBody models.PaymentVerificationBody) error {
+	isValidSignature := paymentService.gateway.VerifySignature(verificationBody.RazorpayOrderId, verificationBody.RazorpayPaymentId, verificationBody.RazorpaySignature)
 	if !isValidSignature {
 		return ErrPaymentSignatureFailure
 	}
@@ -67,15 +57,107 @@ func (paymentService *RazorpayService) VerifyPayment(verificationBody models.Pay
 	return nil
 }
 
-func verifySignature(orderId, paymentId, signature, secret string) bool {
-	data := orderId + "|" + paymentId
-	h := hmac.New(sha256.New, []byte(secret))
-	_, err := h.Write([]byte(data))
+func (paymentService *PaymentsService) FetchPayment(paymentId string) (*models.GatewayPayment, error) {
+	return paymentService.gateway.FetchPayment(paymentId)
+}
+
+// RefundPayments refunds what is outstanding of the refund request from the captured payments of the order,
+// starting with the latest one, and records the refunds. The refund of every payment is made with the request id
+// suffixed with the razorpay order of the payment as the idempotency key, and the payments refunded for the request
+// before are skipped, so a request which failed half way is retried by refunding it again.
+// The refunds of the request, along with those made before a failure, are returned.
+func (paymentService *PaymentsService) RefundPayments(order models.OrderDetails, request models.RefundRequest, at time.Time) ([]models.Refund, error) {
+	refunds := append(make([]models.Refund, 0, len(request.Refunds)+1), request.Refunds...)
+	refunded := make(map[string]bool, len(request.Refunds))
+	for _, refund := range request.Refunds {
+		refunded[refund.RazorpayOrderId] = true
+	}
+
+	amount := request.Outstanding()
+	for i := len(order.Payments) - 1; i >= 0 && amount > 0; i-- {
+		payment := order.Payments[i]
+		refundable := min(amount, payment.Amount-payment.Refunded)
+		if refunded[payment.RazorpayOrderId] || refundable <= 0 {
+			continue
+		}
+
+		refund, err := paymentService.refund(order.ID, payment, refundable, request.ID+"-"+payment.RazorpayOrderId, request.ID, at)
+		if err != nil {
+			return refunds, err
+		}
+		refunds = append(refunds, *refund)
+		// a refund made before its recording failed comes back from the gateway as it was made
+		amount -= refund.Amount
+	}
+	return refunds, nil
+}
 
+// GetRefundRequest returns the refund request with the refunds made for it, sql.ErrNoRows is returned
+// when nothing was owed under the id
+func (paymentService *PaymentsService) GetRefundRequest(id string) (*models.RefundRequest, error) {
+	return paymentService.paymentRepo.GetRefundRequest(id)
+}
+
+// RefundCapture refunds what is left of the payment of the razorpay order, for payments which came in
+// after the order or the reschedule they were made for could no longer use them
+func (paymentService *PaymentsService) RefundCapture(orderId, razorpayOrderId, paymentId string, at time.Time) (*models.Refund, error) {
+	payment, err := paymentService.paymentRepo.GetPayment(razorpayOrderId)
 	if err != nil {
-		return false
+		return nil, fmt.Errorf("refund payment: %w", err)
 	}
-	generatedSignature := hex.EncodeToString(h.Sum(nil))
+	payment.RazorpayPaymentId = paymentId
+
+	if payment.Amount <= payment.Refunded {
+		return nil, nil
+	}
+	return paymentService.refund(orderId, *payment, payment.Amount-payment.Refunded, razorpayOrderId+"-unused", "", at)
+}
+
+func (paymentService *PaymentsService) refund(orderId string, payment models.CapturedPayment, amount int, idempotencyKey, refundRequestId string, at time.Time) (*models.Refund, error) {
+	gatewayRefund, err := paymentService.gateway.Refund(payment.RazorpayPaymentId, amount, idempotencyKey)
+	if err != nil {
+		return nil, fmt.Errorf("refund payment %s: %w", payment.RazorpayPaymentId, err)
+	}
+
+	refund := models.Refund{
+		ID:                gatewayRefund.ID,
+		OrderId:           orderId,
+		RazorpayOrderId:   payment.RazorpayOrderId,
+		RazorpayPaymentId: payment.RazorpayPaymentId,
+		Amount:            gatewayRefund.Amount,
+		Status:            gatewayRefund.Status,
+		RefundRequestId:   refundRequestId,
+		CreatedAt:         at,
+	}
+	if err := paymentService.paymentRepo.CreateRefund(refund); err != nil {
+		return nil, fmt.Errorf("refund payment %s after gateway refund %s: %w", payment.RazorpayPaymentId, refund.ID, err)
+	}
+	return &refund, nil
+}
+
+// HasPendingRefunds reports whether any refund of the order is yet to reach the customer
+func (paymentService *PaymentsService) HasPendingRefunds(orderId string) (bool, error) {
+	return paymentService.paymentRepo.HasPendingRefunds(orderId)
+}
+
+func (paymentService *PaymentsService) GetRefund(refundId string) (*models.Refund, error) {
+	return paymentService.paymentRepo.GetRefund(refundId)
+}
+
+func verifySignature(orderId, paymentId, signature, secret string) bool {
+	return verifyHmacSignature([]byte(orderId+"|"+paymentId), signature, secret)
+}
+
+// verifyHmacSignature checks the signature is the hex encoded HMAC-SHA256 of data with the secret
+func verifyHmacSignature(data []byte, signature, secret string) bool {
+	generatedSignature := hmacSignature(data, secret)
 
 	return subtle.ConstantTimeCompare([]byte(generatedSignature), []byte(signature)) == 1
 }
+
+func hmacSignature(data []byte, secret string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
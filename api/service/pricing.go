@@ -0,0 +1,288 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+type PricingService struct {
+	theatresRepo     repository.TheatreRepository
+	addonsRepo       repository.AddonRepository
+	schedulesRepo    repository.SchedulesRepository
+	pricingRulesRepo repository.PricingRulesRepository
+	couponsRepo      repository.CouponsRepository
+	config           models.PricingConfig
+}
+
+var (
+	ErrInvalidOrder  = errors.New("invalid order")
+	ErrPriceMismatch = errors.New("order total does not match the price")
+)
+
+func NewPricingService(theatresRepo repository.TheatreRepository, addonsRepo repository.AddonRepository, schedulesRepo repository.SchedulesRepository, pricingRulesRepo repository.PricingRulesRepository, couponsRepo repository.CouponsRepository, pricingConfig models.PricingConfig) PricingService {
+	return PricingService{
+		theatresRepo:     theatresRepo,
+		addonsRepo:       addonsRepo,
+		schedulesRepo:    schedulesRepo,
+		pricingRulesRepo: pricingRulesRepo,
+		couponsRepo:      couponsRepo,
+		config:           pricingConfig,
+	}
+}
+
+// Calculate prices the booking of the theatre slot from the theatre and addon prices stored with us,
+// it is used both for quotes and order creation so that both always agree.
+// The coupon of the booking is applied before tax.
+// It returns ErrInvalidOrder when the booking can not be priced or its total is below models.MinPaymentAmount, and
+// models.ErrInvalidCoupon when the coupon can not be used.
+func (ps *PricingService) Calculate(params models.QuoteParams) (*models.PriceBreakdown, error) {
+	addonItems, err := ps.addonItems(params.Addons)
+	if err != nil {
+		return nil, err
+	}
+	breakdown, err := ps.price(params, addonItems)
+	if err != nil {
+		return nil, err
+	}
+
+	// the pricing rules may take the charges down to nothing, which can not be paid for
+	ps.total(breakdown)
+	if breakdown.Total < models.MinPaymentAmount {
+		return nil, fmt.Errorf("%w: total is below the minimum payment of %d paise", ErrInvalidOrder, models.MinPaymentAmount)
+	}
+
+	if params.CouponCode != "" {
+		discount, err := ps.couponDiscount(params, breakdown.Items)
+		if err != nil {
+			return nil, err
+		}
+		breakdown.Items = append(breakdown.Items, discount)
+		ps.total(breakdown)
+
+		if breakdown.Total < models.MinPaymentAmount {
+			return nil, fmt.Errorf("%w: coupon %s leaves less than the minimum payment of %d paise", models.ErrInvalidCoupon, params.CouponCode, models.MinPaymentAmount)
+		}
+	}
+	return breakdown, nil
+}
+
+// Reprice prices an existing order for another booking, the theatre is priced afresh while the addons keep
+// the unit prices they were ordered at and the order keeps its coupon discount, as long as it is not more than the new charges
+func (ps *PricingService) Reprice(params models.QuoteParams, orderedAddons []models.OrderAddonDetails, priced *models.PriceBreakdown) (*models.PriceBreakdown, error) {
+	addonItems := make([]models.PriceLineItem, 0, len(orderedAddons))
+	for _, addon := range orderedAddons {
+		unitPrice := models.ToPaise(addon.Price)
+		addonItems = append(addonItems, models.PriceLineItem{
+			Type:      models.AddonPriceItem,
+			Name:      addon.Name,
+			AddonId:   addon.ID,
+			Quantity:  addon.Quantity,
+			UnitPrice: unitPrice,
+			Amount:    unitPrice * addon.Quantity,
+		})
+	}
+	breakdown, err := ps.price(params, addonItems)
+	if err != nil {
+		return nil, err
+	}
+
+	if priced != nil {
+		if discount, ok := priced.CouponDiscount(); ok {
+			var charges int
+			for _, item := range breakdown.Items {
+				charges += item.Amount
+			}
+			discount.Amount = max(discount.Amount, -charges)
+			discount.UnitPrice = discount.Amount
+			breakdown.Items = append(breakdown.Items, discount)
+		}
+	}
+	ps.total(breakdown)
+	return breakdown, nil
+}
+
+func (ps *PricingService) price(params models.QuoteParams, addonItems []models.PriceLineItem) (*models.PriceBreakdown, error) {
+	theatre, err := ps.theatresRepo.GetTheatreDetails(params.TheatreId)
+	if err != nil {
+		return nil, fmt.Errorf("calculate price: %w", err)
+	}
+
+	if theatre.ArchivedAt != nil {
+		return nil, fmt.Errorf("%w: theatre is no longer available", ErrInvalidOrder)
+	}
+	if !slices.ContainsFunc(theatre.Slots, func(slot models.Slot) bool { return slot.ID == params.SlotId }) {
+		return nil, fmt.Errorf("%w: slot is not available in the theatre", ErrInvalidOrder)
+	}
+	schedule, err := getSchedule(ps.schedulesRepo, theatre.ID, params.OrderDate, &params.OrderDate)
+	if err != nil {
+		return nil, fmt.Errorf("calculate price: %w", err)
+	}
+	if !schedule.Runs(params.SlotId, params.OrderDate) {
+		return nil, fmt.Errorf("%w: slot is closed on %s", ErrInvalidOrder, params.OrderDate.Format(time.DateOnly))
+	}
+	if params.NoOfPersons < theatre.MinCapacity || params.NoOfPersons > theatre.MaxCapacity {
+		return nil, fmt.Errorf("%w: no of persons should be between %d and %d", ErrInvalidOrder, theatre.MinCapacity, theatre.MaxCapacity)
+	}
+
+	basePrice := models.ToPaise(theatre.Price)
+	breakdown := models.PriceBreakdown{
+		Items: []models.PriceLineItem{
+			{
+				Type:      models.BasePriceItem,
+				Name:      theatre.Name,
+				Quantity:  1,
+				UnitPrice: basePrice,
+				Amount:    basePrice,
+			},
+		},
+		Currency: "INR",
+	}
+
+	if extraPersons := params.NoOfPersons - theatre.DefaultCapacity; extraPersons > 0 {
+		pricePerHead := models.ToPaise(theatre.AdditionalPricePerHead)
+		breakdown.Items = append(breakdown.Items, models.PriceLineItem{
+			Type:      models.ExtraPersonItem,
+			Name:      "additional persons",
+			Quantity:  extraPersons,
+			UnitPrice: pricePerHead,
+			Amount:    pricePerHead * extraPersons,
+		})
+	}
+
+	adjustmentItems, err := ps.adjustmentItems(theatre.Theatre, params, breakdown.Items)
+	if err != nil {
+		return nil, err
+	}
+	breakdown.Items = append(breakdown.Items, adjustmentItems...)
+
+	breakdown.Items = append(breakdown.Items, addonItems...)
+
+	return &breakdown, nil
+}
+
+// total sums the items of the breakdown and adds the tax on them
+func (ps *PricingService) total(breakdown *models.PriceBreakdown) {
+	breakdown.Subtotal = 0
+	for _, item := range breakdown.Items {
+		breakdown.Subtotal += item.Amount
+	}
+	breakdown.TaxPercent = ps.config.TaxPercent
+	breakdown.Tax = int(math.Round(float64(breakdown.Subtotal) * ps.config.TaxPercent / 100))
+	breakdown.Total = breakdown.Subtotal + breakdown.Tax
+}
+
+// couponDiscount returns the discount of the coupon on the priced items. The usage limits are checked again
+// when the order is saved, as other orders may use the coupon in the meantime.
+func (ps *PricingService) couponDiscount(params models.QuoteParams, items []models.PriceLineItem) (models.PriceLineItem, error) {
+	coupon, err := ps.couponsRepo.GetByCode(models.NormalizeCouponCode(params.CouponCode))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.PriceLineItem{}, fmt.Errorf("%w: coupon %s does not exist", models.ErrInvalidCoupon, params.CouponCode)
+	}
+	if err != nil {
+		return models.PriceLineItem{}, fmt.Errorf("calculate price: %w", err)
+	}
+
+	if err := coupon.CheckUsable(params.TheatreId, time.Now()); err != nil {
+		return models.PriceLineItem{}, err
+	}
+	return coupon.DiscountItem(items)
+}
+
+// adjustmentItems applies the pricing rules matching the booking to the theatre charges, one after the other from
+// the highest priority, stopping after an exclusive rule
+func (ps *PricingService) adjustmentItems(theatre models.Theatre, params models.QuoteParams, theatreItems []models.PriceLineItem) ([]models.PriceLineItem, error) {
+	rules, err := ps.pricingRulesRepo.GetForTheatre(theatre.ID)
+	if err != nil {
+		return nil, fmt.Errorf("calculate price: %w", err)
+	}
+
+	// the lead time is counted in days from today at the theatre
+	now := time.Now().In(theatre.Location())
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	orderDate := time.Date(params.OrderDate.Year(), params.OrderDate.Month(), params.OrderDate.Day(), 0, 0, 0, 0, time.UTC)
+	leadDays := int(orderDate.Sub(today).Hours() / 24)
+
+	var charges int
+	for _, item := range theatreItems {
+		charges += item.Amount
+	}
+
+	var items []models.PriceLineItem
+	for _, rule := range rules {
+		if !rule.Matches(theatre.ID, params.SlotId, params.OrderDate, leadDays) {
+			continue
+		}
+
+		adjustment := rule.AdjustmentFor(charges)
+		charges += adjustment
+		items = append(items, models.PriceLineItem{
+			Type:      models.AdjustmentItem,
+			Name:      rule.Name,
+			RuleId:    rule.ID,
+			Quantity:  1,
+			UnitPrice: adjustment,
+			Amount:    adjustment,
+		})
+
+		if rule.Exclusive {
+			break
+		}
+	}
+	return items, nil
+}
+
+// CheckTotal makes sure the total price the customer agreed to is the one we calculated
+func (ps *PricingService) CheckTotal(breakdown *models.PriceBreakdown, totalPrice int) error {
+	if breakdown.TotalInRupees() != totalPrice {
+		return fmt.Errorf("%w: expected %d, got %d", ErrPriceMismatch, breakdown.TotalInRupees(), totalPrice)
+	}
+	return nil
+}
+
+func (ps *PricingService) addonItems(orderAddons []models.OrderAddon) ([]models.PriceLineItem, error) {
+	if len(orderAddons) == 0 {
+		return nil, nil
+	}
+
+	addonIds := make([]string, 0, len(orderAddons))
+	for _, orderAddon := range orderAddons {
+		if slices.Contains(addonIds, orderAddon.ID) {
+			return nil, fmt.Errorf("%w: addon %s is added more than once", ErrInvalidOrder, orderAddon.ID)
+		}
+		addonIds = append(addonIds, orderAddon.ID)
+	}
+
+	addons, err := ps.addonsRepo.GetByIds(addonIds)
+	if err != nil {
+		return nil, fmt.Errorf("calculate price: %w", err)
+	}
+
+	items := make([]models.PriceLineItem, 0, len(orderAddons))
+	for _, orderAddon := range orderAddons {
+		idx := slices.IndexFunc(addons, func(addon models.Addon) bool { return addon.ID == orderAddon.ID })
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: addon %s does not exist", ErrInvalidOrder, orderAddon.ID)
+		}
+		if addons[idx].ArchivedAt != nil {
+			return nil, fmt.Errorf("%w: addon %s is no longer available", ErrInvalidOrder, orderAddon.ID)
+		}
+
+		unitPrice := models.ToPaise(addons[idx].Price)
+		items = append(items, models.PriceLineItem{
+			Type:      models.AddonPriceItem,
+			Name:      addons[idx].Name,
+			AddonId:   addons[idx].ID,
+			Quantity:  orderAddon.Quantity,
+			UnitPrice: unitPrice,
+			Amount:    unitPrice * orderAddon.Quantity,
+		})
+	}
+	return items, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+type PricingRulesService struct {
+	pricingRulesRepo repository.PricingRulesRepository
+}
+
+func NewPricingRulesService(pricingRulesRepo repository.PricingRulesRepository) PricingRulesService {
+	return PricingRulesService{
+		pricingRulesRepo: pricingRulesRepo,
+	}
+}
+
+func (prs *PricingRulesService) GetAll() ([]models.PricingRule, error) {
+	return prs.pricingRulesRepo.GetAll()
+}
+
+func (prs *PricingRulesService) Get(id string) (*models.PricingRule, error) {
+	return prs.pricingRulesRepo.GetById(id)
+}
+
+func (prs *PricingRulesService) Create(params models.PricingRuleParams, userId string) (*models.PricingRule, error) {
+	now := time.Now()
+	rule := params.Rule(models.PricingRule{
+		ID:        uuid.NewString(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		CreatedBy: userId,
+		UpdatedBy: userId,
+	})
+
+	if err := prs.pricingRulesRepo.Create(rule); err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// Update replaces the conditions and the adjustment of the rule, orders already made keep their price
+func (prs *PricingRulesService) Update(id string, params models.PricingRuleParams, userId string) (*models.PricingRule, error) {
+	rule, err := prs.pricingRulesRepo.GetById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := params.Rule(*rule)
+	updated.UpdatedAt = time.Now()
+	updated.UpdatedBy = userId
+
+	if err := prs.pricingRulesRepo.Update(updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+func (prs *PricingRulesService) Delete(id string) error {
+	return prs.pricingRulesRepo.Delete(id)
+}
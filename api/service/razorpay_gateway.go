@@ -0,0 +1,82 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/razorpay/razorpay-go"
+)
+
+type razorpayGateway struct {
+	config models.RazorpayConfig
+	client *razorpay.Client
+}
+
+func NewRazorpayGateway(razorpayConfig models.RazorpayConfig) PaymentGateway {
+	return &razorpayGateway{
+		config: razorpayConfig,
+		client: razorpay.NewClient(razorpayConfig.Key, razorpayConfig.Secret),
+	}
+}
+
+func (rg *razorpayGateway) CreateOrder(amount int) (string, error) {
+	razorpayData := map[string]any{
+		"amount":          amount,
+		"currency":        "INR",
+		"partial_payment": false,
+	}
+
+	razorpayOrder, err := rg.client.Order.Create(razorpayData, nil)
+	if err != nil {
+		return "", fmt.Errorf("create razorpay order: %w", err)
+	}
+
+	orderId, _ := razorpayOrder["id"].(string)
+	if orderId == "" {
+		return "", fmt.Errorf("create razorpay order: no order id in the response")
+	}
+	return orderId, nil
+}
+
+func (rg *razorpayGateway) VerifySignature(orderId, paymentId, signature string) bool {
+	return verifySignature(orderId, paymentId, signature, rg.config.Secret)
+}
+
+func (rg *razorpayGateway) FetchPayment(paymentId string) (*models.GatewayPayment, error) {
+	payment, err := rg.client.Payment.Fetch(paymentId, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch razorpay payment: %w", err)
+	}
+
+	return &models.GatewayPayment{
+		ID:      stringField(payment, "id"),
+		OrderId: stringField(payment, "order_id"),
+		Amount:  intField(payment, "amount"),
+		Status:  stringField(payment, "status"),
+	}, nil
+}
+
+func (rg *razorpayGateway) Refund(paymentId string, amount int, idempotencyKey string) (*models.GatewayRefund, error) {
+	refund, err := rg.client.Payment.Refund(paymentId, amount, nil, map[string]string{"X-Refund-Idempotency": idempotencyKey})
+	if err != nil {
+		return nil, fmt.Errorf("refund razorpay payment: %w", err)
+	}
+
+	return &models.GatewayRefund{
+		ID:        stringField(refund, "id"),
+		PaymentId: stringField(refund, "payment_id"),
+		Amount:    intField(refund, "amount"),
+		Status:    stringField(refund, "status"),
+	}, nil
+}
+
+func stringField(entity map[string]any, key string) string {
+	val, _ := entity[key].(string)
+	return val
+}
+
+// intField reads the numeric field of the razorpay response, json numbers are decoded as float64
+func intField(entity map[string]any, key string) int {
+	val, _ := entity[key].(float64)
+	return int(val)
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/models"
+)
+
+type RescheduleService struct {
+	ordersService   OrdersService
+	pricingService  PricingService
+	paymentsService PaymentsService
+	holdsService    HoldsService
+}
+
+func NewRescheduleService(ordersService OrdersService, pricingService PricingService, paymentsService PaymentsService, holdsService HoldsService) RescheduleService {
+	return RescheduleService{
+		ordersService:   ordersService,
+		pricingService:  pricingService,
+		paymentsService: paymentsService,
+		holdsService:    holdsService,
+	}
+}
+
+// Reschedule moves a confirmed order to another slot, date or theatre and settles the price difference.
+// A cheaper booking is moved to right away with a partial refund, while a costlier one is held and gets
+// a top up payment order, the order only moves once the top up is captured.
+func (rs *RescheduleService) Reschedule(orderId string, params models.RescheduleParams, actor string) (*models.Reschedule, error) {
+	order, err := rs.ordersService.GetById(orderId)
+	if err != nil {
+		return nil, fmt.Errorf("reschedule order: %w", err)
+	}
+
+	now := time.Now()
+	if order.Status != models.OrderConfirmed || !order.SlotStartsAt().After(now) {
+		return nil, models.ErrOrderNotReschedulable
+	}
+
+	theatreId := params.TheatreId
+	if theatreId == "" {
+		theatreId = order.Theatre.ID
+	}
+
+	reschedule := models.Reschedule{
+		ID:      uuid.NewString(),
+		OrderId: order.ID,
+		From: models.Booking{
+			TheatreId: order.Theatre.ID,
+			SlotId:    order.Slot.ID,
+			OrderDate: order.OrderDate,
+		},
+		To: models.Booking{
+			TheatreId: theatreId,
+			SlotId:    params.SlotId,
+			OrderDate: params.OrderDate,
+		},
+		Actor:     actor,
+		CreatedAt: now,
+	}
+
+	if reschedule.From.TheatreId == reschedule.To.TheatreId && reschedule.From.SlotId == reschedule.To.SlotId &&
+		reschedule.From.OrderDate.Format(time.DateOnly) == reschedule.To.OrderDate.Format(time.DateOnly) {
+		return nil, fmt.Errorf("%w: order is already booked for the slot", ErrInvalidOrder)
+	}
+
+	breakdown, err := rs.pricingService.Reprice(models.QuoteParams{
+		TheatreId:   theatreId,
+		SlotId:      params.SlotId,
+		NoOfPersons: order.NoOfPersons,
+		OrderDate:   params.OrderDate,
+	}, order.Addons, order.PriceBreakdown)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no theatre found with given details", ErrInvalidOrder)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reschedule order: %w", err)
+	}
+	reschedule.PriceBreakdown = breakdown
+	reschedule.PriceDifference = breakdown.Total - order.AmountPaid()
+
+	if reschedule.PriceDifference > 0 {
+		return rs.requestTopUp(reschedule)
+	}
+
+	reschedule.Status = models.RescheduleApplied
+	if err := rs.ordersService.Reschedule(reschedule); err != nil {
+		return nil, err
+	}
+
+	if reschedule.PriceDifference == 0 {
+		return &reschedule, nil
+	}
+
+	refund := models.RefundRequest{
+		ID:        reschedule.ID,
+		OrderId:   order.ID,
+		Amount:    -reschedule.PriceDifference,
+		CreatedAt: now,
+	}
+	// the order has moved along with the refund it is owed, a refund failing now is left pending for RetryRefund
+	reschedule.Refunds, err = rs.paymentsService.RefundPayments(*order, refund, now)
+	if err != nil {
+		reschedule.RefundPending = true
+		return &reschedule, fmt.Errorf("%w: reschedule %s: %w", models.ErrRefundPending, reschedule.ID, err)
+	}
+
+	return &reschedule, nil
+}
+
+// RetryRefund refunds what is left of the refund the reschedule of the order owes, the payments refunded for it
+// before are not refunded again. sql.ErrNoRows is returned when the reschedule of the order owes no refund.
+func (rs *RescheduleService) RetryRefund(orderId, rescheduleId string) (*models.RefundRequest, error) {
+	refund, err := rs.paymentsService.GetRefundRequest(rescheduleId)
+	if err != nil {
+		return nil, fmt.Errorf("retry reschedule refund: %w", err)
+	}
+	if refund.OrderId != orderId {
+		return nil, fmt.Errorf("retry reschedule refund: %w", sql.ErrNoRows)
+	}
+	if refund.Outstanding() == 0 {
+		return refund, nil
+	}
+
+	order, err := rs.ordersService.GetById(orderId)
+	if err != nil {
+		return nil, fmt.Errorf("retry reschedule refund: %w", err)
+	}
+
+	refund.Refunds, err = rs.paymentsService.RefundPayments(*order, *refund, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: reschedule %s: %w", models.ErrRefundPending, rescheduleId, err)
+	}
+	return refund, nil
+}
+
+// requestTopUp holds the new booking and creates the payment order for the price difference,
+// leaving the order on its booking until the top up is captured
+func (rs *RescheduleService) requestTopUp(reschedule models.Reschedule) (*models.Reschedule, error) {
+	hold, err := rs.holdsService.Acquire(models.Order{
+		ID:        reschedule.OrderId,
+		TheatreId: reschedule.To.TheatreId,
+		SlotId:    reschedule.To.SlotId,
+		OrderDate: reschedule.To.OrderDate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reschedule order: %w", err)
+	}
+
+	reschedule.TopUpRazorpayOrderId, err = rs.paymentsService.CreateOrder(reschedule.PriceDifference)
+	if err != nil {
+		return nil, fmt.Errorf("reschedule order: %w", errors.Join(err, rs.holdsService.Release(hold.ID)))
+	}
+
+	reschedule.Status = models.ReschedulePendingPayment
+	reschedule.HoldExpiresAt = &hold.ExpiresAt
+	if err := rs.ordersService.Reschedule(reschedule); err != nil {
+		return nil, errors.Join(err, rs.holdsService.Release(hold.ID))
+	}
+	return &reschedule, nil
+}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+type RolesService struct {
+	rolesRepo repository.RolesRepository
+}
+
+func NewRolesService(rolesRepo repository.RolesRepository) RolesService {
+	return RolesService{
+		rolesRepo: rolesRepo,
+	}
+}
+
+func (rs *RolesService) GetAll() ([]models.Role, error) {
+	return rs.rolesRepo.GetAll()
+}
+
+// Save creates the role or replaces its permissions. The admin role can not be changed, so there is always
+// someone able to manage the roles. The grantor can only add the permissions they hold to the role,
+// models.ErrGrantNotHeld is returned otherwise.
+func (rs *RolesService) Save(name string, params models.RoleParams, grantor models.Grantor) (*models.Role, error) {
+	if name == models.AdminRole {
+		return nil, models.ErrAdminRoleFixed
+	}
+
+	current, err := rs.rolesRepo.GetPermissions([]string{name})
+	if err != nil {
+		return nil, err
+	}
+	for _, permission := range params.Permissions {
+		if !slices.Contains(current, permission) && !slices.Contains(grantor.Permissions, permission) {
+			return nil, fmt.Errorf("%w: %s", models.ErrGrantNotHeld, permission)
+		}
+	}
+
+	role := models.Role{
+		Name:        name,
+		Description: params.Description,
+		Permissions: params.Permissions,
+	}
+	slices.Sort(role.Permissions)
+	role.Permissions = slices.Compact(role.Permissions)
+
+	if err := rs.rolesRepo.Save(role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// ValidateRoles returns models.ErrUnknownRole when any of the roles does not exist
+func (rs *RolesService) ValidateRoles(roles []string) error {
+	unique := slices.Clone(roles)
+	slices.Sort(unique)
+	unique = slices.Compact(unique)
+	count, err := rs.rolesRepo.CountExisting(unique)
+	if err != nil {
+		return err
+	}
+	if count != len(unique) {
+		return models.ErrUnknownRole
+	}
+	return nil
+}
+
+// CanGrant returns models.ErrGrantNotHeld when the grantor can not hand out any of the roles. A role can be
+// handed out by its holders and by the users holding every permission it grants.
+func (rs *RolesService) CanGrant(grantor models.Grantor, roles []string) error {
+	for _, role := range roles {
+		if slices.Contains(grantor.Roles, role) {
+			continue
+		}
+
+		permissions, err := rs.rolesRepo.GetPermissions([]string{role})
+		if err != nil {
+			return err
+		}
+		for _, permission := range permissions {
+			if !slices.Contains(grantor.Permissions, permission) {
+				return fmt.Errorf("%w: %s", models.ErrGrantNotHeld, role)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+type SchedulesService struct {
+	schedulesRepo repository.SchedulesRepository
+	theatresRepo  repository.TheatreRepository
+}
+
+func NewSchedulesService(schedulesRepo repository.SchedulesRepository, theatresRepo repository.TheatreRepository) SchedulesService {
+	return SchedulesService{
+		schedulesRepo: schedulesRepo,
+		theatresRepo:  theatresRepo,
+	}
+}
+
+// GetSchedule returns the rules of the theatre's slots and its blackouts from today onwards
+func (ss *SchedulesService) GetSchedule(theatreId string) (*models.TheatreSchedule, error) {
+	if _, err := ss.theatresRepo.GetTheatreDetails(theatreId); err != nil {
+		return nil, err
+	}
+	return getSchedule(ss.schedulesRepo, theatreId, time.Now(), nil)
+}
+
+// SetSlotRules replaces the rules of the theatre slot, a slot without rules runs every day
+func (ss *SchedulesService) SetSlotRules(theatreId, slotId string, params models.SlotScheduleParams, userId string) (*models.TheatreSchedule, error) {
+	now := time.Now()
+	rules := make([]models.ScheduleRule, 0, len(params.Rules))
+	for _, ruleParams := range params.Rules {
+		rules = append(rules, models.ScheduleRule{
+			ID:        uuid.NewString(),
+			TheatreId: theatreId,
+			SlotId:    slotId,
+			Weekdays:  ruleParams.Weekdays,
+			ValidFrom: ruleParams.ValidFrom,
+			ValidTo:   ruleParams.ValidTo,
+			CreatedAt: now,
+			CreatedBy: userId,
+		})
+	}
+
+	if err := ss.schedulesRepo.ReplaceSlotRules(theatreId, slotId, rules, userId, now); err != nil {
+		return nil, err
+	}
+	return getSchedule(ss.schedulesRepo, theatreId, now, nil)
+}
+
+func (ss *SchedulesService) AddBlackout(theatreId string, params models.BlackoutParams, userId string) (*models.Blackout, error) {
+	blackout := models.Blackout{
+		ID:        uuid.NewString(),
+		TheatreId: theatreId,
+		SlotId:    params.SlotId,
+		FromDate:  params.FromDate,
+		ToDate:    params.ToDate,
+		Reason:    params.Reason,
+		CreatedAt: time.Now(),
+		CreatedBy: userId,
+	}
+
+	if err := ss.schedulesRepo.AddBlackout(blackout); err != nil {
+		return nil, err
+	}
+	return &blackout, nil
+}
+
+func (ss *SchedulesService) DeleteBlackout(theatreId, blackoutId string) error {
+	return ss.schedulesRepo.DeleteBlackout(theatreId, blackoutId)
+}
+
+// getSchedule loads the rules of the theatre and its blackouts overlapping the dates, to being nil
+// loads every blackout ending on from or later
+func getSchedule(schedulesRepo repository.SchedulesRepository, theatreId string, from time.Time, to *time.Time) (*models.TheatreSchedule, error) {
+	rules, err := schedulesRepo.GetRules(theatreId)
+	if err != nil {
+		return nil, fmt.Errorf("get schedule: %w", err)
+	}
+
+	blackouts, err := schedulesRepo.GetBlackouts(theatreId, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("get schedule: %w", err)
+	}
+
+	return &models.TheatreSchedule{
+		TheatreId: theatreId,
+		Rules:     rules,
+		Blackouts: blackouts,
+	}, nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ortin779/private_theatre_api/api/auth"
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+type SessionsService struct {
+	refreshTokensRepo repository.RefreshTokensRepository
+	usersRepo         repository.UsersRepository
+	rolesRepo         repository.RolesRepository
+	tokenIssuer       *auth.TokenIssuer
+	config            models.SessionsConfig
+}
+
+func NewSessionsService(refreshTokensRepo repository.RefreshTokensRepository, usersRepo repository.UsersRepository, rolesRepo repository.RolesRepository, tokenIssuer *auth.TokenIssuer, sessionsConfig models.SessionsConfig) SessionsService {
+	return SessionsService{
+		refreshTokensRepo: refreshTokensRepo,
+		usersRepo:         usersRepo,
+		rolesRepo:         rolesRepo,
+		tokenIssuer:       tokenIssuer,
+		config:            sessionsConfig,
+	}
+}
+
+// Start issues the tokens of a new session for the user, starting a new refresh token family
+func (ss *SessionsService) Start(user models.User) (*models.LoginResponse, error) {
+	refreshToken, token, err := ss.newRefreshToken(user.ID, uuid.NewString())
+	if err != nil {
+		return nil, fmt.Errorf("start session: %w", err)
+	}
+
+	if err := ss.refreshTokensRepo.Create(token); err != nil {
+		return nil, fmt.Errorf("start session: %w", err)
+	}
+
+	accessToken, err := ss.newAccessToken(user)
+	if err != nil {
+		return nil, fmt.Errorf("start session: %w", err)
+	}
+
+	return &models.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+// Refresh swaps the refresh token for a new access token and refresh token. A refresh token can only be used once,
+// using it again revokes its whole family and returns models.ErrRefreshTokenReused.
+func (ss *SessionsService) Refresh(refreshToken string) (*models.LoginResponse, error) {
+	token, err := ss.refreshTokensRepo.GetByHash(hashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, models.ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("refresh session: %w", err)
+	}
+
+	now := time.Now()
+	// the family of a revoked token is already logged out
+	if token.RevokedAt != nil {
+		return nil, models.ErrInvalidRefreshToken
+	}
+	if token.UsedAt != nil {
+		return nil, ss.revokeReusedFamily(token.FamilyId, now)
+	}
+	if now.After(token.ExpiresAt) {
+		return nil, models.ErrRefreshTokenExpired
+	}
+
+	// roles and their permissions may have changed since the session started, the access token carries the current ones
+	user, err := ss.usersRepo.GetByUserId(token.UserId)
+	if err != nil {
+		return nil, fmt.Errorf("refresh session: %w", err)
+	}
+	if user.DisabledAt != nil {
+		if err := ss.refreshTokensRepo.RevokeFamily(token.FamilyId, now); err != nil {
+			return nil, fmt.Errorf("refresh session: %w", err)
+		}
+		return nil, models.ErrUserDisabled
+	}
+
+	nextRefreshToken, next, err := ss.newRefreshToken(user.ID, token.FamilyId)
+	if err != nil {
+		return nil, fmt.Errorf("refresh session: %w", err)
+	}
+
+	rotated, err := ss.refreshTokensRepo.Rotate(token.ID, next)
+	if err != nil {
+		return nil, fmt.Errorf("refresh session: %w", err)
+	}
+	if !rotated {
+		return nil, ss.revokeReusedFamily(token.FamilyId, now)
+	}
+
+	accessToken, err := ss.newAccessToken(*user)
+	if err != nil {
+		return nil, fmt.Errorf("refresh session: %w", err)
+	}
+
+	return &models.LoginResponse{
+		Token:        accessToken,
+		RefreshToken: nextRefreshToken,
+	}, nil
+}
+
+// End logs out the session of the refresh token, unknown tokens are ignored
+func (ss *SessionsService) End(refreshToken string) error {
+	token, err := ss.refreshTokensRepo.GetByHash(hashToken(refreshToken))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("end session: %w", err)
+	}
+
+	return ss.refreshTokensRepo.RevokeFamily(token.FamilyId, time.Now())
+}
+
+// EndAll logs out every session of the user
+func (ss *SessionsService) EndAll(userId string) error {
+	return ss.refreshTokensRepo.RevokeAllForUser(userId, time.Now())
+}
+
+func (ss *SessionsService) revokeReusedFamily(familyId string, now time.Time) error {
+	if err := ss.refreshTokensRepo.RevokeFamily(familyId, now); err != nil {
+		return fmt.Errorf("refresh session: %w", err)
+	}
+	return models.ErrRefreshTokenReused
+}
+
+// newAccessToken issues an access token with the permissions the roles of the user grant
+func (ss *SessionsService) newAccessToken(user models.User) (string, error) {
+	permissions, err := ss.rolesRepo.GetPermissions(user.Roles)
+	if err != nil {
+		return "", err
+	}
+	return ss.tokenIssuer.GenerateAccessToken(user.ID, user.Roles, permissions)
+}
+
+func (ss *SessionsService) newRefreshToken(userId, familyId string) (string, models.RefreshToken, error) {
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+
+	now := time.Now()
+	return refreshToken, models.RefreshToken{
+		ID:        uuid.NewString(),
+		UserId:    userId,
+		FamilyId:  familyId,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: now.Add(ss.config.RefreshTokenTTL),
+		CreatedAt: now,
+	}, nil
+}
@@ -1,6 +1,10 @@
 package service
 
 import (
+	"database/sql"
+	"fmt"
+	"time"
+
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/repository"
 )
@@ -22,3 +26,31 @@ func (ss *SlotsService) GetSlots() ([]models.Slot, error) {
 func (ss *SlotsService) AddSlot(slot models.Slot) error {
 	return ss.slotsRepo.AddSlot(slot)
 }
+
+// Update changes the times of the slot, failing with a *models.SlotConflictError when the new times overlap
+// the other slots of a theatre the slot is assigned to
+func (ss *SlotsService) Update(id string, params models.UpdateSlotParams, userId string) (*models.Slot, map[string]string, error) {
+	slot, err := ss.slotsRepo.GetById(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if slot.DeletedAt != nil {
+		return nil, nil, fmt.Errorf("update slot: %w", sql.ErrNoRows)
+	}
+
+	updated, errs := params.Apply(*slot)
+	if len(errs) > 0 {
+		return nil, errs, nil
+	}
+
+	updated.UpdatedBy = userId
+	updated.UpdatedAt = time.Now()
+	if err := ss.slotsRepo.Update(updated); err != nil {
+		return nil, nil, err
+	}
+	return &updated, nil, nil
+}
+
+func (ss *SlotsService) Delete(id string, userId string) error {
+	return ss.slotsRepo.Delete(id, userId, time.Now())
+}
@@ -1,21 +1,30 @@
 package service
 
 import (
+	"fmt"
+	"slices"
+	"time"
+
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/repository"
 )
 
 type TheatresService struct {
 	theatresRepo repository.TheatreRepository
+	slotsRepo    repository.SlotsRepository
 }
 
-func NewTheatreService(theatresRepo repository.TheatreRepository) TheatresService {
+func NewTheatreService(theatresRepo repository.TheatreRepository, slotsRepo repository.SlotsRepository) TheatresService {
 	return TheatresService{
 		theatresRepo: theatresRepo,
+		slotsRepo:    slotsRepo,
 	}
 }
 
 func (ts *TheatresService) Create(t models.Theatre, slots []string) error {
+	if err := ts.checkSlots(slots); err != nil {
+		return err
+	}
 	return ts.theatresRepo.Create(t, slots)
 }
 
@@ -26,3 +35,85 @@ func (ts *TheatresService) GetTheatres() ([]models.Theatre, error) {
 func (ts *TheatresService) GetTheatreDetails(id string) (*models.TheatreWithSlots, error) {
 	return ts.theatresRepo.GetTheatreDetails(id)
 }
+
+// Update applies the changes to the theatre, returning the validation errors of the combined capacities
+func (ts *TheatresService) Update(id string, params models.UpdateTheatreParams, userId string) (*models.TheatreWithSlots, map[string]string, error) {
+	theatre, err := ts.theatresRepo.GetTheatreDetails(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updated, errs := params.Apply(theatre.Theatre)
+	if len(errs) > 0 {
+		return nil, errs, nil
+	}
+	updated.UpdatedBy = userId
+	updated.UpdatedAt = time.Now()
+
+	if err := ts.theatresRepo.Update(updated); err != nil {
+		return nil, nil, err
+	}
+	theatre.Theatre = updated
+	return theatre, nil, nil
+}
+
+// AddSlots assigns the slots to the theatre, the overlaps with the slots of the theatre are checked along with
+// adding them
+func (ts *TheatresService) AddSlots(id string, slots []string, userId string) (*models.TheatreWithSlots, error) {
+	if _, err := ts.theatresRepo.GetTheatreDetails(id); err != nil {
+		return nil, err
+	}
+	if err := ts.checkSlots(slots); err != nil {
+		return nil, err
+	}
+
+	if err := ts.theatresRepo.AddSlots(id, slots, userId, time.Now()); err != nil {
+		return nil, err
+	}
+	return ts.theatresRepo.GetTheatreDetails(id)
+}
+
+func (ts *TheatresService) RemoveSlot(id, slotId string, userId string) (*models.TheatreWithSlots, error) {
+	if err := ts.theatresRepo.RemoveSlot(id, slotId, userId, time.Now()); err != nil {
+		return nil, err
+	}
+	return ts.theatresRepo.GetTheatreDetails(id)
+}
+
+func (ts *TheatresService) Archive(id string, userId string) (*models.TheatreWithSlots, error) {
+	if err := ts.theatresRepo.Archive(id, userId, time.Now()); err != nil {
+		return nil, err
+	}
+	return ts.theatresRepo.GetTheatreDetails(id)
+}
+
+func (ts *TheatresService) Restore(id string, userId string) (*models.TheatreWithSlots, error) {
+	if err := ts.theatresRepo.Restore(id, userId, time.Now()); err != nil {
+		return nil, err
+	}
+	return ts.theatresRepo.GetTheatreDetails(id)
+}
+
+// checkSlots makes sure the slots can be added to a theatre, returning models.ErrUnknownSlot for slots which do
+// not exist and a *models.SlotConflictError when the slots overlap each other
+func (ts *TheatresService) checkSlots(slotIds []string) error {
+	if len(slotIds) == 0 {
+		return nil
+	}
+
+	slots, err := ts.slotsRepo.GetByIds(slotIds)
+	if err != nil {
+		return err
+	}
+	for _, slotId := range slotIds {
+		idx := slices.IndexFunc(slots, func(slot models.Slot) bool { return slot.ID == slotId })
+		if idx < 0 || slots[idx].DeletedAt != nil {
+			return fmt.Errorf("%w: %s", models.ErrUnknownSlot, slotId)
+		}
+	}
+
+	if conflicts := models.FindOverlaps(nil, slots); len(conflicts) > 0 {
+		return &models.SlotConflictError{Slots: conflicts}
+	}
+	return nil
+}
@@ -1,21 +1,36 @@
 package service
 
 import (
+	"fmt"
+	"slices"
+	"time"
+
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/api/repository"
 )
 
 type UsersService struct {
-	usersRepo repository.UsersRepository
+	usersRepo       repository.UsersRepository
+	rolesService    RolesService
+	sessionsService SessionsService
 }
 
-func NewUsersService(usersRepo repository.UsersRepository) UsersService {
+func NewUsersService(usersRepo repository.UsersRepository, rolesService RolesService, sessionsService SessionsService) UsersService {
 	return UsersService{
-		usersRepo: usersRepo,
+		usersRepo:       usersRepo,
+		rolesService:    rolesService,
+		sessionsService: sessionsService,
 	}
 }
 
-func (us *UsersService) Create(user models.User) error {
+// Create stores the user, the grantor can only hand out the roles they can grant
+func (us *UsersService) Create(user models.User, grantor models.Grantor) error {
+	if err := us.rolesService.ValidateRoles(user.Roles); err != nil {
+		return err
+	}
+	if err := us.rolesService.CanGrant(grantor, user.Roles); err != nil {
+		return err
+	}
 	return us.usersRepo.Create(user)
 }
 
@@ -26,3 +41,54 @@ func (us *UsersService) GetByEmail(email string) (*models.User, error) {
 func (us *UsersService) GetByUserId(userId string) (*models.User, error) {
 	return us.usersRepo.GetByUserId(userId)
 }
+
+func (us *UsersService) List(filter models.UsersFilter) (*models.UsersPage, error) {
+	return us.usersRepo.List(filter)
+}
+
+// Update changes the name or roles of the user. New roles make it into the access tokens as the sessions refresh,
+// the grantor can only add the roles they can grant.
+func (us *UsersService) Update(userId string, params models.UpdateUserParams, grantor models.Grantor) (*models.User, error) {
+	user, err := us.usersRepo.GetByUserId(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Name != nil {
+		user.Name = *params.Name
+	}
+	if params.Roles != nil {
+		if err := us.rolesService.ValidateRoles(*params.Roles); err != nil {
+			return nil, err
+		}
+
+		added := make([]string, 0, len(*params.Roles))
+		for _, role := range *params.Roles {
+			if !slices.Contains(user.Roles, role) {
+				added = append(added, role)
+			}
+		}
+		if err := us.rolesService.CanGrant(grantor, added); err != nil {
+			return nil, err
+		}
+		user.Roles = *params.Roles
+	}
+
+	if err := us.usersRepo.Update(*user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Disable keeps the user from logging in and ends the sessions of the user, the access tokens already
+// issued stay valid until they expire
+func (us *UsersService) Disable(userId string) (*models.User, error) {
+	if err := us.usersRepo.Disable(userId, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := us.sessionsService.EndAll(userId); err != nil {
+		return nil, fmt.Errorf("disable user: %w", err)
+	}
+	return us.usersRepo.GetByUserId(userId)
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+type WebhooksService struct {
+	paymentRepo   repository.PaymentsRepository
+	holdsService  HoldsService
+	ordersService OrdersService
+	config        models.RazorpayConfig
+}
+
+var (
+	ErrWebhookSignatureFailure = errors.New("invalid webhook signature")
+	ErrInvalidWebhookPayload   = errors.New("invalid webhook payload")
+)
+
+func NewWebhooksService(paymentRepo repository.PaymentsRepository, holdsService HoldsService, ordersService OrdersService, paymentConfig models.RazorpayConfig) WebhooksService {
+	return WebhooksService{
+		paymentRepo:   paymentRepo,
+		holdsService:  holdsService,
+		ordersService: ordersService,
+		config:        paymentConfig,
+	}
+}
+
+// HandleRazorpayEvent verifies and applies the razorpay webhook event. Every event is applied at most once,
+// razorpay redelivering an event which was already applied is a no-op. It reports whether the event was applied.
+// Payments captured after their order could no longer use them are refunded, the event is then applied
+// and models.ErrPaymentRefunded is returned so that they can be followed up.
+func (ws *WebhooksService) HandleRazorpayEvent(eventId string, body []byte, signature string) (bool, error) {
+	if ws.config.WebhookSecret == "" || !verifyHmacSignature(body, signature, ws.config.WebhookSecret) {
+		return false, ErrWebhookSignatureFailure
+	}
+
+	var event models.RazorpayWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return false, fmt.Errorf("%w: %w", ErrInvalidWebhookPayload, err)
+	}
+
+	if eventId == "" {
+		// older webhook deliveries don't carry an event id, the signed body identifies them as well
+		sum := sha256.Sum256(body)
+		eventId = hex.EncodeToString(sum[:])
+	}
+
+	recorded, err := ws.paymentRepo.RecordWebhookEvent(eventId, event.Event, time.Now())
+	if err != nil {
+		return false, fmt.Errorf("handle webhook event: %w", err)
+	}
+	if !recorded {
+		return false, nil
+	}
+
+	err = ws.applyEvent(event)
+	if errors.Is(err, models.ErrPaymentRefunded) {
+		// the event is applied, the late payment is reported for a follow up
+		return true, err
+	}
+	if err != nil {
+		// forget the event so that razorpay's retry gets to apply it
+		if deleteErr := ws.paymentRepo.DeleteWebhookEvent(eventId); deleteErr != nil {
+			return false, fmt.Errorf("handle webhook event: %w", errors.Join(err, deleteErr))
+		}
+		return false, fmt.Errorf("handle webhook event: %w", err)
+	}
+	return true, nil
+}
+
+func (ws *WebhooksService) applyEvent(event models.RazorpayWebhookEvent) error {
+	switch event.Event {
+	case models.PaymentCapturedEvent:
+		if event.Payload.Payment == nil {
+			return ErrInvalidWebhookPayload
+		}
+		payment := event.Payload.Payment.Entity
+		return ws.capture(payment.OrderId, payment.ID)
+
+	case models.OrderPaidEvent:
+		if event.Payload.Order == nil || event.Payload.Payment == nil {
+			return ErrInvalidWebhookPayload
+		}
+		return ws.capture(event.Payload.Order.Entity.ID, event.Payload.Payment.Entity.ID)
+
+	case models.PaymentFailedEvent:
+		if event.Payload.Payment == nil {
+			return ErrInvalidWebhookPayload
+		}
+		payment := event.Payload.Payment.Entity
+		return ws.paymentRepo.MarkFailed(payment.OrderId, payment.ID)
+
+	case models.RefundProcessedEvent:
+		if event.Payload.Refund == nil {
+			return ErrInvalidWebhookPayload
+		}
+		refund := event.Payload.Refund.Entity
+		if err := ws.paymentRepo.UpdateRefundStatus(refund.ID, refund.Status); err != nil {
+			return err
+		}
+		if err := ws.markOrderRefunded(refund.ID); err != nil {
+			return err
+		}
+		// partial refunds keep the payment successful, only refunding all of it marks it refunded
+		if payment := event.Payload.Payment; payment != nil && payment.Entity.AmountRefunded < payment.Entity.Amount {
+			return nil
+		}
+		return ws.paymentRepo.MarkRefunded(refund.PaymentId)
+	}
+
+	// the webhook may be subscribed to more events than we care about
+	return nil
+}
+
+// markOrderRefunded moves the cancelled order of the refund to refunded, refunds issued from
+// the razorpay dashboard are not tracked with us and are skipped
+func (ws *WebhooksService) markOrderRefunded(refundId string) error {
+	refund, err := ws.paymentRepo.GetRefund(refundId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	// orders paid in more than one payment are refunded once every refund is processed
+	pending, err := ws.paymentRepo.HasPendingRefunds(refund.OrderId)
+	if err != nil || pending {
+		return err
+	}
+
+	err = ws.ordersService.Transition(refund.OrderId, models.OrderRefunded, models.SystemActor, "refund processed")
+	return ignoreInvalidTransition(err)
+}
+
+func (ws *WebhooksService) capture(orderId, paymentId string) error {
+	if err := ws.paymentRepo.MarkCaptured(orderId, paymentId); err != nil {
+		return err
+	}
+
+	err := ws.holdsService.Confirm(orderId, paymentId)
+	if errors.Is(err, models.ErrPaymentRefunded) {
+		return err
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return ignoreInvalidTransition(err)
+	}
+	return nil
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ortin779/private_theatre_api/api/models"
+	"github.com/ortin779/private_theatre_api/api/repository"
+)
+
+const (
+	testWebhookSecret   = "whsec_test"
+	testRazorpayOrderId = "order_DESlLckIVRkHWj"
+	testPaymentId       = "pay_DESlfW9H8K9uqM"
+	testOrderId         = "5b0b5b5e-6f0e-4c2c-9d5c-0c9a4f6f1e11"
+	testOrderAmount     = 250000
+)
+
+// fakePaymentsRepository keeps the payments, refunds and webhook events in memory
+type fakePaymentsRepository struct {
+	payments map[string]*models.CapturedPayment
+	statuses map[string]models.PaymentStatus
+	refunds  map[string]models.Refund
+	requests map[string]models.RefundRequest
+	events   map[string]string
+}
+
+func newFakePaymentsRepository() *fakePaymentsRepository {
+	return &fakePaymentsRepository{
+		payments: make(map[string]*models.CapturedPayment),
+		statuses: make(map[string]models.PaymentStatus),
+		refunds:  make(map[string]models.Refund),
+		requests: make(map[string]models.RefundRequest),
+		events:   make(map[string]string),
+	}
+}
+
+func (fr *fakePaymentsRepository) Create(orderId, status string, amount int) error {
+	fr.payments[orderId] = &models.CapturedPayment{RazorpayOrderId: orderId, Amount: amount}
+	fr.statuses[orderId] = models.PaymentStatus(status)
+	return nil
+}
+
+func (fr *fakePaymentsRepository) GetPayment(orderId string) (*models.CapturedPayment, error) {
+	payment, ok := fr.payments[orderId]
+	if !ok {
+		return nil, fmt.Errorf("get payment: %w", sql.ErrNoRows)
+	}
+	found := *payment
+	for _, refund := range fr.refunds {
+		if refund.RazorpayOrderId == orderId {
+			found.Refunded += refund.Amount
+		}
+	}
+	return &found, nil
+}
+
+func (fr *fakePaymentsRepository) Update(orderId, signature, paymentId string) error {
+	fr.payments[orderId].RazorpayPaymentId = paymentId
+	fr.statuses[orderId] = models.Success
+	return nil
+}
+
+func (fr *fakePaymentsRepository) MarkCaptured(orderId, paymentId string) error {
+	if status := fr.statuses[orderId]; status == models.Pending || status == models.Failure {
+		fr.payments[orderId].RazorpayPaymentId = paymentId
+		fr.statuses[orderId] = models.Success
+	}
+	return nil
+}
+
+func (fr *fakePaymentsRepository) MarkFailed(orderId, paymentId string) error {
+	if fr.statuses[orderId] == models.Pending {
+		fr.payments[orderId].RazorpayPaymentId = paymentId
+		fr.statuses[orderId] = models.Failure
+	}
+	return nil
+}
+
+func (fr *fakePaymentsRepository) MarkRefunded(paymentId string) error {
+	for orderId, payment := range fr.payments {
+		if payment.RazorpayPaymentId == paymentId {
+			fr.statuses[orderId] = models.Refunded
+		}
+	}
+	return nil
+}
+
+func (fr *fakePaymentsRepository) CreateRefund(refund models.Refund) error {
+	if _, ok := fr.refunds[refund.ID]; !ok {
+		fr.refunds[refund.ID] = refund
+	}
+	return nil
+}
+
+func (fr *fakePaymentsRepository) GetRefund(refundId string) (*models.Refund, error) {
+	refund, ok := fr.refunds[refundId]
+	if !ok {
+		return nil, fmt.Errorf("get refund: %w", sql.ErrNoRows)
+	}
+	return &refund, nil
+}
+
+func (fr *fakePaymentsRepository) GetRefundRequest(id string) (*models.RefundRequest, error) {
+	request, ok := fr.requests[id]
+	if !ok {
+		return nil, fmt.Errorf("get refund request: %w", sql.ErrNoRows)
+	}
+	for _, refund := range fr.refunds {
+		if refund.RefundRequestId == id {
+			request.Refunds = append(request.Refunds, refund)
+		}
+	}
+	return &request, nil
+}
+
+func (fr *fakePaymentsRepository) HasPendingRefunds(orderId string) (bool, error) {
+	for _, refund := range fr.refunds {
+		if refund.OrderId == orderId && refund.Status != models.RefundProcessed {
+			return true, nil
+		}
+	}
+	for id, request := range fr.requests {
+		if request.OrderId != orderId {
+			continue
+		}
+		if request, err := fr.GetRefundRequest(id); err != nil || request.Outstanding() > 0 {
+			return true, err
+		}
+	}
+	return false, nil
+}
+
+func (fr *fakePaymentsRepository) UpdateRefundStatus(refundId, status string) error {
+	if refund, ok := fr.refunds[refundId]; ok {
+		refund.Status = status
+		fr.refunds[refundId] = refund
+	}
+	return nil
+}
+
+func (fr *fakePaymentsRepository) RecordWebhookEvent(eventId, event string, receivedAt time.Time) (bool, error) {
+	if _, ok := fr.events[eventId]; ok {
+		return false, nil
+	}
+	fr.events[eventId] = event
+	return true, nil
+}
+
+func (fr *fakePaymentsRepository) DeleteWebhookEvent(eventId string) error {
+	delete(fr.events, eventId)
+	return nil
+}
+
+// fakeOrdersRepository keeps the status of the orders in memory, the webhooks need nothing else of the orders.
+// The orders themselves are only kept for the tests cancelling them, along with their history.
+type fakeOrdersRepository struct {
+	repository.OrdersRepository
+	razorpayOrders map[string]string
+	statuses       map[string]models.OrderStatus
+	orders         map[string]models.OrderDetails
+	history        map[string][]models.OrderTransition
+	payments       *fakePaymentsRepository
+}
+
+func (fr *fakeOrdersRepository) GetIdByRazorpayOrderId(razorpayOrderId string) (string, error) {
+	orderId, ok := fr.razorpayOrders[razorpayOrderId]
+	if !ok {
+		return "", fmt.Errorf("get order by razorpay order: %w", sql.ErrNoRows)
+	}
+	return orderId, nil
+}
+
+func (fr *fakeOrdersRepository) GetStatus(orderId string) (models.OrderStatus, error) {
+	status, ok := fr.statuses[orderId]
+	if !ok {
+		return "", fmt.Errorf("get order status: %w", sql.ErrNoRows)
+	}
+	return status, nil
+}
+
+func (fr *fakeOrdersRepository) Transition(transition models.OrderTransition) error {
+	if fr.statuses[transition.OrderId] != transition.FromStatus {
+		return fmt.Errorf("%w: order is no longer %s", models.ErrInvalidOrderTransition, transition.FromStatus)
+	}
+	fr.statuses[transition.OrderId] = transition.ToStatus
+	return nil
+}
+
+func (fr *fakeOrdersRepository) ApplyReschedule(razorpayOrderId string, at time.Time) error {
+	return fmt.Errorf("apply reschedule: %w", sql.ErrNoRows)
+}
+
+type webhooksTest struct {
+	service  WebhooksService
+	gateway  *FakeGateway
+	payments *fakePaymentsRepository
+	orders   *fakeOrdersRepository
+}
+
+// newWebhooksTest sets up an order in the given status, paid with the razorpay order of the recorded payloads
+func newWebhooksTest(t *testing.T, status models.OrderStatus) *webhooksTest {
+	t.Helper()
+
+	gateway := NewFakeGateway("secret")
+	gateway.orders[testRazorpayOrderId] = testOrderAmount
+	gateway.payments[testPaymentId] = &models.GatewayPayment{
+		ID:      testPaymentId,
+		OrderId: testRazorpayOrderId,
+		Amount:  testOrderAmount,
+		Status:  "captured",
+	}
+
+	paymentsRepo := newFakePaymentsRepository()
+	paymentsRepo.Create(testRazorpayOrderId, string(models.Pending), testOrderAmount)
+
+	ordersRepo := &fakeOrdersRepository{
+		razorpayOrders: map[string]string{testRazorpayOrderId: testOrderId},
+		statuses:       map[string]models.OrderStatus{testOrderId: status},
+	}
+
+	ordersService := NewOrdersService(ordersRepo)
+	paymentsService := NewPaymentsService(paymentsRepo, gateway)
+	holdsService := NewHoldsService(nil, ordersService, paymentsService, models.HoldConfig{TTL: time.Minute})
+
+	return &webhooksTest{
+		service:  NewWebhooksService(paymentsRepo, holdsService, ordersService, models.RazorpayConfig{WebhookSecret: testWebhookSecret}),
+		gateway:  gateway,
+		payments: paymentsRepo,
+		orders:   ordersRepo,
+	}
+}
+
+// deliver plays the recorded payload through the webhook the way razorpay posts it, signed with the webhook secret
+func (wt *webhooksTest) deliver(t *testing.T, eventId, payload string) (bool, error) {
+	t.Helper()
+
+	body, err := os.ReadFile(filepath.Join("testdata", "webhooks", payload))
+	if err != nil {
+		t.Fatalf("read payload %s: %v", payload, err)
+	}
+	return wt.service.HandleRazorpayEvent(eventId, body, hmacSignature(body, testWebhookSecret))
+}
+
+func TestWebhookPaymentCaptured(t *testing.T) {
+	wt := newWebhooksTest(t, models.OrderPendingPayment)
+
+	applied, err := wt.deliver(t, "evt_captured", "payment_captured.json")
+	if err != nil || !applied {
+		t.Fatalf("expected the event to be applied, got %v, %v", applied, err)
+	}
+	if status := wt.orders.statuses[testOrderId]; status != models.OrderConfirmed {
+		t.Errorf("expected the order to be confirmed, got %s", status)
+	}
+	if status := wt.payments.statuses[testRazorpayOrderId]; status != models.Success {
+		t.Errorf("expected the payment to be successful, got %s", status)
+	}
+}
+
+func TestWebhookOrderPaid(t *testing.T) {
+	wt := newWebhooksTest(t, models.OrderPendingPayment)
+
+	applied, err := wt.deliver(t, "evt_order_paid", "order_paid.json")
+	if err != nil || !applied {
+		t.Fatalf("expected the event to be applied, got %v, %v", applied, err)
+	}
+	if status := wt.orders.statuses[testOrderId]; status != models.OrderConfirmed {
+		t.Errorf("expected the order to be confirmed, got %s", status)
+	}
+}
+
+func TestWebhookPaymentFailed(t *testing.T) {
+	wt := newWebhooksTest(t, models.OrderPendingPayment)
+
+	applied, err := wt.deliver(t, "evt_failed", "payment_failed.json")
+	if err != nil || !applied {
+		t.Fatalf("expected the event to be applied, got %v, %v", applied, err)
+	}
+	if status := wt.payments.statuses[testRazorpayOrderId]; status != models.Failure {
+		t.Errorf("expected the payment to have failed, got %s", status)
+	}
+	if status := wt.orders.statuses[testOrderId]; status != models.OrderPendingPayment {
+		t.Errorf("expected the order to wait on its payment, got %s", status)
+	}
+}
+
+func TestWebhookRefundProcessed(t *testing.T) {
+	wt := newWebhooksTest(t, models.OrderCancelled)
+	wt.payments.MarkCaptured(testRazorpayOrderId, testPaymentId)
+	wt.payments.CreateRefund(models.Refund{
+		ID:                "rfnd_DGn6Ih8Ukd8Hfa",
+		OrderId:           testOrderId,
+		RazorpayOrderId:   testRazorpayOrderId,
+		RazorpayPaymentId: testPaymentId,
+		Amount:            testOrderAmount,
+		Status:            "pending",
+	})
+
+	applied, err := wt.deliver(t, "evt_refund", "refund_processed.json")
+	if err != nil || !applied {
+		t.Fatalf("expected the event to be applied, got %v, %v", applied, err)
+	}
+	if status := wt.payments.refunds["rfnd_DGn6Ih8Ukd8Hfa"].Status; status != models.RefundProcessed {
+		t.Errorf("expected the refund to be processed, got %s", status)
+	}
+	if status := wt.orders.statuses[testOrderId]; status != models.OrderRefunded {
+		t.Errorf("expected the order to be refunded, got %s", status)
+	}
+	if status := wt.payments.statuses[testRazorpayOrderId]; status != models.Refunded {
+		t.Errorf("expected the payment to be refunded, got %s", status)
+	}
+}
+
+func TestWebhookDuplicateEvent(t *testing.T) {
+	wt := newWebhooksTest(t, models.OrderPendingPayment)
+
+	if applied, err := wt.deliver(t, "evt_duplicate", "payment_captured.json"); err != nil || !applied {
+		t.Fatalf("expected the first delivery to be applied, got %v, %v", applied, err)
+	}
+
+	// the order moving on makes a second application visible
+	wt.orders.statuses[testOrderId] = models.OrderCheckedIn
+
+	applied, err := wt.deliver(t, "evt_duplicate", "payment_captured.json")
+	if err != nil {
+		t.Fatalf("expected the redelivery to succeed, got %v", err)
+	}
+	if applied {
+		t.Error("expected the redelivery not to be applied")
+	}
+	if status := wt.orders.statuses[testOrderId]; status != models.OrderCheckedIn {
+		t.Errorf("expected the order to be left alone, got %s", status)
+	}
+}
+
+func TestWebhookLateCaptureIsRefunded(t *testing.T) {
+	wt := newWebhooksTest(t, models.OrderExpired)
+
+	applied, err := wt.deliver(t, "evt_late", "payment_captured.json")
+	if !errors.Is(err, models.ErrPaymentRefunded) || !errors.Is(err, models.ErrHoldExpired) {
+		t.Fatalf("expected the late payment to be reported as refunded, got %v", err)
+	}
+	if !applied {
+		t.Error("expected the event to be applied")
+	}
+	if status := wt.orders.statuses[testOrderId]; status != models.OrderExpired {
+		t.Errorf("expected the order to stay expired, got %s", status)
+	}
+
+	var refunded int
+	for _, refund := range wt.payments.refunds {
+		if refund.OrderId == testOrderId && refund.RazorpayPaymentId == testPaymentId {
+			refunded += refund.Amount
+		}
+	}
+	if refunded != testOrderAmount {
+		t.Errorf("expected %d to be refunded, got %d", testOrderAmount, refunded)
+	}
+
+	// razorpay sending the capture once more does not refund it again
+	if _, err := wt.deliver(t, "evt_late_order_paid", "order_paid.json"); !errors.Is(err, models.ErrPaymentRefunded) {
+		t.Fatalf("expected the late payment to be reported as refunded, got %v", err)
+	}
+	if len(wt.payments.refunds) != 1 {
+		t.Errorf("expected a single refund, got %d", len(wt.payments.refunds))
+	}
+}
+
+func TestWebhookInvalidSignature(t *testing.T) {
+	wt := newWebhooksTest(t, models.OrderPendingPayment)
+
+	body, err := os.ReadFile(filepath.Join("testdata", "webhooks", "payment_captured.json"))
+	if err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+
+	_, err = wt.service.HandleRazorpayEvent("evt_forged", body, hmacSignature(body, "not the secret"))
+	if !errors.Is(err, ErrWebhookSignatureFailure) {
+		t.Fatalf("expected a signature failure, got %v", err)
+	}
+	if status := wt.orders.statuses[testOrderId]; status != models.OrderPendingPayment {
+		t.Errorf("expected the order to be left alone, got %s", status)
+	}
+}
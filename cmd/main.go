@@ -9,7 +9,10 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	// theatre timezones are looked up even where the system has no timezone database
+	_ "time/tzdata"
 
+	"github.com/ortin779/private_theatre_api/api/auth"
 	"github.com/ortin779/private_theatre_api/api/server"
 	"github.com/ortin779/private_theatre_api/config"
 	"github.com/ortin779/private_theatre_api/logger"
@@ -24,6 +27,12 @@ func run(ctx context.Context, logger *zap.Logger) error {
 		return err
 	}
 
+	tokenIssuer, err := auth.NewTokenIssuer(cfg.Tokens)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
 	db, err := cfg.Postgres.Open()
 	if err != nil {
 		logger.Error(err.Error())
@@ -32,7 +41,10 @@ func run(ctx context.Context, logger *zap.Logger) error {
 
 	defer db.Close()
 
-	svr := server.NewServer(logger, db, cfg)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	svr := server.NewServer(ctx, logger, db, cfg, tokenIssuer)
 
 	httpServer := &http.Server{
 		Addr:    net.JoinHostPort(cfg.Server.Host, cfg.Server.Port),
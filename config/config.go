@@ -1,10 +1,15 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/ortin779/private_theatre_api/api/auth"
 	"github.com/ortin779/private_theatre_api/api/models"
 	"github.com/ortin779/private_theatre_api/db"
 )
@@ -14,9 +19,17 @@ type Config struct {
 		Host string
 		Port string
 	}
-	Postgres db.PostgresConfig
-	Razorpay models.RazorpayConfig
-	Web      struct{ ShutdownTimeout int }
+	Postgres      db.PostgresConfig
+	Payments      models.PaymentsConfig
+	Razorpay      models.RazorpayConfig
+	Holds         models.HoldConfig
+	Pricing       models.PricingConfig
+	Cancellation  models.CancellationPolicy
+	Accounts      models.AccountsConfig
+	Sessions      models.SessionsConfig
+	LoginThrottle models.LoginThrottleConfig
+	Tokens        auth.TokenConfig
+	Web           struct{ ShutdownTimeout int }
 }
 
 func LoadConfigFromEnv() (*Config, error) {
@@ -25,7 +38,13 @@ func LoadConfigFromEnv() (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("load env config: %w", err)
 	}
-	return &Config{
+
+	tokens, err := loadTokenConfig()
+	if err != nil {
+		return nil, fmt.Errorf("load env config: %w", err)
+	}
+
+	cfg := &Config{
 		Server: struct {
 			Host string
 			Port string
@@ -41,10 +60,143 @@ func LoadConfigFromEnv() (*Config, error) {
 			DBName:   os.Getenv("DB_DBNAME"),
 			SSLMode:  os.Getenv("DB_SSLMODE"),
 		},
+		Payments: models.PaymentsConfig{
+			Gateway: getEnv("PAYMENT_GATEWAY", models.RazorpayGateway),
+		},
 		Razorpay: models.RazorpayConfig{
-			Key:    os.Getenv("RAZORPAY_KEY"),
-			Secret: os.Getenv("RAZORPAY_SECRET"),
+			Key:           os.Getenv("RAZORPAY_KEY"),
+			Secret:        os.Getenv("RAZORPAY_SECRET"),
+			WebhookSecret: os.Getenv("RAZORPAY_WEBHOOK_SECRET"),
+		},
+		Holds: models.HoldConfig{
+			TTL:           time.Duration(getEnvInt("HOLD_TTL_MINS", 10)) * time.Minute,
+			SweepInterval: time.Duration(getEnvInt("HOLD_SWEEP_INTERVAL_SECS", 60)) * time.Second,
+		},
+		Pricing: models.PricingConfig{
+			TaxPercent: getEnvFloat("PRICING_TAX_PERCENT", 0),
+		},
+		Cancellation: models.CancellationPolicy{
+			FullRefundHours:      getEnvInt("CANCEL_FULL_REFUND_HOURS", 48),
+			NoRefundHours:        getEnvInt("CANCEL_NO_REFUND_HOURS", 6),
+			PartialRefundPercent: getEnvInt("CANCEL_PARTIAL_REFUND_PERCENT", 50),
+		},
+		Accounts: models.AccountsConfig{
+			VerificationCodeTTL:        time.Duration(getEnvInt("VERIFICATION_CODE_TTL_MINS", 15)) * time.Minute,
+			VerificationResendInterval: time.Duration(getEnvInt("VERIFICATION_RESEND_INTERVAL_SECS", 60)) * time.Second,
+			MaxVerificationAttempts:    getEnvInt("VERIFICATION_MAX_ATTEMPTS", 5),
+			PasswordResetTTL:           time.Duration(getEnvInt("PASSWORD_RESET_TTL_MINS", 30)) * time.Minute,
+		},
+		Sessions: models.SessionsConfig{
+			RefreshTokenTTL: time.Duration(getEnvInt("JWT_REFRESH_TOKEN_EXP_MINS", 1440)) * time.Minute,
 		},
-		Web: struct{ ShutdownTimeout int }{8},
-	}, nil
+		LoginThrottle: models.LoginThrottleConfig{
+			FreeAttempts:     getEnvInt("LOGIN_FREE_ATTEMPTS", 3),
+			LockoutAttempts:  getEnvInt("LOGIN_LOCKOUT_ATTEMPTS", 10),
+			LockoutDuration:  time.Duration(getEnvInt("LOGIN_LOCKOUT_MINS", 15)) * time.Minute,
+			IPAttemptsFactor: getEnvInt("LOGIN_IP_ATTEMPTS_FACTOR", 10),
+			ClientIPHeader:   os.Getenv("CLIENT_IP_HEADER"),
+		},
+		Tokens: tokens,
+		Web:    struct{ ShutdownTimeout int }{8},
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("load env config: %w", err)
+	}
+	return cfg, nil
+}
+
+// validate rejects the settings the server can not run with, the holds need positive durations
+// and the cancellation policy hours and percent that make sense together
+func (cfg *Config) validate() error {
+	var errs []error
+	if cfg.Holds.TTL <= 0 {
+		errs = append(errs, errors.New("HOLD_TTL_MINS must be positive"))
+	}
+	if cfg.Holds.SweepInterval <= 0 {
+		errs = append(errs, errors.New("HOLD_SWEEP_INTERVAL_SECS must be positive"))
+	}
+
+	policy := cfg.Cancellation
+	if policy.NoRefundHours < 0 {
+		errs = append(errs, errors.New("CANCEL_NO_REFUND_HOURS can not be negative"))
+	}
+	if policy.FullRefundHours < policy.NoRefundHours {
+		errs = append(errs, errors.New("CANCEL_FULL_REFUND_HOURS can not be less than CANCEL_NO_REFUND_HOURS"))
+	}
+	if policy.PartialRefundPercent < 0 || policy.PartialRefundPercent > 100 {
+		errs = append(errs, errors.New("CANCEL_PARTIAL_REFUND_PERCENT must be between 0 and 100"))
+	}
+	return errors.Join(errs...)
+}
+
+// loadTokenConfig reads the JWT settings. JWT_SECRET_KEY becomes the HS256 key with the id "default", more keys
+// are listed in JWT_KEYS and each is configured with JWT_KEY_<ID>_ALG, _SECRET, _PRIVATE_KEY_FILE and _PUBLIC_KEY_FILE.
+// Tokens without a key id are accepted when issued before the RFC 3339 time in JWT_LEGACY_TOKENS_ISSUED_BEFORE.
+func loadTokenConfig() (auth.TokenConfig, error) {
+	tokenConfig := auth.TokenConfig{
+		Issuer:            os.Getenv("JWT_ISSUER"),
+		Audience:          os.Getenv("JWT_AUDIENCE"),
+		AccessTokenExpiry: time.Duration(getEnvInt("JWT_ACC_TOKEN_EXP_MINS", 60)) * time.Minute,
+		OrderTokenExpiry:  time.Duration(getEnvInt("JWT_ORDER_TOKEN_EXP_MINS", 90*24*60)) * time.Minute,
+		SigningKeyId:      getEnv("JWT_SIGNING_KEY_ID", auth.DefaultKeyId),
+	}
+
+	if issuedBefore := os.Getenv("JWT_LEGACY_TOKENS_ISSUED_BEFORE"); issuedBefore != "" {
+		cutoff, err := time.Parse(time.RFC3339, issuedBefore)
+		if err != nil {
+			return auth.TokenConfig{}, fmt.Errorf("JWT_LEGACY_TOKENS_ISSUED_BEFORE must be an RFC 3339 time: %w", err)
+		}
+		tokenConfig.LegacyTokensIssuedBefore = cutoff
+	}
+
+	if secret := os.Getenv("JWT_SECRET_KEY"); secret != "" {
+		tokenConfig.Keys = append(tokenConfig.Keys, auth.KeyConfig{
+			ID:        auth.DefaultKeyId,
+			Algorithm: auth.HS256,
+			Secret:    secret,
+		})
+	}
+
+	for _, id := range strings.Split(os.Getenv("JWT_KEYS"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		prefix := "JWT_KEY_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		tokenConfig.Keys = append(tokenConfig.Keys, auth.KeyConfig{
+			ID:             id,
+			Algorithm:      os.Getenv(prefix + "ALG"),
+			Secret:         os.Getenv(prefix + "SECRET"),
+			PrivateKeyFile: os.Getenv(prefix + "PRIVATE_KEY_FILE"),
+			PublicKeyFile:  os.Getenv(prefix + "PUBLIC_KEY_FILE"),
+		})
+	}
+	return tokenConfig, nil
+}
+
+// getEnv reads the environment variable, falling back to the given value when it is not set
+func getEnv(key, fallback string) string {
+	if val, ok := os.LookupEnv(key); ok && val != "" {
+		return val
+	}
+	return fallback
+}
+
+// getEnvInt reads an integer environment variable, falling back to the given value when it is not set or invalid
+func getEnvInt(key string, fallback int) int {
+	val, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return val
+}
+
+// getEnvFloat reads a decimal environment variable, falling back to the given value when it is not set or invalid
+func getEnvFloat(key string, fallback float64) float64 {
+	val, err := strconv.ParseFloat(os.Getenv(key), 64)
+	if err != nil {
+		return fallback
+	}
+	return val
 }
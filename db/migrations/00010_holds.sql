@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE holds(
+    id UUID PRIMARY KEY,
+    theatre_id UUID NOT NULL REFERENCES theatres(id),
+    slot_id UUID NOT NULL REFERENCES slots(id),
+    order_date DATE NOT NULL,
+    order_id UUID NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (theatre_id, slot_id, order_date)
+);
+
+CREATE INDEX holds_expires_at_idx ON holds(expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE holds;
+-- +goose StatementEnd
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+    ADD COLUMN cancelled_at TIMESTAMP,
+    DROP CONSTRAINT orders_theatre_id_slot_id_order_date_key;
+
+-- cancelled orders give up their theatre slot, so only the live orders need to be unique
+CREATE UNIQUE INDEX orders_theatre_id_slot_id_order_date_key
+    ON orders(theatre_id, slot_id, order_date)
+    WHERE cancelled_at IS NULL;
+
+CREATE TABLE refunds(
+    id TEXT PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id),
+    razorpay_order_id TEXT NOT NULL REFERENCES payments(razorpay_order_id),
+    razorpay_payment_id TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE refunds;
+
+DROP INDEX orders_theatre_id_slot_id_order_date_key;
+
+ALTER TABLE orders
+    DROP COLUMN cancelled_at,
+    ADD CONSTRAINT orders_theatre_id_slot_id_order_date_key UNIQUE (theatre_id, slot_id, order_date);
+-- +goose StatementEnd
@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE orders
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'pending_payment';
+
+UPDATE orders SET status = 'confirmed'
+    FROM payments
+    WHERE payments.razorpay_order_id = orders.razorpay_order_id AND payments.status = 'success';
+
+UPDATE orders SET status = 'cancelled'
+    WHERE cancelled_at IS NOT NULL;
+
+UPDATE orders SET status = 'refunded'
+    FROM payments
+    WHERE payments.razorpay_order_id = orders.razorpay_order_id AND payments.status = 'refunded' AND orders.cancelled_at IS NOT NULL;
+
+-- only the orders which are not cancelled, expired or refunded hold on to their theatre slot
+DROP INDEX orders_theatre_id_slot_id_order_date_key;
+
+CREATE UNIQUE INDEX orders_theatre_id_slot_id_order_date_key
+    ON orders(theatre_id, slot_id, order_date)
+    WHERE status IN ('pending_payment', 'confirmed', 'checked_in', 'completed');
+
+CREATE TABLE order_history(
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id),
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    actor TEXT NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX order_history_order_id_idx ON order_history(order_id);
+
+INSERT INTO order_history(id, order_id, from_status, to_status, actor, note, created_at)
+    SELECT gen_random_uuid(), id, NULL, status, 'system', 'status backfilled', ordered_at FROM orders;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE order_history;
+
+DROP INDEX orders_theatre_id_slot_id_order_date_key;
+
+CREATE UNIQUE INDEX orders_theatre_id_slot_id_order_date_key
+    ON orders(theatre_id, slot_id, order_date)
+    WHERE cancelled_at IS NULL;
+
+ALTER TABLE orders
+    DROP COLUMN status;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE order_reschedules(
+    id UUID PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id),
+    from_theatre_id UUID NOT NULL REFERENCES theatres(id),
+    from_slot_id UUID NOT NULL REFERENCES slots(id),
+    from_order_date DATE NOT NULL,
+    to_theatre_id UUID NOT NULL REFERENCES theatres(id),
+    to_slot_id UUID NOT NULL REFERENCES slots(id),
+    to_order_date DATE NOT NULL,
+    price_difference INTEGER NOT NULL,
+    razorpay_order_id TEXT REFERENCES payments(razorpay_order_id),
+    actor TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX order_reschedules_order_id_idx ON order_reschedules(order_id);
+
+ALTER TABLE order_history
+    ADD COLUMN details JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE order_history
+    DROP COLUMN details;
+
+DROP TABLE order_reschedules;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE INDEX orders_ordered_at_id_idx ON orders(ordered_at, id);
+CREATE INDEX orders_order_date_id_idx ON orders(order_date, id);
+CREATE INDEX orders_customer_email_idx ON orders(LOWER(customer_email));
+CREATE INDEX orders_phone_number_idx ON orders(phone_number);
+CREATE INDEX order_addons_order_id_idx ON order_addons(order_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX order_addons_order_id_idx;
+DROP INDEX orders_phone_number_idx;
+DROP INDEX orders_customer_email_idx;
+DROP INDEX orders_order_date_id_idx;
+DROP INDEX orders_ordered_at_id_idx;
+-- +goose StatementEnd
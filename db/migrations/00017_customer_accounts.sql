@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN phone_number TEXT,
+    ADD COLUMN email_verified_at TIMESTAMP,
+    ADD COLUMN created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- the existing users were all created by admins, who vouch for their emails
+UPDATE users SET email_verified_at = CURRENT_TIMESTAMP;
+
+CREATE UNIQUE INDEX users_lower_email_key ON users(LOWER(email));
+
+CREATE TABLE email_verifications(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    code_hash TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE orders
+    ADD COLUMN user_id UUID REFERENCES users(id);
+
+CREATE INDEX orders_user_id_idx ON orders(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX orders_user_id_idx;
+
+ALTER TABLE orders
+    DROP COLUMN user_id;
+
+DROP TABLE email_verifications;
+
+DROP INDEX users_lower_email_key;
+
+ALTER TABLE users
+    DROP COLUMN phone_number,
+    DROP COLUMN email_verified_at,
+    DROP COLUMN created_at;
+-- +goose StatementEnd
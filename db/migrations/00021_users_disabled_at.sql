@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN disabled_at TIMESTAMP;
+
+CREATE INDEX users_created_at_id_idx ON users(created_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_created_at_id_idx;
+
+ALTER TABLE users
+    DROP COLUMN disabled_at;
+-- +goose StatementEnd
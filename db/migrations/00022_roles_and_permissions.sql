@@ -0,0 +1,51 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE permissions (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission TEXT NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO permissions(name, description) VALUES
+    ('theatres:write', 'create and change theatres, their slots and prices'),
+    ('addons:write', 'create and change addons and their prices'),
+    ('orders:read', 'see every order and its history'),
+    ('orders:write', 'book and reschedule orders for customers'),
+    ('orders:check_in', 'check customers in and complete their orders'),
+    ('orders:refund', 'cancel and refund any order'),
+    ('users:read', 'see the users and the roles'),
+    ('users:write', 'create, change, disable and unlock users and change the roles'),
+    ('reports:read', 'see the business reports');
+
+INSERT INTO roles(name, description) VALUES
+    ('admin', 'manages the theatres and everything else'),
+    ('staff', 'front desk employees checking the customers in'),
+    ('customer', 'books theatres and manages their own orders');
+
+INSERT INTO role_permissions(role, permission)
+    SELECT 'admin', name FROM permissions;
+
+INSERT INTO role_permissions(role, permission) VALUES
+    ('staff', 'orders:read'),
+    ('staff', 'orders:write'),
+    ('staff', 'orders:check_in');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE role_permissions;
+
+DROP TABLE roles;
+
+DROP TABLE permissions;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE theatres
+    ADD COLUMN archived_at TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE theatres
+    DROP COLUMN archived_at;
+-- +goose StatementEnd
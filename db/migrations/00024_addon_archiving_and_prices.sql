@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE addons
+    ADD COLUMN archived_at TIMESTAMP;
+
+CREATE TABLE addon_prices(
+    id UUID PRIMARY KEY,
+    addon_id UUID NOT NULL REFERENCES addons(id),
+    price DOUBLE PRECISION NOT NULL,
+    effective_from TIMESTAMP NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id)
+);
+
+CREATE INDEX addon_prices_addon_id_idx ON addon_prices(addon_id, effective_from);
+
+INSERT INTO addon_prices(id, addon_id, price, effective_from, created_by)
+    SELECT gen_random_uuid(), id, price, created_at, created_by FROM addons;
+
+ALTER TABLE order_addons
+    ADD COLUMN unit_price DOUBLE PRECISION;
+
+-- the orders priced on the server have the unit price in their breakdown, in paise, the older ones get the current price
+UPDATE order_addons SET unit_price = COALESCE(
+    (SELECT (item->>'unit_price')::DOUBLE PRECISION / 100
+        FROM orders, JSONB_ARRAY_ELEMENTS(orders.price_breakdown->'items') AS item
+        WHERE orders.id = order_addons.order_id AND item->>'addon_id' = order_addons.addon_id::TEXT),
+    (SELECT price FROM addons WHERE addons.id = order_addons.addon_id)
+);
+
+ALTER TABLE order_addons
+    ALTER COLUMN unit_price SET NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE order_addons
+    DROP COLUMN unit_price;
+
+DROP TABLE addon_prices;
+
+ALTER TABLE addons
+    DROP COLUMN archived_at;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE slots
+    ADD COLUMN deleted_at TIMESTAMP;
+
+-- deleted slots are kept for their past orders, a new slot can take their times
+ALTER TABLE slots
+    DROP CONSTRAINT slots_start_time_end_time_key;
+
+CREATE UNIQUE INDEX slots_start_time_end_time_key
+    ON slots(start_time, end_time)
+    WHERE deleted_at IS NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX slots_start_time_end_time_key;
+
+ALTER TABLE slots
+    ADD CONSTRAINT slots_start_time_end_time_key UNIQUE (start_time, end_time);
+
+ALTER TABLE slots
+    DROP COLUMN deleted_at;
+-- +goose StatementEnd
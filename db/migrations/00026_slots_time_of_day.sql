@@ -0,0 +1,118 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE slots
+    ADD COLUMN start_minute SMALLINT,
+    ADD COLUMN end_minute SMALLINT;
+
+-- the times of day were kept as timestamps on the day the slot was created, a slot ending at midnight
+-- ended at 00:00 of the next day
+UPDATE slots SET
+    start_minute = EXTRACT(HOUR FROM start_time) * 60 + EXTRACT(MINUTE FROM start_time),
+    end_minute = EXTRACT(HOUR FROM end_time) * 60 + EXTRACT(MINUTE FROM end_time);
+
+-- slots made on different days for the same times are now the same slot, their theatres, upcoming orders
+-- and holds move to the oldest of them and the rest are deleted
+CREATE TEMPORARY TABLE duplicate_slots ON COMMIT DROP AS
+    SELECT id, FIRST_VALUE(id) OVER (PARTITION BY start_minute, end_minute ORDER BY created_at, id) AS kept_id
+    FROM slots
+    WHERE deleted_at IS NULL;
+
+DELETE FROM duplicate_slots WHERE id = kept_id;
+
+-- the same times of a theatre may have been booked twice under duplicate slots, which one of the orders keeps
+-- the theatre is up to the staff, so the migration stops until all but one of them are cancelled or rescheduled
+DO $$
+DECLARE
+    collisions TEXT;
+BEGIN
+    SELECT string_agg(order_ids, '; ') INTO collisions FROM (
+        SELECT string_agg(orders.id::TEXT, ', ' ORDER BY orders.id) AS order_ids
+        FROM orders
+        LEFT JOIN duplicate_slots ON duplicate_slots.id = orders.slot_id
+        WHERE orders.order_date >= CURRENT_DATE
+            AND orders.status IN ('pending_payment', 'confirmed', 'checked_in', 'completed')
+        GROUP BY orders.theatre_id, COALESCE(duplicate_slots.kept_id, orders.slot_id), orders.order_date
+        HAVING COUNT(*) > 1
+    ) AS colliding;
+
+    IF collisions IS NOT NULL THEN
+        RAISE EXCEPTION 'upcoming orders book the same theatre at the same times under duplicate slots, cancel or reschedule all but one order of each group and migrate again: %', collisions;
+    END IF;
+END $$;
+
+INSERT INTO theatre_slots(theatre_id, slot_id)
+    SELECT theatre_slots.theatre_id, duplicate_slots.kept_id
+    FROM theatre_slots
+    JOIN duplicate_slots ON duplicate_slots.id = theatre_slots.slot_id
+    ON CONFLICT DO NOTHING;
+
+DELETE FROM theatre_slots WHERE slot_id IN (SELECT id FROM duplicate_slots);
+
+UPDATE orders SET slot_id = duplicate_slots.kept_id
+    FROM duplicate_slots
+    WHERE orders.slot_id = duplicate_slots.id AND orders.order_date >= CURRENT_DATE;
+
+-- holds only last minutes, of the holds which would end up on the same slot the latest one is kept
+DELETE FROM holds WHERE id IN (
+    SELECT id FROM (
+        SELECT holds.id, ROW_NUMBER() OVER (
+            PARTITION BY holds.theatre_id, COALESCE(duplicate_slots.kept_id, holds.slot_id), holds.order_date
+            ORDER BY holds.expires_at DESC, holds.id
+        ) AS position
+        FROM holds
+        LEFT JOIN duplicate_slots ON duplicate_slots.id = holds.slot_id
+    ) AS ranked
+    WHERE position > 1
+);
+
+UPDATE holds SET slot_id = duplicate_slots.kept_id
+    FROM duplicate_slots
+    WHERE holds.slot_id = duplicate_slots.id;
+
+UPDATE slots SET deleted_at = NOW()
+    WHERE id IN (SELECT id FROM duplicate_slots);
+
+DROP INDEX slots_start_time_end_time_key;
+
+ALTER TABLE slots
+    DROP COLUMN start_time,
+    DROP COLUMN end_time,
+    ALTER COLUMN start_minute SET NOT NULL,
+    ALTER COLUMN end_minute SET NOT NULL,
+    ADD CONSTRAINT slots_start_minute_check CHECK (start_minute >= 0 AND start_minute < 1440),
+    ADD CONSTRAINT slots_end_minute_check CHECK (end_minute >= 0 AND end_minute < 1440),
+    ADD CONSTRAINT slots_length_check CHECK (start_minute <> end_minute);
+
+CREATE UNIQUE INDEX slots_start_minute_end_minute_key
+    ON slots(start_minute, end_minute)
+    WHERE deleted_at IS NULL;
+
+ALTER TABLE theatres
+    ADD COLUMN timezone TEXT NOT NULL DEFAULT 'Asia/Kolkata';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE theatres
+    DROP COLUMN timezone;
+
+DROP INDEX slots_start_minute_end_minute_key;
+
+ALTER TABLE slots
+    ADD COLUMN start_time TIMESTAMP,
+    ADD COLUMN end_time TIMESTAMP;
+
+UPDATE slots SET
+    start_time = CURRENT_DATE + make_interval(mins => start_minute),
+    end_time = CURRENT_DATE + make_interval(mins => CASE WHEN end_minute <= start_minute THEN end_minute + 1440 ELSE end_minute END);
+
+ALTER TABLE slots
+    DROP COLUMN start_minute,
+    DROP COLUMN end_minute,
+    ALTER COLUMN start_time SET NOT NULL,
+    ALTER COLUMN end_time SET NOT NULL;
+
+CREATE UNIQUE INDEX slots_start_time_end_time_key
+    ON slots(start_time, end_time)
+    WHERE deleted_at IS NULL;
+-- +goose StatementEnd
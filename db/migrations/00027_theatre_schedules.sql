@@ -0,0 +1,39 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE slot_schedule_rules(
+    id UUID PRIMARY KEY,
+    theatre_id UUID NOT NULL,
+    slot_id UUID NOT NULL,
+    weekdays SMALLINT[] NOT NULL,
+    valid_from DATE,
+    valid_to DATE,
+    created_at TIMESTAMP NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    FOREIGN KEY (theatre_id, slot_id) REFERENCES theatre_slots(theatre_id, slot_id) ON DELETE CASCADE,
+    CHECK (valid_from IS NULL OR valid_to IS NULL OR valid_from <= valid_to)
+);
+
+CREATE INDEX slot_schedule_rules_theatre_id_idx ON slot_schedule_rules(theatre_id, slot_id);
+
+CREATE TABLE theatre_blackouts(
+    id UUID PRIMARY KEY,
+    theatre_id UUID NOT NULL REFERENCES theatres(id),
+    slot_id UUID,
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    reason TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    FOREIGN KEY (theatre_id, slot_id) REFERENCES theatre_slots(theatre_id, slot_id) ON DELETE CASCADE,
+    CHECK (from_date <= to_date)
+);
+
+CREATE INDEX theatre_blackouts_theatre_id_idx ON theatre_blackouts(theatre_id, to_date);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE theatre_blackouts;
+
+DROP TABLE slot_schedule_rules;
+-- +goose StatementEnd
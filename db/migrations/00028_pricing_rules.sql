@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE pricing_rules(
+    id UUID PRIMARY KEY,
+    name TEXT NOT NULL,
+    theatre_id UUID REFERENCES theatres(id),
+    slot_id UUID REFERENCES slots(id),
+    weekdays SMALLINT[] NOT NULL DEFAULT '{}',
+    valid_from DATE,
+    valid_to DATE,
+    min_lead_days INT,
+    max_lead_days INT,
+    adjustment_type TEXT NOT NULL CHECK (adjustment_type IN ('percent', 'flat')),
+    adjustment DOUBLE PRECISION NOT NULL,
+    priority INT NOT NULL DEFAULT 0,
+    exclusive BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    updated_by UUID NOT NULL REFERENCES users(id),
+    CHECK (valid_from IS NULL OR valid_to IS NULL OR valid_from <= valid_to),
+    CHECK (min_lead_days IS NULL OR max_lead_days IS NULL OR min_lead_days <= max_lead_days)
+);
+
+CREATE INDEX pricing_rules_theatre_id_idx ON pricing_rules(theatre_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE pricing_rules;
+-- +goose StatementEnd
@@ -0,0 +1,53 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE coupons(
+    id UUID PRIMARY KEY,
+    code TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    discount_type TEXT NOT NULL CHECK (discount_type IN ('percent', 'flat')),
+    discount DOUBLE PRECISION NOT NULL CHECK (discount > 0),
+    max_discount DOUBLE PRECISION,
+    min_order_value DOUBLE PRECISION NOT NULL DEFAULT 0,
+    valid_from TIMESTAMP,
+    valid_to TIMESTAMP,
+    max_redemptions INT,
+    max_redemptions_per_customer INT,
+    theatre_ids UUID[] NOT NULL DEFAULT '{}',
+    addon_ids UUID[] NOT NULL DEFAULT '{}',
+    archived_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_by UUID NOT NULL REFERENCES users(id),
+    updated_by UUID NOT NULL REFERENCES users(id),
+    CHECK (valid_from IS NULL OR valid_to IS NULL OR valid_from < valid_to)
+);
+
+CREATE UNIQUE INDEX coupons_code_key ON coupons(UPPER(code));
+
+CREATE TABLE coupon_redemptions(
+    id UUID PRIMARY KEY,
+    coupon_id UUID NOT NULL REFERENCES coupons(id),
+    order_id UUID NOT NULL UNIQUE REFERENCES orders(id),
+    customer_email TEXT NOT NULL,
+    user_id UUID REFERENCES users(id),
+    discount INT NOT NULL,
+    redeemed_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX coupon_redemptions_coupon_id_idx ON coupon_redemptions(coupon_id);
+
+INSERT INTO permissions(name, description) VALUES
+    ('coupons:write', 'create and change coupons and see their redemptions');
+
+INSERT INTO role_permissions(role, permission) VALUES
+    ('admin', 'coupons:write');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'coupons:write';
+
+DROP TABLE coupon_redemptions;
+
+DROP TABLE coupons;
+-- +goose StatementEnd
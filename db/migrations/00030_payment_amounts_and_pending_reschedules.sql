@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE payments
+    ADD COLUMN amount INTEGER NOT NULL DEFAULT 0;
+
+UPDATE payments SET amount = order_reschedules.price_difference
+    FROM order_reschedules
+    WHERE order_reschedules.razorpay_order_id = payments.razorpay_order_id;
+
+-- the price breakdown of a rescheduled order is its latest one, every reschedule changed the total by its difference
+UPDATE payments SET amount = COALESCE((orders.price_breakdown->>'total')::INTEGER, orders.total_price * 100) - COALESCE((
+        SELECT SUM(order_reschedules.price_difference) FROM order_reschedules WHERE order_reschedules.order_id = orders.id
+    ), 0)
+    FROM orders
+    WHERE orders.razorpay_order_id = payments.razorpay_order_id;
+
+ALTER TABLE order_reschedules
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'applied' CHECK (status IN ('pending_payment', 'applied', 'failed')),
+    ADD COLUMN price_breakdown JSONB;
+
+ALTER TABLE order_reschedules
+    ALTER COLUMN status DROP DEFAULT;
+
+CREATE UNIQUE INDEX order_reschedules_razorpay_order_id_key ON order_reschedules(razorpay_order_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX order_reschedules_razorpay_order_id_key;
+
+ALTER TABLE order_reschedules
+    DROP COLUMN price_breakdown,
+    DROP COLUMN status;
+
+ALTER TABLE payments
+    DROP COLUMN amount;
+-- +goose StatementEnd
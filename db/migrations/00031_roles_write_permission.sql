@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+INSERT INTO permissions(name, description) VALUES
+    ('roles:write', 'create roles and change their permissions');
+
+INSERT INTO role_permissions(role, permission) VALUES
+    ('admin', 'roles:write');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM permissions WHERE name = 'roles:write';
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the amounts owed back for the orders, the refunds made for them are linked so what is left can be retried
+CREATE TABLE refund_requests(
+    id TEXT PRIMARY KEY,
+    order_id UUID NOT NULL REFERENCES orders(id),
+    amount INTEGER NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP NOT NULL
+);
+
+ALTER TABLE refunds
+    ADD COLUMN refund_request_id TEXT REFERENCES refund_requests(id);
+
+CREATE INDEX refunds_refund_request_id_idx ON refunds(refund_request_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX refunds_refund_request_id_idx;
+
+ALTER TABLE refunds
+    DROP COLUMN refund_request_id;
+
+DROP TABLE refund_requests;
+-- +goose StatementEnd